@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/opsorch/opsorch-core/alert"
 	"github.com/opsorch/opsorch-core/orcherr"
@@ -15,13 +14,13 @@ type AlertHandler struct {
 	provider alert.Provider
 }
 
-func newAlertHandlerFromEnv(sec SecretProvider) (AlertHandler, error) {
+func newAlertHandlerFromEnv(sec SecretProvider, host pluginHost) (AlertHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "alert", "OPSORCH_ALERT_PROVIDER", "OPSORCH_ALERT_CONFIG", "OPSORCH_ALERT_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return AlertHandler{}, err
 	}
 	if pluginPath != "" {
-		return AlertHandler{provider: newAlertPluginProvider(pluginPath, cfg)}, nil
+		return AlertHandler{provider: observeAlert(pluginPath, newAlertPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := alert.LookupProvider(name)
 	if !ok {
@@ -31,47 +30,37 @@ func newAlertHandlerFromEnv(sec SecretProvider) (AlertHandler, error) {
 	if err != nil {
 		return AlertHandler{}, err
 	}
-	return AlertHandler{provider: provider}, nil
+	return AlertHandler{provider: observeAlert(name, provider)}, nil
 }
 
-func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/alerts") {
-		return false
+// alertProvider returns the alert provider currently installed.
+func (s *Server) alertProvider() alert.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.alert.provider
+}
+
+func (s *Server) queryAlerts(w http.ResponseWriter, r *http.Request) {
+	var query schema.AlertQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if s.alert.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "alert_provider_missing", Message: "alert provider not configured"})
-		return true
+	alerts, err := s.alertProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "alert.query")
+	writeJSON(w, http.StatusOK, alerts)
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
-
-	switch {
-	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
-		var query schema.AlertQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		alerts, err := s.alert.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "alert.query")
-		writeJSON(w, http.StatusOK, alerts)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodGet:
-		id := segments[1]
-		al, err := s.alert.provider.Get(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "alert.get")
-		writeJSON(w, http.StatusOK, al)
-		return true
-	default:
-		return false
+func (s *Server) getAlert(w http.ResponseWriter, r *http.Request) {
+	al, err := s.alertProvider().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "alert.get")
+	writeJSON(w, http.StatusOK, al)
 }
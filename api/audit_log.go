@@ -1,10 +1,11 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -12,29 +13,90 @@ import (
 // AuditLogEntry captures structured details for audit actions.
 // Action is a dot-separated string, e.g. "incident.created", "incident.query".
 type AuditLogEntry struct {
-	RequestID string    `json:"request_id"`
-	ActorType string    `json:"actor_type"`
-	ActorID   string    `json:"actor_id"`
-	Timestamp time.Time `json:"timestamp"`
-	Action    string    `json:"action"`
+	RequestID  string            `json:"request_id"`
+	ActorType  string            `json:"actor_type"`
+	ActorID    string            `json:"actor_id"`
+	Capability string            `json:"capability,omitempty"` // capability of the route that handled the request
+	Timestamp  time.Time         `json:"timestamp"`
+	Action     string            `json:"action"`
+	Details    map[string]string `json:"details,omitempty"`
 }
 
 func logAudit(r *http.Request, action string) {
+	logAuditDetails(r, action, nil)
+}
+
+// logAuditDetails records action on behalf of the request's caller with extra details.
+func logAuditDetails(r *http.Request, action string, details map[string]string) {
 	entry := AuditLogEntry{
 		RequestID: requestIDFromRequest(r),
-		ActorType: actorTypeFromRequest(r),
-		ActorID:   actorIDFromRequest(r),
 		Timestamp: time.Now().UTC(),
 		Action:    action,
+		Details:   details,
+	}
+	entry.ActorType, entry.ActorID = requestActor(r)
+	if info := requestInfoFrom(r.Context()); info != nil {
+		entry.Capability = info.capability
 	}
+	if caller := principalFrom(r.Context()); caller != nil && caller.actorID != "" {
+		if entry.Details == nil {
+			entry.Details = make(map[string]string)
+		}
+		entry.Details["credential"] = caller.name
+		if len(caller.groups) > 0 {
+			entry.Details["groups"] = strings.Join(caller.groups, ",")
+		}
+	}
+	writeAudit(entry)
+}
 
-	encoded, err := json.Marshal(entry)
-	if err != nil {
-		log.Printf("audit_log_error action=%s request_id=%s err=%v", action, entry.RequestID, err)
-		return
+// requestActor returns the actor type and ID of the request. A credential with an identity
+// overrides the client-supplied actor headers.
+func requestActor(r *http.Request) (actorType, actorID string) {
+	if caller := principalFrom(r.Context()); caller != nil && caller.actorID != "" {
+		return caller.actorType, caller.actorID
 	}
+	return actorTypeFromRequest(r), actorIDFromRequest(r)
+}
 
-	log.Printf("audit_log %s", string(encoded))
+// logSystemAudit records an action taken by core itself rather than on behalf of a request.
+func logSystemAudit(action string, details map[string]string) {
+	writeAudit(AuditLogEntry{
+		RequestID: fmt.Sprintf("generated-%d", time.Now().UnixNano()),
+		ActorType: "system",
+		ActorID:   "opsorch-core",
+		Timestamp: time.Now().UTC(),
+		Action:    action,
+		Details:   details,
+	})
+}
+
+// writeAudit writes entry to the audit stream as an audit_log record. Audit records bypass the
+// application log level.
+func writeAudit(entry AuditLogEntry) {
+	rec := slog.NewRecord(entry.Timestamp, slog.LevelInfo, "audit_log", 0)
+	rec.AddAttrs(
+		slog.String("request_id", entry.RequestID),
+		slog.String("actor_type", entry.ActorType),
+		slog.String("actor_id", entry.ActorID),
+	)
+	if entry.Capability != "" {
+		rec.AddAttrs(slog.String("capability", entry.Capability))
+	}
+	rec.AddAttrs(slog.String("action", entry.Action))
+	if len(entry.Details) > 0 {
+		keys := make([]string, 0, len(entry.Details))
+		for k := range entry.Details {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		details := make([]any, 0, len(keys))
+		for _, k := range keys {
+			details = append(details, slog.String(k, entry.Details[k]))
+		}
+		rec.AddAttrs(slog.Group("details", details...))
+	}
+	_ = auditLogger().Handler().Handle(context.Background(), rec)
 }
 
 func actorTypeFromRequest(r *http.Request) string {
@@ -58,7 +120,12 @@ func actorIDFromRequest(r *http.Request) string {
 	return "unknown"
 }
 
+// requestIDFromRequest returns the request's ID: the one ServeHTTP settled on, else the first
+// tracing header set, else a generated one.
 func requestIDFromRequest(r *http.Request) string {
+	if info := requestInfoFrom(r.Context()); info != nil && info.requestID != "" {
+		return info.requestID
+	}
 	for _, header := range []string{"X-Request-ID", "X-Amzn-Trace-Id", "X-Correlation-ID", "X-Trace-ID"} {
 		if id := strings.TrimSpace(r.Header.Get(header)); id != "" {
 			return id
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	name      string // credential name, recorded in audit details
+	actorID   string // empty when the credential carries no identity; audit falls back to headers
+	actorType string
+	groups    []string // group memberships from an OIDC token
+	scopes    []string
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p *principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFrom returns the caller of the request, or nil when authentication is disabled.
+func principalFrom(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+var errUnauthorized = errors.New("missing or invalid bearer token")
+
+// authenticate identifies the caller from its bearer token or client certificate. It returns a nil principal when
+// no credentials are configured, in which case every route is open.
+func (s *Server) authenticate(r *http.Request) (*principal, error) {
+	if s.tokens == nil && s.oidc == nil && s.bearerToken == "" && s.clientCerts == nil {
+		return nil, nil
+	}
+	const prefix = "Bearer "
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, prefix) {
+		// A bearer token takes precedence; without one, a verified client certificate identifies the caller.
+		if s.clientCerts != nil {
+			if p := s.clientCerts.principal(r.TLS); p != nil {
+				return p, nil
+			}
+		}
+		return nil, errUnauthorized
+	}
+	token := strings.TrimSpace(authz[len(prefix):])
+
+	if s.bearerToken != "" && tokenEqual(token, s.bearerToken) {
+		return &principal{name: "OPSORCH_BEARER_TOKEN", scopes: []string{"*"}}, nil
+	}
+	if s.oidc != nil && looksLikeJWT(token) {
+		return s.oidc.verify(r.Context(), token)
+	}
+	if s.tokens != nil {
+		return s.tokens.lookup(token)
+	}
+	return nil, errUnauthorized
+}
+
+// tokenEqual compares tokens in constant time, hashing first so their lengths do not leak.
+func tokenEqual(a, b string) bool {
+	da, db := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(da[:], db[:]) == 1
+}
+
+// allows reports whether the principal holds scope. A nil principal (authentication disabled)
+// and an empty scope always pass.
+func (p *principal) allows(scope string) bool {
+	if p == nil || scope == "" {
+		return true
+	}
+	resource, access, _ := strings.Cut(scope, ":")
+	for _, granted := range p.scopes {
+		if granted == "*" {
+			return true
+		}
+		gotResource, gotAccess, _ := strings.Cut(granted, ":")
+		if gotResource != resource {
+			continue
+		}
+		if gotAccess == "*" || scopeRank[gotAccess] >= scopeRank[access] {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeRank orders access levels; a scope grants every level at or below its own.
+var scopeRank = map[string]int{"read": 1, "write": 2, "admin": 3}
+
+// validScope reports whether scope names a known resource and access level.
+func validScope(scope string) bool {
+	if scope == "*" {
+		return true
+	}
+	resource, access, ok := strings.Cut(scope, ":")
+	if !ok || (access != "*" && scopeRank[access] == 0) {
+		return false
+	}
+	if resource == "providers" || resource == "admin" {
+		return true
+	}
+	for _, c := range capabilities {
+		if c == resource {
+			return true
+		}
+	}
+	return false
+}
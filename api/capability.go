@@ -2,6 +2,9 @@ package api
 
 import "strings"
 
+// capabilities lists the canonical capability keys served under the HTTP API.
+var capabilities = []string{"incident", "alert", "log", "metric", "ticket", "messaging", "service", "deployment", "team", "orchestration"}
+
 // normalizeCapability maps plural or variant path segments to canonical capability keys.
 func normalizeCapability(name string) (string, bool) {
 	switch strings.ToLower(name) {
@@ -3,7 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/opsorch/opsorch-core/orcherr"
@@ -21,9 +21,21 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError answers with an error body and logs it: at error level for server-side failures,
+// at info level for the caller's mistakes.
 func writeError(w http.ResponseWriter, status int, err orcherr.OpsOrchError) {
-	log.Printf("API error (status=%d): code=%s, message=%s", status, err.Code, err.Message)
-	writeJSON(w, status, map[string]string{"code": err.Code, "message": err.Message})
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(requestContext(w), level, "api_error", "status", status, "code", err.Code, "message", err.Message)
+	writeJSON(w, status, errorResponse{Code: err.Code, Message: err.Message})
 }
 
 func asOpsOrchError(err error) *orcherr.OpsOrchError {
@@ -46,11 +58,20 @@ func writeProviderError(w http.ResponseWriter, err error) {
 			status = http.StatusNotFound
 		case "bad_request":
 			status = http.StatusBadRequest
+		case "forbidden":
+			status = http.StatusForbidden
+		case "rate_limited":
+			status = http.StatusTooManyRequests
+		case "plugin_unavailable":
+			status = http.StatusServiceUnavailable
+		case "timeout":
+			status = http.StatusGatewayTimeout
+		case "not_implemented":
+			status = http.StatusNotImplemented
 		}
 		writeError(w, status, *oe)
 		return
 	}
-	// If not an OpsOrchError, log the raw error and return a generic provider error with the actual error message
-	log.Printf("Provider error (non-OpsOrchError): %v", err)
+	// If not an OpsOrchError, return a generic provider error with the actual error message
 	writeError(w, http.StatusBadGateway, orcherr.OpsOrchError{Code: "provider_error", Message: err.Error()})
 }
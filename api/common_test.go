@@ -60,3 +60,30 @@ func TestWriteProviderErrorGeneric(t *testing.T) {
 		t.Fatalf("expected message 'connection timeout', got %s", body["message"])
 	}
 }
+
+func TestWriteProviderErrorPluginUnavailable(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeProviderError(rr, orcherr.New("plugin_unavailable", "incident plugin is restarting", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestWriteProviderErrorTimeout(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeProviderError(rr, orcherr.New("timeout", "log plugin did not answer log.query in time", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+}
+
+func TestWriteProviderErrorNotImplemented(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeProviderError(rr, orcherr.New("not_implemented", "incident plugin does not implement incident.timeline.append", nil))
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected status %d, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/opsorch/opsorch-core/deployment"
 	"github.com/opsorch/opsorch-core/orcherr"
@@ -15,13 +14,13 @@ type DeploymentHandler struct {
 	provider deployment.Provider
 }
 
-func newDeploymentHandlerFromEnv(sec SecretProvider) (DeploymentHandler, error) {
+func newDeploymentHandlerFromEnv(sec SecretProvider, host pluginHost) (DeploymentHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "deployment", "OPSORCH_DEPLOYMENT_PROVIDER", "OPSORCH_DEPLOYMENT_CONFIG", "OPSORCH_DEPLOYMENT_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return DeploymentHandler{}, err
 	}
 	if pluginPath != "" {
-		return DeploymentHandler{provider: newDeploymentPluginProvider(pluginPath, cfg)}, nil
+		return DeploymentHandler{provider: observeDeployment(pluginPath, newDeploymentPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := deployment.LookupProvider(name)
 	if !ok {
@@ -31,53 +30,38 @@ func newDeploymentHandlerFromEnv(sec SecretProvider) (DeploymentHandler, error)
 	if err != nil {
 		return DeploymentHandler{}, err
 	}
-	return DeploymentHandler{provider: provider}, nil
+	return DeploymentHandler{provider: observeDeployment(name, provider)}, nil
 }
 
 // handleDeployment handles deployment HTTP requests from the server
-func (s *Server) handleDeployment(w http.ResponseWriter, r *http.Request) bool {
-	return s.deployment.handleDeploymentRequest(w, r)
+// deploymentProvider returns the deployment provider currently installed.
+func (s *Server) deploymentProvider() deployment.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.deployment.provider
 }
 
-// handleDeploymentRequest handles deployment HTTP requests
-func (h *DeploymentHandler) handleDeploymentRequest(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/deployments") {
-		return false
+func (s *Server) queryDeployments(w http.ResponseWriter, r *http.Request) {
+	var query schema.DeploymentQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if h.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "deployment_provider_missing", Message: "deployment provider not configured"})
-		return true
+	deployments, err := s.deploymentProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "deployment.query")
+	writeJSON(w, http.StatusOK, deployments)
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
-
-	switch {
-	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
-		var query schema.DeploymentQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		deployments, err := h.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "deployment.query")
-		writeJSON(w, http.StatusOK, deployments)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodGet:
-		id := segments[1]
-		deployment, err := h.provider.Get(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "deployment.get")
-		writeJSON(w, http.StatusOK, deployment)
-		return true
-	default:
-		return false
+func (s *Server) getDeployment(w http.ResponseWriter, r *http.Request) {
+	deployment, err := s.deploymentProvider().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "deployment.get")
+	writeJSON(w, http.StatusOK, deployment)
 }
@@ -107,7 +107,7 @@ func TestProperty_EnvironmentConfigurationProcessing(t *testing.T) {
 			mockSec := &mockSecretProvider{}
 
 			// Test the environment configuration processing
-			handler, err := newDeploymentHandlerFromEnv(mockSec)
+			handler, err := newDeploymentHandlerFromEnv(mockSec, nil)
 
 			// Verify expectations
 			if tc.expectError && err == nil {
@@ -300,7 +300,7 @@ func TestProperty_DeploymentQueryBodyProcessing(t *testing.T) {
 			recorder := httptest.NewRecorder()
 
 			// Call the handler
-			handled := handler.handleDeploymentRequest(recorder, req)
+			handled := (&Server{deployment: *handler}).dispatch(recorder, req)
 
 			// Verify the request was handled
 			if !handled {
@@ -401,7 +401,7 @@ func TestProperty_DeploymentIDRetrieval(t *testing.T) {
 			recorder := httptest.NewRecorder()
 
 			// Call the handler
-			handled := handler.handleDeploymentRequest(recorder, req)
+			handled := (&Server{deployment: *handler}).dispatch(recorder, req)
 
 			// Verify the request was handled
 			if !handled {
@@ -510,7 +510,7 @@ func TestProperty_ProviderErrorHandling(t *testing.T) {
 				req.Header.Set("Content-Type", "application/json")
 				recorder := httptest.NewRecorder()
 
-				handled := handler.handleDeploymentRequest(recorder, req)
+				handled := (&Server{deployment: *handler}).dispatch(recorder, req)
 
 				if !handled {
 					t.Errorf("expected request to be handled")
@@ -543,7 +543,7 @@ func TestProperty_ProviderErrorHandling(t *testing.T) {
 				req := httptest.NewRequest("GET", "/deployments/test-id", nil)
 				recorder := httptest.NewRecorder()
 
-				handled := handler.handleDeploymentRequest(recorder, req)
+				handled := (&Server{deployment: *handler}).dispatch(recorder, req)
 
 				if !handled {
 					t.Errorf("expected request to be handled")
@@ -624,7 +624,7 @@ func TestProperty_ErrorResponseConsistency(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			recorder := httptest.NewRecorder()
 
-			handled := handler.handleDeploymentRequest(recorder, req)
+			handled := (&Server{deployment: *handler}).dispatch(recorder, req)
 
 			if !handled {
 				t.Errorf("expected request to be handled")
@@ -929,7 +929,7 @@ func TestProperty_ProviderStatusReporting(t *testing.T) {
 			req := httptest.NewRequest("GET", "/providers/deployment", nil)
 			recorder := httptest.NewRecorder()
 
-			handled := server.handleProviders(recorder, req)
+			handled := server.dispatch(recorder, req)
 
 			if !handled {
 				t.Errorf("expected providers request to be handled")
@@ -973,7 +973,7 @@ func TestProperty_ProviderStatusReporting(t *testing.T) {
 				req := httptest.NewRequest("GET", path, nil)
 				recorder := httptest.NewRecorder()
 
-				handled := server.handleProviders(recorder, req)
+				handled := server.dispatch(recorder, req)
 
 				if !handled {
 					t.Errorf("expected providers request to be handled for path %s", path)
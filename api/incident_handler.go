@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/opsorch/opsorch-core/incident"
@@ -16,13 +15,13 @@ type IncidentHandler struct {
 	provider incident.Provider
 }
 
-func newIncidentHandlerFromEnv(sec SecretProvider) (IncidentHandler, error) {
+func newIncidentHandlerFromEnv(sec SecretProvider, host pluginHost) (IncidentHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "incident", "OPSORCH_INCIDENT_PROVIDER", "OPSORCH_INCIDENT_CONFIG", "OPSORCH_INCIDENT_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return IncidentHandler{}, err
 	}
 	if pluginPath != "" {
-		return IncidentHandler{provider: newIncidentPluginProvider(pluginPath, cfg)}, nil
+		return IncidentHandler{provider: observeIncident(pluginPath, newIncidentPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := incident.LookupProvider(name)
 	if !ok {
@@ -32,103 +31,94 @@ func newIncidentHandlerFromEnv(sec SecretProvider) (IncidentHandler, error) {
 	if err != nil {
 		return IncidentHandler{}, err
 	}
-	return IncidentHandler{provider: provider}, nil
+	return IncidentHandler{provider: observeIncident(name, provider)}, nil
 }
 
-func (s *Server) handleIncident(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/incidents") {
-		return false
+// incidentProvider returns the incident provider currently installed.
+func (s *Server) incidentProvider() incident.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.incident.provider
+}
+
+func (s *Server) queryIncidents(w http.ResponseWriter, r *http.Request) {
+	var query schema.IncidentQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	incidents, err := s.incidentProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "incident.query")
+	writeJSON(w, http.StatusOK, incidents)
+}
+
+func (s *Server) createIncident(w http.ResponseWriter, r *http.Request) {
+	var input schema.CreateIncidentInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if s.incident.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "incident_provider_missing", Message: "incident provider not configured"})
-		return true
+	inc, err := s.incidentProvider().Create(r.Context(), input)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "incident.created")
+	writeJSON(w, http.StatusCreated, inc)
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
+func (s *Server) getIncident(w http.ResponseWriter, r *http.Request) {
+	inc, err := s.incidentProvider().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "incident.get")
+	writeJSON(w, http.StatusOK, inc)
+}
 
-	switch {
-	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
-		var query schema.IncidentQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		incidents, err := s.incident.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.query")
-		writeJSON(w, http.StatusOK, incidents)
-		return true
-	case len(segments) == 1 && r.Method == http.MethodPost:
-		var input schema.CreateIncidentInput
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		inc, err := s.incident.provider.Create(r.Context(), input)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.created")
-		writeJSON(w, http.StatusCreated, inc)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodGet:
-		id := segments[1]
-		inc, err := s.incident.provider.Get(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.get")
-		writeJSON(w, http.StatusOK, inc)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodPatch:
-		id := segments[1]
-		var input schema.UpdateIncidentInput
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		inc, err := s.incident.provider.Update(r.Context(), id, input)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.updated")
-		writeJSON(w, http.StatusOK, inc)
-		return true
-	case len(segments) == 3 && segments[2] == "timeline" && r.Method == http.MethodGet:
-		id := segments[1]
-		timeline, err := s.incident.provider.GetTimeline(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.timeline.get")
-		writeJSON(w, http.StatusOK, timeline)
-		return true
-	case len(segments) == 3 && segments[2] == "timeline" && r.Method == http.MethodPost:
-		id := segments[1]
-		var input schema.TimelineAppendInput
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		if input.At.IsZero() {
-			input.At = time.Now()
-		}
-		if err := s.incident.provider.AppendTimeline(r.Context(), id, input); err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "incident.timeline.appended")
-		writeJSON(w, http.StatusCreated, map[string]string{"status": "ok"})
-		return true
-	default:
-		return false
+func (s *Server) updateIncident(w http.ResponseWriter, r *http.Request) {
+	var input schema.UpdateIncidentInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	inc, err := s.incidentProvider().Update(r.Context(), r.PathValue("id"), input)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "incident.updated")
+	writeJSON(w, http.StatusOK, inc)
+}
+
+func (s *Server) getIncidentTimeline(w http.ResponseWriter, r *http.Request) {
+	timeline, err := s.incidentProvider().GetTimeline(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "incident.timeline.get")
+	writeJSON(w, http.StatusOK, timeline)
+}
+
+func (s *Server) appendIncidentTimeline(w http.ResponseWriter, r *http.Request) {
+	var input schema.TimelineAppendInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	if input.At.IsZero() {
+		input.At = time.Now()
+	}
+	if err := s.incidentProvider().AppendTimeline(r.Context(), r.PathValue("id"), input); err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "incident.timeline.appended")
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "ok"})
 }
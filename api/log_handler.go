@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -14,13 +15,13 @@ type LogHandler struct {
 	provider log.Provider
 }
 
-func newLogHandlerFromEnv(sec SecretProvider) (LogHandler, error) {
+func newLogHandlerFromEnv(sec SecretProvider, host pluginHost) (LogHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "log", "OPSORCH_LOG_PROVIDER", "OPSORCH_LOG_CONFIG", "OPSORCH_LOG_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return LogHandler{}, err
 	}
 	if pluginPath != "" {
-		return LogHandler{provider: newLogPluginProvider(pluginPath, cfg)}, nil
+		return LogHandler{provider: observeLog(pluginPath, newLogPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := log.LookupProvider(name)
 	if !ok {
@@ -30,31 +31,60 @@ func newLogHandlerFromEnv(sec SecretProvider) (LogHandler, error) {
 	if err != nil {
 		return LogHandler{}, err
 	}
-	return LogHandler{provider: provider}, nil
+	return LogHandler{provider: observeLog(name, provider)}, nil
 }
 
-func (s *Server) handleLog(w http.ResponseWriter, r *http.Request) bool {
-	if r.URL.Path != "/logs/query" {
-		return false
-	}
-	if s.log.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "log_provider_missing", Message: "log provider not configured"})
-		return true
-	}
-	if r.Method != http.MethodPost {
-		return false
-	}
+// logProvider returns the log provider currently installed.
+func (s *Server) logProvider() log.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.log.provider
+}
+
+func (s *Server) queryLogs(w http.ResponseWriter, r *http.Request) {
 	var query schema.LogQuery
 	if err := decodeJSON(r, &query); err != nil {
 		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-		return true
+		return
+	}
+	provider := s.logProvider()
+	if streamer, ok := provider.(log.StreamingProvider); ok {
+		streamLogs(w, r, streamer, query)
+		return
 	}
-	results, err := s.log.provider.Query(r.Context(), query)
+	results, err := provider.Query(r.Context(), query)
 	if err != nil {
 		writeProviderError(w, err)
-		return true
+		return
 	}
 	logAudit(r, "log.query")
 	writeJSON(w, http.StatusOK, results)
-	return true
+}
+
+// streamLogs writes {"entries":[...],"url":...} as the provider's batches arrive.
+func streamLogs(w http.ResponseWriter, r *http.Request, streamer log.StreamingProvider, query schema.LogQuery) {
+	out := newJSONArrayStream(w, `{"entries":[`)
+	var url string
+	err := streamer.QueryStream(r.Context(), query, func(batch schema.LogEntries) error {
+		if batch.URL != "" {
+			url = batch.URL
+		}
+		for _, entry := range batch.Entries {
+			if err := out.add(entry); err != nil {
+				return err
+			}
+		}
+		return out.flush()
+	})
+	if err != nil {
+		out.fail(err)
+		return
+	}
+	logAudit(r, "log.query")
+	suffix := "}"
+	if url != "" {
+		encoded, _ := json.Marshal(url)
+		suffix = `,"url":` + string(encoded) + "}"
+	}
+	out.close(suffix)
 }
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// logLevel is the minimum level of application logs. It starts at OPSORCH_LOG_LEVEL and
+// POST /admin/log-level changes it at runtime. Audit records are written regardless of it.
+var logLevel = new(slog.LevelVar)
+
+// auditLog receives audit records; nil until setLogOutput runs, in which case they go to the
+// default logger.
+var auditLog atomic.Pointer[slog.Logger]
+
+// configureLoggingFromEnv sets up JSON logging. Application logs go to stderr at
+// OPSORCH_LOG_LEVEL (default info). Audit records go to OPSORCH_AUDIT_LOG: stderr (the
+// default), stdout, or the path of a file to append to.
+func configureLoggingFromEnv() error {
+	level, err := parseLogLevel(os.Getenv("OPSORCH_LOG_LEVEL"))
+	if err != nil {
+		return fmt.Errorf("invalid OPSORCH_LOG_LEVEL: %w", err)
+	}
+	var audit io.Writer
+	switch dest := strings.TrimSpace(os.Getenv("OPSORCH_AUDIT_LOG")); dest {
+	case "", "stderr":
+		audit = os.Stderr
+	case "stdout":
+		audit = os.Stdout
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("open OPSORCH_AUDIT_LOG: %w", err)
+		}
+		audit = f
+	}
+	logLevel.Set(level)
+	setLogOutput(os.Stderr, audit)
+	return nil
+}
+
+// setLogOutput writes application logs to app and audit records to audit, both as JSON lines.
+// Audit records carry "stream":"audit" so they can be told apart when both share a writer.
+func setLogOutput(app, audit io.Writer) {
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(app, &slog.HandlerOptions{Level: logLevel})}))
+	auditLog.Store(slog.New(slog.NewJSONHandler(audit, nil)).With("stream", "audit"))
+}
+
+func auditLogger() *slog.Logger {
+	if l := auditLog.Load(); l != nil {
+		return l
+	}
+	return slog.Default().With("stream", "audit")
+}
+
+func parseLogLevel(raw string) (slog.Level, error) {
+	var level slog.Level
+	if strings.TrimSpace(raw) == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(strings.TrimSpace(raw)))
+	return level, err
+}
+
+// contextHandler adds the request ID, actor, and capability of the request a record was
+// logged for, so every line about a request can be traced back to it.
+type contextHandler struct{ slog.Handler }
+
+func (h contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if info := requestInfoFrom(ctx); info != nil {
+		rec.AddAttrs(slog.String("request_id", info.requestID), slog.String("actor_type", info.actorType), slog.String("actor_id", info.actorID))
+		if info.capability != "" {
+			rec.AddAttrs(slog.String("capability", info.capability))
+		}
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestContext returns the context of the request w answers. Helpers that only hold the
+// writer log with it so their lines still carry the request's ID, actor, and capability.
+func requestContext(w http.ResponseWriter) context.Context {
+	if rec, ok := w.(*statusRecorder); ok && rec.ctx != nil {
+		return rec.ctx
+	}
+	return context.Background()
+}
+
+// logLevelRequest is the body of POST /admin/log-level.
+type logLevelRequest struct {
+	Level string `json:"level"` // debug, info, warn, or error
+}
+
+// logLevelResponse is the body of GET and POST /admin/log-level.
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, logLevelResponse{Level: strings.ToLower(logLevel.Level().String())})
+}
+
+// handleSetLogLevel changes the level of application logs until the next restart.
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	level, err := parseLogLevel(req.Level)
+	if err != nil || strings.TrimSpace(req.Level) == "" {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: fmt.Sprintf("unknown log level %q; use debug, info, warn, or error", req.Level)})
+		return
+	}
+	previous := logLevel.Level()
+	logLevel.Set(level)
+	logAuditDetails(r, "admin.log_level_changed", map[string]string{"from": strings.ToLower(previous.String()), "to": strings.ToLower(level.String())})
+	writeJSON(w, http.StatusOK, logLevelResponse{Level: strings.ToLower(level.String())})
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logRecords decodes the JSON lines written to logs.
+func logRecords(t *testing.T, logs *syncBuffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// resetLogLevel restores the application log level after a test changes it.
+func resetLogLevel(t *testing.T) {
+	previous := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(previous) })
+}
+
+func TestLogLinesCarryRequestContext(t *testing.T) {
+	logs := captureLog(t)
+	srv := &Server{incident: IncidentHandler{provider: observeIncident("stub", missingIncidentProvider{})}}
+	req := httptest.NewRequest(http.MethodGet, "/incidents/INC-404", nil)
+	req.Header.Set("X-Actor-ID", "alice")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	records := logRecords(t, logs)
+	if len(records) != 1 {
+		t.Fatalf("expected one log line, got %q", logs.String())
+	}
+	rec := records[0]
+	want := map[string]any{
+		"level": "INFO", "msg": "api_error", "code": "not_found",
+		"request_id": w.Header().Get("X-Request-ID"), "actor_type": "user", "actor_id": "alice", "capability": "incident",
+	}
+	for key, value := range want {
+		if rec[key] != value {
+			t.Fatalf("expected %s=%v, got %+v", key, value, rec)
+		}
+	}
+}
+
+func TestAuditRecordsUseTheirOwnStream(t *testing.T) {
+	logs := captureLog(t)
+	resetLogLevel(t)
+	logLevel.Set(slog.LevelError)
+	srv := &Server{log: LogHandler{provider: observeLog("stub", stubLogProvider{})}}
+	req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`))
+	req.Header.Set("X-Request-ID", "req-42")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tickets/1", nil))
+
+	// The 501 is logged at error level; the audit record is written although it is info.
+	records := logRecords(t, logs)
+	if len(records) != 2 {
+		t.Fatalf("expected an audit record and an error, got %q", logs.String())
+	}
+	audit := records[0]
+	if audit["msg"] != "audit_log" || audit["stream"] != "audit" || audit["request_id"] != "req-42" || audit["capability"] != "log" || audit["action"] != "log.query" {
+		t.Fatalf("unexpected audit record %+v", audit)
+	}
+	if records[1]["msg"] != "api_error" || records[1]["stream"] != nil {
+		t.Fatalf("expected an application line, got %+v", records[1])
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	logs := captureLog(t)
+	resetLogLevel(t)
+	logLevel.Set(slog.LevelInfo)
+	srv := &Server{}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"level":"info"}` {
+		t.Fatalf("expected the current level, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/log-level", strings.NewReader(`{"level":"debug"}`)))
+	if w.Code != http.StatusOK || logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("expected the level to change, got %d %s", w.Code, w.Body.String())
+	}
+	if out := logs.String(); !strings.Contains(out, `"action":"admin.log_level_changed","details":{"from":"info","to":"debug"}`) {
+		t.Fatalf("expected the change to be audited, got %q", out)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/log-level", strings.NewReader(`{"level":"verbose"}`)))
+	if w.Code != http.StatusBadRequest || logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("expected an unknown level to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAdminLogLevelRequiresAdminWrite(t *testing.T) {
+	resetLogLevel(t)
+	srv := &Server{tokens: testTokenStore(t)}
+	if w := serveWithToken(srv, http.MethodPost, "/admin/log-level", "dash-secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected admin:write to be required, got %d", w.Code)
+	}
+}
+
+func TestConfigureLoggingFromEnv(t *testing.T) {
+	captureLog(t)
+	resetLogLevel(t)
+	t.Setenv("OPSORCH_LOG_LEVEL", "loud")
+	if err := configureLoggingFromEnv(); err == nil {
+		t.Fatalf("expected an unknown level to be rejected")
+	}
+
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("OPSORCH_LOG_LEVEL", "warn")
+	t.Setenv("OPSORCH_AUDIT_LOG", auditFile)
+	if err := configureLoggingFromEnv(); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if logLevel.Level() != slog.LevelWarn {
+		t.Fatalf("expected warn, got %s", logLevel.Level())
+	}
+	logSystemAudit("test.audited", nil)
+	got, err := os.ReadFile(auditFile)
+	if err != nil || !strings.Contains(string(got), `"stream":"audit"`) || !strings.Contains(string(got), `"action":"test.audited"`) {
+		t.Fatalf("expected the audit record in %s, got %q (err=%v)", auditFile, got, err)
+	}
+}
@@ -14,13 +14,13 @@ type MessagingHandler struct {
 	provider messaging.Provider
 }
 
-func newMessagingHandlerFromEnv(sec SecretProvider) (MessagingHandler, error) {
+func newMessagingHandlerFromEnv(sec SecretProvider, host pluginHost) (MessagingHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "messaging", "OPSORCH_MESSAGING_PROVIDER", "OPSORCH_MESSAGING_CONFIG", "OPSORCH_MESSAGING_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return MessagingHandler{}, err
 	}
 	if pluginPath != "" {
-		return MessagingHandler{provider: newMessagingPluginProvider(pluginPath, cfg)}, nil
+		return MessagingHandler{provider: observeMessaging(pluginPath, newMessagingPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := messaging.LookupProvider(name)
 	if !ok {
@@ -30,31 +30,27 @@ func newMessagingHandlerFromEnv(sec SecretProvider) (MessagingHandler, error) {
 	if err != nil {
 		return MessagingHandler{}, err
 	}
-	return MessagingHandler{provider: provider}, nil
+	return MessagingHandler{provider: observeMessaging(name, provider)}, nil
 }
 
-func (s *Server) handleMessaging(w http.ResponseWriter, r *http.Request) bool {
-	if r.URL.Path != "/messages/send" {
-		return false
-	}
-	if s.messaging.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "messaging_provider_missing", Message: "messaging provider not configured"})
-		return true
-	}
-	if r.Method != http.MethodPost {
-		return false
-	}
+// messagingProvider returns the messaging provider currently installed.
+func (s *Server) messagingProvider() messaging.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.messaging.provider
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
 	var msg schema.Message
 	if err := decodeJSON(r, &msg); err != nil {
 		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-		return true
+		return
 	}
-	res, err := s.messaging.provider.Send(r.Context(), msg)
+	res, err := s.messagingProvider().Send(r.Context(), msg)
 	if err != nil {
 		writeProviderError(w, err)
-		return true
+		return
 	}
 	logAudit(r, "message.sent")
 	writeJSON(w, http.StatusOK, res)
-	return true
 }
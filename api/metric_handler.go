@@ -14,13 +14,13 @@ type MetricHandler struct {
 	provider metric.Provider
 }
 
-func newMetricHandlerFromEnv(sec SecretProvider) (MetricHandler, error) {
+func newMetricHandlerFromEnv(sec SecretProvider, host pluginHost) (MetricHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "metric", "OPSORCH_METRIC_PROVIDER", "OPSORCH_METRIC_CONFIG", "OPSORCH_METRIC_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return MetricHandler{}, err
 	}
 	if pluginPath != "" {
-		return MetricHandler{provider: newMetricPluginProvider(pluginPath, cfg)}, nil
+		return MetricHandler{provider: observeMetric(pluginPath, newMetricPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := metric.LookupProvider(name)
 	if !ok {
@@ -30,55 +30,74 @@ func newMetricHandlerFromEnv(sec SecretProvider) (MetricHandler, error) {
 	if err != nil {
 		return MetricHandler{}, err
 	}
-	return MetricHandler{provider: provider}, nil
+	return MetricHandler{provider: observeMetric(name, provider)}, nil
 }
 
-func (s *Server) handleMetric(w http.ResponseWriter, r *http.Request) bool {
-	if r.URL.Path != "/metrics/query" && r.URL.Path != "/metrics/describe" {
-		return false
+// metricProvider returns the metric provider currently installed.
+func (s *Server) metricProvider() metric.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.metric.provider
+}
+
+func (s *Server) queryMetrics(w http.ResponseWriter, r *http.Request) {
+	var query schema.MetricQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if s.metric.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "metric_provider_missing", Message: "metric provider not configured"})
-		return true
+	provider := s.metricProvider()
+	if streamer, ok := provider.(metric.StreamingProvider); ok {
+		streamMetrics(w, r, streamer, query)
+		return
+	}
+	results, err := provider.Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "metric.query")
+	writeJSON(w, http.StatusOK, results)
+}
 
-	switch {
-	case r.URL.Path == "/metrics/query" && r.Method == http.MethodPost:
-		var query schema.MetricQuery
-		if err := decodeJSON(r, &query); err != nil {
+// describeMetrics takes the scope as a JSON body on POST and as query parameters on GET.
+func (s *Server) describeMetrics(w http.ResponseWriter, r *http.Request) {
+	var scope schema.QueryScope
+	if r.Method == http.MethodPost {
+		if err := decodeJSON(r, &scope); err != nil {
 			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		results, err := s.metric.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "metric.query")
-		writeJSON(w, http.StatusOK, results)
-		return true
-	case r.URL.Path == "/metrics/describe" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
-		var scope schema.QueryScope
-		if r.Method == http.MethodPost {
-			if err := decodeJSON(r, &scope); err != nil {
-				writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-				return true
-			}
-		} else {
-			scope.Service = r.URL.Query().Get("service")
-			scope.Environment = r.URL.Query().Get("environment")
-			scope.Team = r.URL.Query().Get("team")
+			return
 		}
+	} else {
+		scope.Service = r.URL.Query().Get("service")
+		scope.Environment = r.URL.Query().Get("environment")
+		scope.Team = r.URL.Query().Get("team")
+	}
 
-		descriptors, err := s.metric.provider.Describe(r.Context(), scope)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
+	descriptors, err := s.metricProvider().Describe(r.Context(), scope)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "metric.describe")
+	writeJSON(w, http.StatusOK, map[string]any{"metrics": descriptors})
+}
+
+// streamMetrics writes the series array as the provider's batches arrive.
+func streamMetrics(w http.ResponseWriter, r *http.Request, streamer metric.StreamingProvider, query schema.MetricQuery) {
+	out := newJSONArrayStream(w, "[")
+	err := streamer.QueryStream(r.Context(), query, func(batch []schema.MetricSeries) error {
+		for _, series := range batch {
+			if err := out.add(series); err != nil {
+				return err
+			}
 		}
-		logAudit(r, "metric.describe")
-		writeJSON(w, http.StatusOK, map[string]any{"metrics": descriptors})
-		return true
-	default:
-		return false
+		return out.flush()
+	})
+	if err != nil {
+		out.fail(err)
+		return
 	}
+	logAudit(r, "metric.query")
+	out.close("")
 }
@@ -0,0 +1,498 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSCacheTTL = time.Hour
+	// jwksMinRefresh limits how often an unknown key ID or a failed fetch triggers another fetch.
+	jwksMinRefresh = 30 * time.Second
+	// oidcClockSkew is the leeway allowed when checking exp and nbf.
+	oidcClockSkew = time.Minute
+)
+
+// oidcVerifier authenticates JWT access tokens issued by an OIDC provider and maps their
+// claims onto a principal.
+type oidcVerifier struct {
+	issuer         string
+	audience       string
+	actorClaim     string
+	actorTypeClaim string // empty means every caller is a "user"
+	groupsClaim    string
+	groupScopes    map[string][]string // "*" applies to every caller
+	keys           *jwks
+}
+
+// newOIDCVerifierFromEnv configures JWT authentication from the OPSORCH_OIDC_* variables. It
+// returns nil when OIDC is not configured.
+func newOIDCVerifierFromEnv() (*oidcVerifier, error) {
+	issuer := strings.TrimSpace(os.Getenv("OPSORCH_OIDC_ISSUER"))
+	audience := strings.TrimSpace(os.Getenv("OPSORCH_OIDC_AUDIENCE"))
+	jwksURL := strings.TrimSpace(os.Getenv("OPSORCH_OIDC_JWKS_URL"))
+	jwksFile := strings.TrimSpace(os.Getenv("OPSORCH_OIDC_JWKS_FILE"))
+	if issuer == "" && audience == "" && jwksURL == "" && jwksFile == "" {
+		return nil, nil
+	}
+	if issuer == "" || audience == "" {
+		return nil, fmt.Errorf("OPSORCH_OIDC_ISSUER and OPSORCH_OIDC_AUDIENCE must be set together")
+	}
+
+	v := &oidcVerifier{
+		issuer:         issuer,
+		audience:       audience,
+		actorClaim:     envOr("OPSORCH_OIDC_ACTOR_CLAIM", "sub"),
+		actorTypeClaim: strings.TrimSpace(os.Getenv("OPSORCH_OIDC_ACTOR_TYPE_CLAIM")),
+		groupsClaim:    envOr("OPSORCH_OIDC_GROUPS_CLAIM", "groups"),
+	}
+	if raw := strings.TrimSpace(os.Getenv("OPSORCH_OIDC_GROUP_SCOPES")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &v.groupScopes); err != nil {
+			return nil, fmt.Errorf("invalid OPSORCH_OIDC_GROUP_SCOPES: %w", err)
+		}
+		for group, scopes := range v.groupScopes {
+			for _, scope := range scopes {
+				if !validScope(scope) {
+					return nil, fmt.Errorf("OPSORCH_OIDC_GROUP_SCOPES: group %s: invalid scope %q", group, scope)
+				}
+			}
+		}
+	}
+
+	switch {
+	case jwksURL != "" && jwksFile != "":
+		return nil, fmt.Errorf("set only one of OPSORCH_OIDC_JWKS_URL and OPSORCH_OIDC_JWKS_FILE")
+	case jwksURL != "":
+		v.keys = &jwks{
+			url:    jwksURL,
+			ttl:    envDuration("OPSORCH_OIDC_JWKS_CACHE_TTL", defaultJWKSCacheTTL),
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	case jwksFile != "":
+		raw, err := os.ReadFile(jwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("read OPSORCH_OIDC_JWKS_FILE: %w", err)
+		}
+		keys, err := parseJWKS(raw)
+		if err != nil {
+			return nil, fmt.Errorf("OPSORCH_OIDC_JWKS_FILE: %w", err)
+		}
+		v.keys = &jwks{keys: keys}
+	default:
+		return nil, fmt.Errorf("OPSORCH_OIDC_JWKS_URL or OPSORCH_OIDC_JWKS_FILE is required with OPSORCH_OIDC_ISSUER")
+	}
+	return v, nil
+}
+
+func envOr(envVar, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(envVar)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// looksLikeJWT reports whether token has the three dot-separated segments of a compact JWS.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verify checks the token's signature, issuer, audience, and validity window, and returns the
+// principal described by its claims.
+func (v *oidcVerifier) verify(ctx context.Context, token string) (*principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed JWT signature")
+	}
+	key, err := v.keys.key(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return nil, fmt.Errorf("JWT issuer %q is not trusted", iss)
+	}
+	if !slices.Contains(claimStrings(claims["aud"]), v.audience) {
+		return nil, fmt.Errorf("JWT audience does not include %s", v.audience)
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("JWT has no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("JWT expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("JWT is not valid yet")
+	}
+
+	actorID, _ := claimValue(claims, v.actorClaim).(string)
+	if actorID == "" {
+		return nil, fmt.Errorf("JWT has no %s claim", v.actorClaim)
+	}
+	p := &principal{name: "oidc", actorID: actorID, actorType: "user"}
+	if v.actorTypeClaim != "" {
+		if typ, _ := claimValue(claims, v.actorTypeClaim).(string); typ != "" {
+			p.actorType = strings.ToLower(typ)
+		}
+	}
+	p.groups = claimStrings(claimValue(claims, v.groupsClaim))
+	p.scopes = append(p.scopes, v.groupScopes["*"]...)
+	for _, group := range p.groups {
+		p.scopes = append(p.scopes, v.groupScopes[group]...)
+	}
+	return p, nil
+}
+
+func decodeSegment(seg string, into any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, into)
+}
+
+// claimValue looks up a claim by name, falling back to a dotted path into nested objects
+// (for example "realm_access.roles").
+func claimValue(claims map[string]any, name string) any {
+	if v, ok := claims[name]; ok {
+		return v
+	}
+	var cur any = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// claimStrings reads a claim that may be a single string or an array of strings.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hash, ok := map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+		"EdDSA": 0,
+	}[alg]
+	if !ok {
+		return fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+	errInvalid := errors.New("invalid JWT signature")
+	if alg == "EdDSA" {
+		if !ed25519.Verify(key.(ed25519.PublicKey), signed, sig) {
+			return errInvalid
+		}
+		return nil
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		if alg[0] == 'P' {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		}
+		if err != nil {
+			return errInvalid
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errInvalid
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errInvalid
+		}
+	}
+	return nil
+}
+
+// jwks is a JSON Web Key Set. Keys loaded from a URL are cached for ttl and refetched early
+// when a token names a key ID the cache does not have, so signing key rotation is picked up.
+type jwks struct {
+	url    string // empty for a set loaded from a file
+	ttl    time.Duration
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      []jsonWebKey
+	expires   time.Time     // when the cached set goes stale
+	lastFetch time.Time     // last fetch attempt, successful or not
+	fetching  chan struct{} // closed when the fetch in flight finishes; nil when none is
+	fetchErr  error         // outcome of the last fetch
+}
+
+type jsonWebKey struct {
+	kid string
+	alg string // empty when the key does not restrict its algorithm
+	key crypto.PublicKey
+}
+
+// key returns the public key for kid that can verify alg. A token without a kid matches any
+// compatible key.
+func (k *jwks) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	if k.url != "" {
+		if err := k.refresh(ctx, kid, alg); err != nil {
+			return nil, err
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.find(kid, alg); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no JWKS key for kid %q and alg %s", kid, alg)
+}
+
+func (k *jwks) find(kid, alg string) (crypto.PublicKey, bool) {
+	for _, jwk := range k.keys {
+		if (kid == "" || jwk.kid == kid) && (jwk.alg == "" || jwk.alg == alg) && keyFitsAlg(jwk.key, alg) {
+			return jwk.key, true
+		}
+	}
+	return nil, false
+}
+
+// refresh refetches the key set when it is stale or lacks kid, and waits for the fetch. One
+// fetch runs at a time, without holding k.mu and on its own context, so callers that need it
+// share it and a caller that gives up does not cancel it for the others.
+func (k *jwks) refresh(ctx context.Context, kid, alg string) error {
+	k.mu.Lock()
+	now := time.Now()
+	_, known := k.find(kid, alg)
+	stale := now.After(k.expires) || (!known && now.Sub(k.lastFetch) >= jwksMinRefresh)
+	if !stale && (known || k.fetching == nil) {
+		k.mu.Unlock()
+		return nil
+	}
+	if k.fetching == nil {
+		k.fetching = make(chan struct{})
+		k.lastFetch = now
+		k.expires = now.Add(jwksMinRefresh)
+		go k.fetch(k.fetching)
+	}
+	done := k.fetching
+	k.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys == nil {
+		return k.fetchErr
+	}
+	return nil
+}
+
+// fetch downloads the key set and swaps it in. A failed fetch is not retried for
+// jwksMinRefresh; until then the keys already cached keep verifying tokens.
+func (k *jwks) fetch(done chan struct{}) {
+	keys, err := k.download(context.Background())
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err == nil {
+		k.keys = keys
+		k.expires = k.lastFetch.Add(k.ttl)
+	} else if k.keys != nil {
+		slog.Error("oidc_jwks_error", "url", k.url, "err", err)
+	}
+	k.fetchErr = err
+	k.fetching = nil
+	close(done)
+}
+
+func (k *jwks) download(ctx context.Context) ([]jsonWebKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return parseJWKS(raw)
+}
+
+// parseJWKS decodes the signing keys of a JWK set. Encryption keys and key types it does not
+// understand are skipped.
+func parseJWKS(raw []byte) ([]jsonWebKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	var keys []jsonWebKey
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k.N, k.E)
+		case "EC":
+			key, err = ecKey(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = edKey(k.Crv, k.X)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %q: %w", k.Kid, err)
+		}
+		keys = append(keys, jsonWebKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil || len(nb) == 0 {
+		return nil, errors.New("invalid RSA modulus")
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil || len(eb) == 0 || len(eb) > 4 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(new(big.Int).SetBytes(eb).Int64())}, nil
+}
+
+var jwkCurves = map[string]struct {
+	curve elliptic.Curve
+	ecdh  ecdh.Curve
+}{
+	"P-256": {elliptic.P256(), ecdh.P256()},
+	"P-384": {elliptic.P384(), ecdh.P384()},
+	"P-521": {elliptic.P521(), ecdh.P521()},
+}
+
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	c, ok := jwkCurves[crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	xb, errX := base64.RawURLEncoding.DecodeString(x)
+	yb, errY := base64.RawURLEncoding.DecodeString(y)
+	size := (c.curve.Params().BitSize + 7) / 8
+	if errX != nil || errY != nil || len(xb) != size || len(yb) != size {
+		return nil, errors.New("invalid EC coordinates")
+	}
+	// crypto/ecdh rejects points that are not on the curve.
+	if _, err := c.ecdh.NewPublicKey(append(append([]byte{4}, xb...), yb...)); err != nil {
+		return nil, errors.New("EC point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: c.curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}, nil
+}
+
+func edKey(crv, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil || len(xb) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 key")
+	}
+	return ed25519.PublicKey(xb), nil
+}
+
+// keyFitsAlg reports whether key can verify alg, so a token cannot pick an algorithm the key
+// was not made for.
+func keyFitsAlg(key crypto.PublicKey, alg string) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		want := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}[alg]
+		return want != "" && key.Curve.Params().Name == want
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+type testSigner struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{rsa: rk, ec: ek}
+}
+
+func (s *testSigner) jwks() string {
+	pad := func(b []byte) []byte { return append(make([]byte, 32-len(b)), b...) }
+	return fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q}
+	]}`,
+		b64.EncodeToString(s.rsa.N.Bytes()), b64.EncodeToString(big.NewInt(int64(s.rsa.E)).Bytes()),
+		b64.EncodeToString(pad(s.ec.X.Bytes())), b64.EncodeToString(pad(s.ec.Y.Bytes())))
+}
+
+// sign returns a compact JWT signed with RS256 (kid "rsa-1") or ES256 (kid "ec-1").
+func (s *testSigner) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, ss *big.Int
+		r, ss, err = ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":    "https://idp.example.com",
+		"aud":    []string{"opsorch", "other"},
+		"sub":    "u-123",
+		"email":  "alice@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"sre", "eng"},
+		"realm":  map[string]any{"kind": "Bot"},
+	}
+}
+
+func setOIDCEnv(t *testing.T, jwks string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(jwks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPSORCH_OIDC_ISSUER", "https://idp.example.com")
+	t.Setenv("OPSORCH_OIDC_AUDIENCE", "opsorch")
+	t.Setenv("OPSORCH_OIDC_JWKS_FILE", path)
+}
+
+func TestOIDCVerifiesSignatureAndClaims(t *testing.T) {
+	signer := newTestSigner(t)
+	setOIDCEnv(t, signer.jwks())
+	v, err := newOIDCVerifierFromEnv()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa-1", "ES256": "ec-1"}[alg]
+		p, err := v.verify(context.Background(), signer.sign(t, alg, kid, validClaims()))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if p.actorID != "u-123" || p.actorType != "user" || strings.Join(p.groups, ",") != "sre,eng" {
+			t.Fatalf("%s: unexpected principal %+v", alg, p)
+		}
+	}
+
+	mutate := func(k string, v any) map[string]any {
+		c := validClaims()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+	cases := map[string]string{
+		signer.sign(t, "RS256", "rsa-1", mutate("iss", "https://evil.example.com")):                 "issuer",
+		signer.sign(t, "RS256", "rsa-1", mutate("aud", "someone-else")):                             "audience",
+		signer.sign(t, "RS256", "rsa-1", mutate("exp", time.Now().Add(-time.Hour).Unix())):          "expired",
+		signer.sign(t, "RS256", "rsa-1", mutate("exp", nil)):                                        "no exp",
+		signer.sign(t, "RS256", "rsa-1", mutate("nbf", time.Now().Add(time.Hour).Unix())):           "not valid yet",
+		signer.sign(t, "RS256", "rsa-1", mutate("sub", nil)):                                        "no sub",
+		signer.sign(t, "RS256", "unknown", validClaims()):                                           "no JWKS key",
+		signer.sign(t, "ES256", "rsa-1", validClaims()):                                             "no JWKS key",
+		signer.sign(t, "RS256", "rsa-1", validClaims())[:40] + "x.y.z":                              "malformed",
+		strings.Replace(signer.sign(t, "RS256", "rsa-1", validClaims()), ".", ".e30K", 1):           "signature",
+		b64.EncodeToString([]byte(`{"alg":"none"}`)) + "." + b64.EncodeToString([]byte(`{}`)) + ".": "no JWKS key",
+	}
+	for token, want := range cases {
+		if _, err := v.verify(context.Background(), token); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error containing %q, got %v", want, err)
+		}
+	}
+
+	tampered := signer.sign(t, "RS256", "rsa-1", validClaims())
+	parts := strings.Split(tampered, ".")
+	forged, _ := json.Marshal(mutate("sub", "admin"))
+	if _, err := v.verify(context.Background(), parts[0]+"."+b64.EncodeToString(forged)+"."+parts[2]); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected a signature error for altered claims, got %v", err)
+	}
+}
+
+func TestOIDCClaimMappingGatesRoutesAndAudit(t *testing.T) {
+	signer := newTestSigner(t)
+	setOIDCEnv(t, signer.jwks())
+	t.Setenv("OPSORCH_OIDC_ACTOR_CLAIM", "email")
+	t.Setenv("OPSORCH_OIDC_ACTOR_TYPE_CLAIM", "realm.kind")
+	t.Setenv("OPSORCH_OIDC_GROUP_SCOPES", `{"*":["incident:read"],"sre":["incident:write"],"admins":["*"]}`)
+	v, err := newOIDCVerifierFromEnv()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	srv := &Server{oidc: v, incident: IncidentHandler{provider: stubIncidentProvider{}}, ticket: TicketHandler{provider: stubTicketProvider{}}}
+
+	logs := captureLog(t)
+	sre := signer.sign(t, "ES256", "ec-1", validClaims())
+	if w := serveWithToken(srv, http.MethodPost, "/incidents", sre); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	out := logs.String()
+	if !strings.Contains(out, `"actor_type":"bot","actor_id":"alice@example.com"`) || !strings.Contains(out, `"groups":"sre,eng"`) {
+		t.Fatalf("expected the token identity in the audit log, got %q", out)
+	}
+	if w := serveWithToken(srv, http.MethodGet, "/tickets/1", sre); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a ticket scope, got %d", w.Code)
+	}
+
+	viewer := validClaims()
+	viewer["groups"] = "eng"
+	token := signer.sign(t, "RS256", "rsa-1", viewer)
+	if w := serveWithToken(srv, http.MethodGet, "/incidents/1", token); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from the default scopes, got %d", w.Code)
+	}
+	if w := serveWithToken(srv, http.MethodPost, "/incidents", token); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+	if w := serveWithToken(srv, http.MethodGet, "/incidents/1", "not-a-jwt"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestOIDCJWKSFromURLIsCached(t *testing.T) {
+	signer := newTestSigner(t)
+	var fetches atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(signer.jwks()))
+	}))
+	defer idp.Close()
+
+	t.Setenv("OPSORCH_OIDC_ISSUER", "https://idp.example.com")
+	t.Setenv("OPSORCH_OIDC_AUDIENCE", "opsorch")
+	t.Setenv("OPSORCH_OIDC_JWKS_URL", idp.URL)
+	v, err := newOIDCVerifierFromEnv()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	token := signer.sign(t, "RS256", "rsa-1", validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := v.verify(context.Background(), token); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+	// An unknown kid refetches at most once per jwksMinRefresh.
+	for i := 0; i < 3; i++ {
+		v.verify(context.Background(), signer.sign(t, "RS256", "rotated", validClaims()))
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected one JWKS fetch, got %d", n)
+	}
+
+	v.keys.expires = time.Now().Add(-time.Second)
+	idp.Close()
+	if _, err := v.verify(context.Background(), token); err != nil {
+		t.Fatalf("expected cached keys to be used while the provider is down, got %v", err)
+	}
+}
+
+func TestOIDCJWKSFetchIsShared(t *testing.T) {
+	signer := newTestSigner(t)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		w.Write([]byte(signer.jwks()))
+	}))
+	defer idp.Close()
+
+	t.Setenv("OPSORCH_OIDC_ISSUER", "https://idp.example.com")
+	t.Setenv("OPSORCH_OIDC_AUDIENCE", "opsorch")
+	t.Setenv("OPSORCH_OIDC_JWKS_URL", idp.URL)
+	v, err := newOIDCVerifierFromEnv()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	token := signer.sign(t, "RS256", "rsa-1", validClaims())
+
+	// The first caller gives up while the fetch is in flight; the fetch carries on for the rest.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := v.verify(ctx, token); err == nil {
+		t.Fatalf("expected the caller's deadline to end its wait")
+	}
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := v.verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	close(release)
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected one shared JWKS fetch, got %d", n)
+	}
+}
+
+func TestOIDCConfigErrors(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"OPSORCH_OIDC_ISSUER": "x"}, "must be set together"},
+		{map[string]string{"OPSORCH_OIDC_ISSUER": "x", "OPSORCH_OIDC_AUDIENCE": "y"}, "is required"},
+		{map[string]string{"OPSORCH_OIDC_ISSUER": "x", "OPSORCH_OIDC_AUDIENCE": "y", "OPSORCH_OIDC_JWKS_URL": "http://a", "OPSORCH_OIDC_JWKS_FILE": "/b"}, "only one"},
+		{map[string]string{"OPSORCH_OIDC_ISSUER": "x", "OPSORCH_OIDC_AUDIENCE": "y", "OPSORCH_OIDC_JWKS_URL": "http://a", "OPSORCH_OIDC_GROUP_SCOPES": `{"sre":["incident:delete"]}`}, "invalid scope"},
+		{map[string]string{"OPSORCH_OIDC_ISSUER": "x", "OPSORCH_OIDC_AUDIENCE": "y", "OPSORCH_OIDC_JWKS_FILE": "/does/not/exist"}, "OPSORCH_OIDC_JWKS_FILE"},
+	}
+	for _, tc := range cases {
+		for _, k := range []string{"OPSORCH_OIDC_ISSUER", "OPSORCH_OIDC_AUDIENCE", "OPSORCH_OIDC_JWKS_URL", "OPSORCH_OIDC_JWKS_FILE", "OPSORCH_OIDC_GROUP_SCOPES"} {
+			t.Setenv(k, tc.env[k])
+		}
+		if _, err := newOIDCVerifierFromEnv(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%v: expected error containing %q, got %v", tc.env, tc.want, err)
+		}
+	}
+	if _, err := parseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AAAA","y":"AAAA"}]}`)); err == nil {
+		t.Fatalf("expected invalid EC coordinates to be rejected")
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// Response bodies that handlers write as maps, named here so the OpenAPI document can describe them.
+type (
+	statusResponse struct {
+		Status string `json:"status"`
+	}
+	providerListResponse struct {
+		Providers []string `json:"providers"`
+	}
+	metricDescribeResponse struct {
+		Metrics []schema.MetricDescriptor `json:"metrics"`
+	}
+)
+
+// typeEnums lists the values of string types that are enumerations.
+var typeEnums = map[reflect.Type][]string{
+	reflect.TypeOf(schema.BlockType("")): {string(schema.BlockTypeHeader), string(schema.BlockTypeSection), string(schema.BlockTypeDivider)},
+}
+
+// fieldEnums lists the documented values of plain string fields, by struct type and field name.
+var fieldEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeOf(schema.OrchestrationStep{}): {"Type": {schema.StepTypeManual, schema.StepTypeAutomated}},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// OpenAPI returns the OpenAPI 3.1 document for the HTTP API, built from the route table and the
+// Go types of each route's request and response bodies.
+func OpenAPI() map[string]any {
+	b := &openAPIBuilder{schemas: make(map[string]any)}
+	errorRef := b.schemaFor(reflect.TypeOf(errorResponse{}))
+
+	table := apiRoutes()
+	handlers := make(map[string]int)
+	for _, rt := range table {
+		handlers[handlerName(rt)]++
+	}
+	paths := make(map[string]any)
+	for _, rt := range table {
+		item, _ := paths[rt.Pattern].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[rt.Pattern] = item
+		}
+		op := b.operation(rt)
+		op["operationId"] = handlerName(rt)
+		if handlers[handlerName(rt)] > 1 {
+			op["operationId"] = handlerName(rt) + titleCase(strings.ToLower(rt.Method))
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "OpsOrch Core API",
+			"version": "1.0.0",
+		},
+		"paths":    paths,
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+			"schemas": b.schemas,
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "Error",
+					"content":     jsonContent(errorRef),
+				},
+			},
+		},
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, OpenAPI())
+}
+
+// openAPIBuilder collects named struct types into components as operations reference them.
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openAPIBuilder) operation(rt route) map[string]any {
+	tag := rt.Capability
+	if tag == "" {
+		tag = "core"
+	}
+	op := map[string]any{"tags": []string{tag}}
+	if rt.Action != "" {
+		op["x-opsorch-action"] = rt.Action
+	}
+	if rt.Scope != "" {
+		op["x-opsorch-scope"] = rt.Scope
+	}
+	if unauthenticatedPaths[rt.Pattern] {
+		op["security"] = []any{}
+	}
+
+	var params []any
+	for _, seg := range splitPath(rt.Pattern) {
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			params = append(params, map[string]any{
+				"name":     strings.TrimSuffix(name, "}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+	}
+	if rt.request != nil {
+		t := reflect.TypeOf(rt.request)
+		if rt.Method == http.MethodGet {
+			for _, f := range jsonFields(t) {
+				params = append(params, map[string]any{"name": f.name, "in": "query", "schema": b.fieldSchema(t, f)})
+			}
+		} else {
+			op["requestBody"] = map[string]any{"required": true, "content": jsonContent(b.schemaFor(t))}
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	content := jsonContent(b.schemaFor(reflect.TypeOf(rt.response)))
+	if _, ok := rt.response.(prometheusText); ok {
+		content = map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	op["responses"] = map[string]any{
+		strconv.Itoa(rt.status): map[string]any{
+			"description": http.StatusText(rt.status),
+			"content":     content,
+		},
+		"default": map[string]any{"$ref": "#/components/responses/Error"},
+	}
+	return op
+}
+
+// schemaFor returns the JSON Schema for t, as encoding/json would encode it. Named structs and
+// enumerations become components and are returned as references.
+func (b *openAPIBuilder) schemaFor(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schemaFor(t.Elem())
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		if values, ok := typeEnums[t]; ok {
+			return b.component(t, func() map[string]any { return map[string]any{"type": "string", "enum": values} })
+		}
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.objectSchema(t)
+		}
+		return b.component(t, func() map[string]any { return b.objectSchema(t) })
+	default:
+		return map[string]any{}
+	}
+}
+
+// component registers t under its exported name, building it once, and returns a reference.
+func (b *openAPIBuilder) component(t reflect.Type, build func() map[string]any) map[string]any {
+	name := titleCase(t.Name())
+	if _, ok := b.schemas[name]; !ok {
+		b.schemas[name] = map[string]any{} // placeholder so recursive types terminate
+		b.schemas[name] = build()
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *openAPIBuilder) objectSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for _, f := range jsonFields(t) {
+		properties[f.name] = b.fieldSchema(t, f)
+		if !f.omitempty {
+			required = append(required, f.name)
+		}
+	}
+	out := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+func (b *openAPIBuilder) fieldSchema(owner reflect.Type, f jsonField) map[string]any {
+	if values, ok := fieldEnums[owner][f.field.Name]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+	return b.schemaFor(f.field.Type)
+}
+
+type jsonField struct {
+	field     reflect.StructField
+	name      string
+	omitempty bool
+}
+
+// jsonFields lists the exported fields of struct type t under their json names.
+func jsonFields(t reflect.Type) []jsonField {
+	var out []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, jsonField{field: f, name: name, omitempty: strings.Contains(","+opts+",", ",omitempty,")})
+	}
+	return out
+}
+
+// handlerName is the name of the Server method that serves rt.
+func handlerName(rt route) string {
+	name := runtime.FuncForPC(reflect.ValueOf(rt.handle).Pointer()).Name()
+	return strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+}
+
+func titleCase(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fetchOpenAPI(t *testing.T) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	(&Server{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	doc := fetchOpenAPI(t)
+	if doc["openapi"] != "3.1.0" {
+		t.Fatalf("unexpected openapi version %v", doc["openapi"])
+	}
+	paths := doc["paths"].(map[string]any)
+	operationIDs := make(map[string]bool)
+	for _, rt := range Routes() {
+		item, ok := paths[rt.Pattern].(map[string]any)
+		if !ok {
+			t.Fatalf("path %s missing", rt.Pattern)
+		}
+		op, ok := item[strings.ToLower(rt.Method)].(map[string]any)
+		if !ok {
+			t.Fatalf("%s %s missing", rt.Method, rt.Pattern)
+		}
+		id, _ := op["operationId"].(string)
+		if id == "" || operationIDs[id] {
+			t.Fatalf("%s %s has missing or duplicate operationId %q", rt.Method, rt.Pattern, id)
+		}
+		operationIDs[id] = true
+		if strings.Contains(rt.Pattern, "{") && op["parameters"] == nil {
+			t.Fatalf("%s %s does not declare its path parameters", rt.Method, rt.Pattern)
+		}
+	}
+
+	get := paths["/incidents/{id}"].(map[string]any)["get"].(map[string]any)
+	if get["x-opsorch-action"] != "incident.get" {
+		t.Fatalf("expected the audit action on the operation, got %v", get["x-opsorch-action"])
+	}
+	created := paths["/incidents"].(map[string]any)["post"].(map[string]any)["responses"].(map[string]any)
+	if _, ok := created["201"]; !ok {
+		t.Fatalf("expected a 201 response for incident create, got %v", created)
+	}
+}
+
+func TestOpenAPISchemasFollowJSONTagsAndEnums(t *testing.T) {
+	doc := fetchOpenAPI(t)
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	blockType := schemas["BlockType"].(map[string]any)
+	if !reflect.DeepEqual(blockType["enum"], []any{"header", "section", "divider"}) {
+		t.Fatalf("unexpected BlockType enum %v", blockType["enum"])
+	}
+	step := schemas["OrchestrationStep"].(map[string]any)["properties"].(map[string]any)
+	if !reflect.DeepEqual(step["type"].(map[string]any)["enum"], []any{"manual", "automated"}) {
+		t.Fatalf("unexpected step type enum %v", step["type"])
+	}
+
+	incident := schemas["Incident"].(map[string]any)
+	props := incident["properties"].(map[string]any)
+	if props["createdAt"].(map[string]any)["format"] != "date-time" {
+		t.Fatalf("expected createdAt as date-time, got %v", props["createdAt"])
+	}
+	required := incident["required"].([]any)
+	for _, name := range required {
+		if name == "fields" || name == "metadata" {
+			t.Fatalf("omitempty field %v listed as required", name)
+		}
+	}
+
+	errSchema := schemas["ErrorResponse"].(map[string]any)
+	if !reflect.DeepEqual(errSchema["required"], []any{"code", "message"}) {
+		t.Fatalf("unexpected error schema %v", errSchema)
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	doc := fetchOpenAPI(t)
+	raw, _ := json.Marshal(doc)
+	components := doc["components"].(map[string]any)
+	for _, part := range strings.Split(string(raw), `"$ref":"#/components/`)[1:] {
+		ref := part[:strings.IndexByte(part, '"')]
+		kind, name, _ := strings.Cut(ref, "/")
+		if _, ok := components[kind].(map[string]any)[name]; !ok {
+			t.Fatalf("unresolved reference #/components/%s", ref)
+		}
+	}
+}
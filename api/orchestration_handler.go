@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/opsorch/opsorch-core/orcherr"
 	"github.com/opsorch/opsorch-core/orchestration"
@@ -15,13 +14,13 @@ type OrchestrationHandler struct {
 	provider orchestration.Provider
 }
 
-func newOrchestrationHandlerFromEnv(sec SecretProvider) (OrchestrationHandler, error) {
+func newOrchestrationHandlerFromEnv(sec SecretProvider, host pluginHost) (OrchestrationHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "orchestration", "OPSORCH_ORCHESTRATION_PROVIDER", "OPSORCH_ORCHESTRATION_CONFIG", "OPSORCH_ORCHESTRATION_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return OrchestrationHandler{}, err
 	}
 	if pluginPath != "" {
-		return OrchestrationHandler{provider: newOrchestrationPluginProvider(pluginPath, cfg)}, nil
+		return OrchestrationHandler{provider: observeOrchestration(pluginPath, newOrchestrationPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := orchestration.LookupProvider(name)
 	if !ok {
@@ -31,121 +30,106 @@ func newOrchestrationHandlerFromEnv(sec SecretProvider) (OrchestrationHandler, e
 	if err != nil {
 		return OrchestrationHandler{}, err
 	}
-	return OrchestrationHandler{provider: provider}, nil
+	return OrchestrationHandler{provider: observeOrchestration(name, provider)}, nil
 }
 
-func (s *Server) handleOrchestration(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/orchestration") {
-		return false
-	}
-	if s.orchestration.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "orchestration_provider_missing", Message: "orchestration provider not configured"})
-		return true
-	}
+// startRunRequest is the body of POST /orchestration/runs.
+type startRunRequest struct {
+	PlanID string `json:"planId"`
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
+// completeStepRequest is the body of POST /orchestration/runs/{runId}/steps/{stepId}/complete.
+type completeStepRequest struct {
+	Actor string `json:"actor"`
+	Note  string `json:"note"`
+}
 
-	switch {
-	// POST /orchestration/plans/query
-	case len(segments) == 3 && segments[1] == "plans" && segments[2] == "query" && r.Method == http.MethodPost:
-		var query schema.OrchestrationPlanQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		plans, err := s.orchestration.provider.QueryPlans(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.plans.query")
-		writeJSON(w, http.StatusOK, plans)
-		return true
+// orchestrationProvider returns the orchestration provider currently installed.
+func (s *Server) orchestrationProvider() orchestration.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.orchestration.provider
+}
 
-	// GET /orchestration/plans/{planId}
-	case len(segments) == 3 && segments[1] == "plans" && r.Method == http.MethodGet:
-		planID := segments[2]
-		plan, err := s.orchestration.provider.GetPlan(r.Context(), planID)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.plans.get")
-		writeJSON(w, http.StatusOK, plan)
-		return true
+func (s *Server) queryOrchestrationPlans(w http.ResponseWriter, r *http.Request) {
+	var query schema.OrchestrationPlanQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	plans, err := s.orchestrationProvider().QueryPlans(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "orchestration.plans.query")
+	writeJSON(w, http.StatusOK, plans)
+}
 
-	// POST /orchestration/runs/query
-	case len(segments) == 3 && segments[1] == "runs" && segments[2] == "query" && r.Method == http.MethodPost:
-		var query schema.OrchestrationRunQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		runs, err := s.orchestration.provider.QueryRuns(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.runs.query")
-		writeJSON(w, http.StatusOK, runs)
-		return true
+func (s *Server) getOrchestrationPlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.orchestrationProvider().GetPlan(r.Context(), r.PathValue("planId"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "orchestration.plans.get")
+	writeJSON(w, http.StatusOK, plan)
+}
 
-	// POST /orchestration/runs
-	case len(segments) == 2 && segments[1] == "runs" && r.Method == http.MethodPost:
-		var input struct {
-			PlanID string `json:"planId"`
-		}
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		if input.PlanID == "" {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: "planId is required"})
-			return true
-		}
-		run, err := s.orchestration.provider.StartRun(r.Context(), input.PlanID)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.runs.start")
-		writeJSON(w, http.StatusCreated, run)
-		return true
+func (s *Server) queryOrchestrationRuns(w http.ResponseWriter, r *http.Request) {
+	var query schema.OrchestrationRunQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	runs, err := s.orchestrationProvider().QueryRuns(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "orchestration.runs.query")
+	writeJSON(w, http.StatusOK, runs)
+}
 
-	// GET /orchestration/runs/{runId}
-	case len(segments) == 3 && segments[1] == "runs" && r.Method == http.MethodGet:
-		runID := segments[2]
-		run, err := s.orchestration.provider.GetRun(r.Context(), runID)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.runs.get")
-		writeJSON(w, http.StatusOK, run)
-		return true
+func (s *Server) startOrchestrationRun(w http.ResponseWriter, r *http.Request) {
+	var input startRunRequest
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	if input.PlanID == "" {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: "planId is required"})
+		return
+	}
+	run, err := s.orchestrationProvider().StartRun(r.Context(), input.PlanID)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "orchestration.runs.start")
+	writeJSON(w, http.StatusCreated, run)
+}
 
-	// POST /orchestration/runs/{runId}/steps/{stepId}/complete
-	case len(segments) == 6 && segments[1] == "runs" && segments[3] == "steps" && segments[5] == "complete" && r.Method == http.MethodPost:
-		runID := segments[2]
-		stepID := segments[4]
-		var input struct {
-			Actor string `json:"actor"`
-			Note  string `json:"note"`
-		}
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		if err := s.orchestration.provider.CompleteStep(r.Context(), runID, stepID, input.Actor, input.Note); err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "orchestration.runs.steps.complete")
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-		return true
+func (s *Server) getOrchestrationRun(w http.ResponseWriter, r *http.Request) {
+	run, err := s.orchestrationProvider().GetRun(r.Context(), r.PathValue("runId"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "orchestration.runs.get")
+	writeJSON(w, http.StatusOK, run)
+}
 
-	default:
-		return false
+func (s *Server) completeOrchestrationStep(w http.ResponseWriter, r *http.Request) {
+	var input completeStepRequest
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	if err := s.orchestrationProvider().CompleteStep(r.Context(), r.PathValue("runId"), r.PathValue("stepId"), input.Actor, input.Note); err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "orchestration.runs.steps.complete")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
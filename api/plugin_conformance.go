@@ -0,0 +1,694 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// conformanceMissingID is an ID no backend is expected to know. Plugins must answer lookups
+// for it with a not_found error.
+const conformanceMissingID = "opsorch-conformance-missing"
+
+// PluginVerifyOptions controls a conformance run.
+type PluginVerifyOptions struct {
+	// Config is sent to the plugin with every request, as OPSORCH_<CAP>_CONFIG would be.
+	Config map[string]any
+	// ReadOnly skips checks that create or change data in the plugin's backend.
+	ReadOnly bool
+	// Timeout bounds each call; zero uses the default plugin timeout.
+	Timeout time.Duration
+}
+
+// PluginCheck is the outcome of one conformance check: "pass", "fail", or "skip".
+type PluginCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// PluginReport is the result of VerifyPlugin.
+type PluginReport struct {
+	Capability string        `json:"capability"`
+	Plugin     string        `json:"plugin"`
+	Name       string        `json:"name,omitempty"`
+	Version    string        `json:"version,omitempty"`
+	Checks     []PluginCheck `json:"checks"`
+}
+
+// Passed reports whether no check failed.
+func (r PluginReport) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Status == "fail" {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteText writes the report as one line per check followed by a summary line.
+func (r PluginReport) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s plugin %s", r.Capability, r.Plugin)
+	if r.Name != "" {
+		fmt.Fprintf(&buf, " (%s %s)", r.Name, r.Version)
+	}
+	buf.WriteString("\n")
+	width := 0
+	for _, c := range r.Checks {
+		width = max(width, len(c.Name))
+	}
+	counts := map[string]int{}
+	for _, c := range r.Checks {
+		counts[c.Status]++
+		line := fmt.Sprintf("%-4s  %-*s  %s", strings.ToUpper(c.Status), width, c.Name, c.Detail)
+		buf.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	result := "PASS"
+	if !r.Passed() {
+		result = "FAIL"
+	}
+	fmt.Fprintf(&buf, "%s: %d passed, %d failed, %d skipped\n", result, counts["pass"], counts["fail"], counts["skip"])
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// VerifyPlugin drives a plugin through the methods core calls for capability and checks its
+// handshake, the shape of every result against the schema types, error code propagation, and
+// its handling of unknown methods. Failures are reported as checks; the error is only set
+// when capability has no conformance suite.
+func VerifyPlugin(ctx context.Context, capability, plugin string, opts PluginVerifyOptions) (PluginReport, error) {
+	if c, ok := normalizeCapability(capability); ok {
+		capability = c
+	}
+	suite, ok := conformanceSuites[capability]
+	if !ok {
+		return PluginReport{}, fmt.Errorf("no conformance suite for capability %q", capability)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultPluginTimeout
+	}
+	target := newConformanceTarget(capability, plugin, opts)
+	defer target.close()
+
+	report := PluginReport{Capability: capability, Plugin: plugin}
+	manifest, check := checkConformanceHandshake(ctx, capability, target)
+	report.Checks = append(report.Checks, check)
+	if !target.started() {
+		return report, nil
+	}
+	if manifest != nil {
+		report.Name, report.Version = manifest.Name, manifest.Version
+		report.Checks = append(report.Checks, checkDeclaredMethods(suite, manifest))
+	}
+
+	rec := &conformanceRecorder{pluginClient: target}
+	state := &conformanceState{}
+	for _, step := range suite(rec) {
+		report.Checks = append(report.Checks, runConformanceStep(ctx, step, manifest, rec, state, opts))
+	}
+	report.Checks = append(report.Checks, checkUnknownMethod(ctx, capability, target))
+	if h, ok := target.health(); ok {
+		c := PluginCheck{Name: "stability", Status: "pass", Detail: "no crashes or restarts"}
+		if h.Restarts > 0 || !h.Running {
+			c = PluginCheck{Name: "stability", Status: "fail", Detail: fmt.Sprintf("plugin restarted %d times; last error: %s", h.Restarts, h.LastError)}
+		}
+		report.Checks = append(report.Checks, c)
+	}
+	return report, nil
+}
+
+// conformanceTarget is a plugin client that sends every method to the plugin, including
+// methods missing from its manifest, so the plugin's own answer can be checked.
+type conformanceTarget interface {
+	pluginClient
+	manifest(ctx context.Context) (*pluginManifest, error)
+	started() bool
+	health() (pluginHealth, bool)
+}
+
+func newConformanceTarget(capability, plugin string, opts PluginVerifyOptions) conformanceTarget {
+	popts := pluginOptions{timeout: opts.Timeout}
+	if isHTTPPluginTarget(plugin) {
+		c := newHTTPPluginClient(capability, plugin, opts.Config, popts)
+		c.sendUndeclared = true
+		return conformanceHTTP{c}
+	}
+	r := newPluginRunner(capability, plugin, opts.Config, popts)
+	r.sendUndeclared = true
+	// A crash must show up as a failed check, not be papered over by a quick restart.
+	r.backoffInitial = time.Hour
+	return conformanceRunner{r}
+}
+
+type conformanceRunner struct{ *pluginRunner }
+
+func (c conformanceRunner) manifest(ctx context.Context) (*pluginManifest, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	proc, err := c.ensureProcess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return proc.manifest, nil
+}
+
+func (c conformanceRunner) started() bool {
+	return c.pluginRunner.health().Running
+}
+
+func (c conformanceRunner) health() (pluginHealth, bool) {
+	return c.pluginRunner.health(), true
+}
+
+type conformanceHTTP struct{ *httpPluginClient }
+
+func (c conformanceHTTP) manifest(ctx context.Context) (*pluginManifest, error) {
+	return c.ensureHandshake(ctx)
+}
+
+func (c conformanceHTTP) started() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handshook
+}
+
+func (c conformanceHTTP) health() (pluginHealth, bool) {
+	return pluginHealth{}, false
+}
+
+func checkConformanceHandshake(ctx context.Context, capability string, target conformanceTarget) (*pluginManifest, PluginCheck) {
+	manifest, err := target.manifest(ctx)
+	switch {
+	case err != nil:
+		return nil, PluginCheck{Name: "handshake", Status: "fail", Detail: err.Error()}
+	case manifest == nil:
+		return nil, PluginCheck{Name: "handshake", Status: "fail", Detail: fmt.Sprintf("plugin does not answer %s; core falls back to lock-step calls without method checks", pluginHandshakeMethod)}
+	case manifest.Capability != capability:
+		return manifest, PluginCheck{Name: "handshake", Status: "fail", Detail: fmt.Sprintf("manifest capability is %q, want %q", manifest.Capability, capability)}
+	}
+	return manifest, PluginCheck{Name: "handshake", Status: "pass", Detail: fmt.Sprintf("protocol %s, %d methods", manifest.ProtocolVersion, len(manifest.Methods))}
+}
+
+// checkDeclaredMethods fails manifests that declare methods core never calls, which usually
+// means a typo that leaves the real method undeclared.
+func checkDeclaredMethods(suite conformanceSuite, manifest *pluginManifest) PluginCheck {
+	known := map[string]bool{pluginPingMethod: true}
+	for _, step := range suite(nil) {
+		known[step.method] = true
+	}
+	var unknown []string
+	for _, m := range manifest.Methods {
+		if !known[m] {
+			unknown = append(unknown, m)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return PluginCheck{Name: "manifest methods", Status: "fail", Detail: "core never calls " + strings.Join(unknown, ", ")}
+	}
+	return PluginCheck{Name: "manifest methods", Status: "pass", Detail: strings.Join(manifest.Methods, ", ")}
+}
+
+func checkUnknownMethod(ctx context.Context, capability string, target conformanceTarget) PluginCheck {
+	method := capability + ".conformance.unknown"
+	name := "unknown method"
+	err := target.call(ctx, method, map[string]any{}, nil)
+	if err == nil {
+		return PluginCheck{Name: name, Status: "fail", Detail: method + " succeeded; unknown methods must fail with not_implemented"}
+	}
+	if oe := asOpsOrchError(err); oe != nil && oe.Code == "not_implemented" {
+		return PluginCheck{Name: name, Status: "pass", Detail: "not_implemented"}
+	}
+	return PluginCheck{Name: name, Status: "fail", Detail: fmt.Sprintf("want error code not_implemented, got %v", err)}
+}
+
+// conformanceStep exercises one method the way core's plugin provider calls it.
+type conformanceStep struct {
+	name    string
+	method  string
+	mutates bool // creates or changes data in the plugin's backend
+	run     func(ctx context.Context, s *conformanceState) error
+}
+
+// conformanceSuite returns the steps for a capability, calling the plugin through client.
+type conformanceSuite func(client pluginClient) []conformanceStep
+
+// conformanceState carries IDs found by earlier steps to the steps that need them.
+type conformanceState struct {
+	ids map[string]string // kind -> an ID the plugin returned
+}
+
+func (s *conformanceState) remember(kind, id string) {
+	if id == "" {
+		return
+	}
+	if s.ids == nil {
+		s.ids = map[string]string{}
+	}
+	s.ids[kind] = id
+}
+
+// id returns a known ID of kind, or a skip when no earlier step produced one.
+func (s *conformanceState) id(kind string) (string, error) {
+	if id := s.ids[kind]; id != "" {
+		return id, nil
+	}
+	return "", conformanceSkip(fmt.Sprintf("no %s ID returned by an earlier check", kind))
+}
+
+// conformanceSkip ends a step without passing or failing it.
+type conformanceSkip string
+
+func (s conformanceSkip) Error() string { return string(s) }
+
+func runConformanceStep(ctx context.Context, step conformanceStep, manifest *pluginManifest, rec *conformanceRecorder, state *conformanceState, opts PluginVerifyOptions) PluginCheck {
+	check := PluginCheck{Name: step.name}
+	if !manifest.supports(step.method) {
+		check.Status, check.Detail = "skip", "not declared in manifest; core answers 501"
+		return check
+	}
+	if step.mutates && opts.ReadOnly {
+		check.Status, check.Detail = "skip", "read-only run"
+		return check
+	}
+	rec.shapeErr = nil
+	err := step.run(ctx, state)
+	var skip conformanceSkip
+	switch {
+	case errors.As(err, &skip):
+		check.Status, check.Detail = "skip", string(skip)
+	case err != nil:
+		check.Status, check.Detail = "fail", err.Error()
+	case rec.shapeErr != nil:
+		check.Status, check.Detail = "fail", rec.shapeErr.Error()
+	default:
+		check.Status = "pass"
+	}
+	return check
+}
+
+// conformanceRecorder passes calls through to the plugin and checks each raw result against
+// the schema type the plugin provider decodes it into.
+type conformanceRecorder struct {
+	pluginClient
+	shapeErr error
+}
+
+func (c *conformanceRecorder) call(ctx context.Context, method string, payload any, out any) error {
+	var raw json.RawMessage
+	if err := c.pluginClient.call(ctx, method, payload, &raw); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := checkResultShape(raw, out); err != nil && c.shapeErr == nil {
+		c.shapeErr = fmt.Errorf("%s: %w", method, err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// checkResultShape decodes raw strictly into a fresh value of out's type, so fields the schema
+// does not define are reported instead of silently dropped.
+func checkResultShape(raw json.RawMessage, out any) error {
+	typ := reflect.TypeOf(out).Elem()
+	if len(raw) == 0 || string(raw) == "null" {
+		if typ.Kind() == reflect.Slice {
+			return fmt.Errorf("result is null; return [] when there are no results")
+		}
+		return fmt.Errorf("result is missing; want %s", typ)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(reflect.New(typ).Interface()); err != nil {
+		return fmt.Errorf("result does not match %s: %v", typ, err)
+	}
+	return nil
+}
+
+// expectCode checks that err carries the given OpsOrchError code.
+func expectCode(err error, code string) error {
+	if err == nil {
+		return fmt.Errorf("call succeeded; want error code %s", code)
+	}
+	if oe := asOpsOrchError(err); oe != nil && oe.Code == code {
+		return nil
+	}
+	return fmt.Errorf("want error code %s so core can map it, got %v", code, err)
+}
+
+func expectID(kind, got, want string) error {
+	if got == "" {
+		return fmt.Errorf("%s has no id", kind)
+	}
+	if want != "" && got != want {
+		return fmt.Errorf("%s id is %q, want %q", kind, got, want)
+	}
+	return nil
+}
+
+func rememberFirst[T any](s *conformanceState, kind string, items []T, id func(T) string) {
+	if len(items) > 0 {
+		s.remember(kind, id(items[0]))
+	}
+}
+
+func missingIDStep(method string, get func(ctx context.Context, id string) error) conformanceStep {
+	return conformanceStep{name: method + " (missing id)", method: method, run: func(ctx context.Context, s *conformanceState) error {
+		return expectCode(get(ctx, conformanceMissingID), "not_found")
+	}}
+}
+
+// conformanceSuites lists, per capability, the calls core's plugin provider makes.
+var conformanceSuites = map[string]conformanceSuite{
+	"incident": func(client pluginClient) []conformanceStep {
+		p := incidentPluginProvider{runner: client}
+		title := "opsorch conformance check"
+		return []conformanceStep{
+			{name: "incident.query", method: "incident.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Query(ctx, schema.IncidentQuery{Limit: 5})
+				rememberFirst(s, "incident", res, func(i schema.Incident) string { return i.ID })
+				return err
+			}},
+			{name: "incident.create", method: "incident.create", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				inc, err := p.Create(ctx, schema.CreateIncidentInput{Title: title, Status: "open", Severity: "sev4", Description: "Created by opsorch plugin verify."})
+				if err != nil {
+					return err
+				}
+				s.remember("incident", inc.ID)
+				return expectID("created incident", inc.ID, "")
+			}},
+			{name: "incident.get", method: "incident.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("incident")
+				if err != nil {
+					return err
+				}
+				inc, err := p.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("incident", inc.ID, id)
+			}},
+			missingIDStep("incident.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+			{name: "incident.update", method: "incident.update", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("incident")
+				if err != nil {
+					return err
+				}
+				status := "acknowledged"
+				inc, err := p.Update(ctx, id, schema.UpdateIncidentInput{Status: &status})
+				if err != nil {
+					return err
+				}
+				return expectID("updated incident", inc.ID, id)
+			}},
+			{name: "incident.timeline.get", method: "incident.timeline.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("incident")
+				if err != nil {
+					return err
+				}
+				_, err = p.GetTimeline(ctx, id)
+				return err
+			}},
+			{name: "incident.timeline.append", method: "incident.timeline.append", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("incident")
+				if err != nil {
+					return err
+				}
+				return p.AppendTimeline(ctx, id, schema.TimelineAppendInput{At: time.Now().UTC(), Kind: "note", Body: "opsorch plugin verify"})
+			}},
+		}
+	},
+	"alert": func(client pluginClient) []conformanceStep {
+		p := alertPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "alert.query", method: "alert.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Query(ctx, schema.AlertQuery{Limit: 5})
+				rememberFirst(s, "alert", res, func(a schema.Alert) string { return a.ID })
+				return err
+			}},
+			{name: "alert.get", method: "alert.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("alert")
+				if err != nil {
+					return err
+				}
+				a, err := p.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("alert", a.ID, id)
+			}},
+			missingIDStep("alert.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+		}
+	},
+	"log": func(client pluginClient) []conformanceStep {
+		p := logPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "log.query", method: "log.query", run: func(ctx context.Context, s *conformanceState) error {
+				end := time.Now().UTC()
+				_, err := p.Query(ctx, schema.LogQuery{Start: end.Add(-15 * time.Minute), End: end, Limit: 5})
+				return err
+			}},
+		}
+	},
+	"metric": func(client pluginClient) []conformanceStep {
+		p := metricPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "metric.describe", method: "metric.describe", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Describe(ctx, schema.QueryScope{})
+				rememberFirst(s, "metric", res, func(d schema.MetricDescriptor) string { return d.Name })
+				return err
+			}},
+			{name: "metric.query", method: "metric.query", run: func(ctx context.Context, s *conformanceState) error {
+				name, err := s.id("metric")
+				if err != nil {
+					return err
+				}
+				end := time.Now().UTC()
+				_, err = p.Query(ctx, schema.MetricQuery{Expression: &schema.MetricExpression{MetricName: name}, Start: end.Add(-15 * time.Minute), End: end, Step: 60})
+				return err
+			}},
+		}
+	},
+	"ticket": func(client pluginClient) []conformanceStep {
+		p := ticketPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "ticket.query", method: "ticket.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Query(ctx, schema.TicketQuery{Limit: 5})
+				rememberFirst(s, "ticket", res, func(t schema.Ticket) string { return t.ID })
+				return err
+			}},
+			{name: "ticket.create", method: "ticket.create", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				t, err := p.Create(ctx, schema.CreateTicketInput{Title: "opsorch conformance check", Description: "Created by opsorch plugin verify."})
+				if err != nil {
+					return err
+				}
+				s.remember("ticket", t.ID)
+				return expectID("created ticket", t.ID, "")
+			}},
+			{name: "ticket.get", method: "ticket.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("ticket")
+				if err != nil {
+					return err
+				}
+				t, err := p.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("ticket", t.ID, id)
+			}},
+			missingIDStep("ticket.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+			{name: "ticket.update", method: "ticket.update", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("ticket")
+				if err != nil {
+					return err
+				}
+				desc := "Updated by opsorch plugin verify."
+				t, err := p.Update(ctx, id, schema.UpdateTicketInput{Description: &desc})
+				if err != nil {
+					return err
+				}
+				return expectID("updated ticket", t.ID, id)
+			}},
+		}
+	},
+	"messaging": func(client pluginClient) []conformanceStep {
+		p := messagingPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "messaging.send", method: "messaging.send", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Send(ctx, schema.Message{Channel: "opsorch-conformance", Body: "opsorch plugin verify"})
+				if err != nil {
+					return err
+				}
+				return expectID("message result", res.ID, "")
+			}},
+		}
+	},
+	"service": func(client pluginClient) []conformanceStep {
+		p := servicePluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "service.query", method: "service.query", run: func(ctx context.Context, s *conformanceState) error {
+				_, err := p.Query(ctx, schema.ServiceQuery{Limit: 5})
+				return err
+			}},
+		}
+	},
+	"deployment": func(client pluginClient) []conformanceStep {
+		p := deploymentPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "deployment.query", method: "deployment.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Query(ctx, schema.DeploymentQuery{Limit: 5})
+				rememberFirst(s, "deployment", res, func(d schema.Deployment) string { return d.ID })
+				return err
+			}},
+			{name: "deployment.get", method: "deployment.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("deployment")
+				if err != nil {
+					return err
+				}
+				d, err := p.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("deployment", d.ID, id)
+			}},
+			missingIDStep("deployment.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+		}
+	},
+	"team": func(client pluginClient) []conformanceStep {
+		p := teamPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "team.query", method: "team.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.Query(ctx, schema.TeamQuery{})
+				rememberFirst(s, "team", res, func(t schema.Team) string { return t.ID })
+				return err
+			}},
+			{name: "team.get", method: "team.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("team")
+				if err != nil {
+					return err
+				}
+				t, err := p.Get(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("team", t.ID, id)
+			}},
+			missingIDStep("team.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+			{name: "team.members", method: "team.members", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("team")
+				if err != nil {
+					return err
+				}
+				_, err = p.Members(ctx, id)
+				return err
+			}},
+		}
+	},
+	"orchestration": func(client pluginClient) []conformanceStep {
+		p := orchestrationPluginProvider{runner: client}
+		return []conformanceStep{
+			{name: "orchestration.plans.query", method: "orchestration.plans.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.QueryPlans(ctx, schema.OrchestrationPlanQuery{Limit: 5})
+				rememberFirst(s, "plan", res, func(pl schema.OrchestrationPlan) string { return pl.ID })
+				return err
+			}},
+			{name: "orchestration.plans.get", method: "orchestration.plans.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("plan")
+				if err != nil {
+					return err
+				}
+				pl, err := p.GetPlan(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("plan", pl.ID, id)
+			}},
+			{name: "orchestration.runs.query", method: "orchestration.runs.query", run: func(ctx context.Context, s *conformanceState) error {
+				res, err := p.QueryRuns(ctx, schema.OrchestrationRunQuery{Limit: 5})
+				rememberFirst(s, "run", res, func(r schema.OrchestrationRun) string { return r.ID })
+				return err
+			}},
+			{name: "orchestration.runs.get", method: "orchestration.runs.get", run: func(ctx context.Context, s *conformanceState) error {
+				id, err := s.id("run")
+				if err != nil {
+					return err
+				}
+				run, err := p.GetRun(ctx, id)
+				if err != nil {
+					return err
+				}
+				return expectID("run", run.ID, id)
+			}},
+			// Starting runs and completing steps act on real workflows, so they are declared
+			// for the manifest check but never exercised.
+			{name: "orchestration.runs.start", method: "orchestration.runs.start", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				return conformanceSkip("starts a real workflow; not exercised")
+			}},
+			{name: "orchestration.runs.steps.complete", method: "orchestration.runs.steps.complete", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				return conformanceSkip("advances a real workflow; not exercised")
+			}},
+		}
+	},
+	"secret": func(client pluginClient) []conformanceStep {
+		p := secretPluginProvider{runner: client}
+		key := "opsorch-conformance/check"
+		return []conformanceStep{
+			{name: "secret.put", method: "secret.put", mutates: true, run: func(ctx context.Context, s *conformanceState) error {
+				if err := p.Put(ctx, key, "conformance"); err != nil {
+					return err
+				}
+				s.remember("secret", key)
+				return nil
+			}},
+			{name: "secret.get", method: "secret.get", run: func(ctx context.Context, s *conformanceState) error {
+				k, err := s.id("secret")
+				if err != nil {
+					return err
+				}
+				v, err := p.Get(ctx, k)
+				if err != nil {
+					return err
+				}
+				if v != "conformance" {
+					return fmt.Errorf("secret.get returned %q, want the value just put", v)
+				}
+				return nil
+			}},
+			missingIDStep("secret.get", func(ctx context.Context, id string) error {
+				_, err := p.Get(ctx, id)
+				return err
+			}),
+		}
+	},
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func checkStatuses(report PluginReport) map[string]PluginCheck {
+	checks := map[string]PluginCheck{}
+	for _, c := range report.Checks {
+		checks[c.Name] = c
+	}
+	return checks
+}
+
+func TestVerifyPluginPassesMockPlugin(t *testing.T) {
+	captureLog(t)
+	report, err := VerifyPlugin(context.Background(), "incidents", buildMockPlugin(t, "incidentmock"), PluginVerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	var out bytes.Buffer
+	_ = report.WriteText(&out)
+	if !report.Passed() || report.Capability != "incident" || report.Name != "incidentmock" {
+		t.Fatalf("expected incidentmock to pass:\n%s", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "PASS: 11 passed, 0 failed, 0 skipped\n") {
+		t.Fatalf("unexpected summary:\n%s", out.String())
+	}
+}
+
+func TestVerifyPluginReportsViolations(t *testing.T) {
+	captureLog(t)
+	// A legacy lock-step plugin: no handshake, null query results, fields outside the schema,
+	// errors without codes, and a crash on unknown methods.
+	script := writePluginScript(t, shellIDHelpers+`while read -r line; do
+  case "$(method_of "$line")" in
+    plugin.handshake) echo '{"error":"unknown method"}' ;;
+    incident.query) echo '{"result":null}' ;;
+    incident.create) echo '{"result":{"id":"c1","title":"t","status":"open","severity":"sev4","createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z","priority":"p1"}}' ;;
+    incident.get)
+      case "$line" in
+        *conformance-missing*) echo '{"error":"no such incident"}' ;;
+        *) echo '{"result":{"id":"c1","title":"t","status":"open","severity":"sev4","createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z"}}' ;;
+      esac ;;
+    incident.update) echo '{"result":{"id":"c1","title":"t","status":"acknowledged","severity":"sev4","createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z"}}' ;;
+    incident.timeline.get) echo '{"result":[]}' ;;
+    incident.timeline.append) echo '{"result":{"status":"ok"}}' ;;
+    *) exit 1 ;;
+  esac
+done
+`)
+	report, err := VerifyPlugin(context.Background(), "incident", script, PluginVerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.Passed() {
+		t.Fatalf("expected failures, got %+v", report.Checks)
+	}
+	checks := checkStatuses(report)
+	want := map[string]string{
+		"handshake":                 "fail",
+		"incident.query":            "fail",
+		"incident.create":           "fail",
+		"incident.get":              "pass",
+		"incident.get (missing id)": "fail",
+		"incident.update":           "pass",
+		"incident.timeline.get":     "pass",
+		"incident.timeline.append":  "pass",
+		"unknown method":            "fail",
+		"stability":                 "fail",
+	}
+	for name, status := range want {
+		if checks[name].Status != status {
+			t.Errorf("%s: status %q, want %q (%s)", name, checks[name].Status, status, checks[name].Detail)
+		}
+	}
+	if d := checks["incident.query"].Detail; !strings.Contains(d, "return [] when there are no results") {
+		t.Errorf("unexpected query detail %q", d)
+	}
+	if d := checks["incident.create"].Detail; !strings.Contains(d, `unknown field "priority"`) {
+		t.Errorf("unexpected create detail %q", d)
+	}
+}
+
+func TestVerifyPluginReadOnlySkipsMutations(t *testing.T) {
+	captureLog(t)
+	report, err := VerifyPlugin(context.Background(), "incident", buildMockPlugin(t, "incidentmock"), PluginVerifyOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	checks := checkStatuses(report)
+	for _, name := range []string{"incident.create", "incident.update", "incident.timeline.append"} {
+		if checks[name].Status != "skip" {
+			t.Errorf("%s: status %q, want skip", name, checks[name].Status)
+		}
+	}
+	if checks["incident.get"].Status != "pass" {
+		t.Errorf("incident.get should use the ID from incident.query, got %+v", checks["incident.get"])
+	}
+}
+
+func TestVerifyPluginUnknownCapability(t *testing.T) {
+	if _, err := VerifyPlugin(context.Background(), "weather", "unused", PluginVerifyOptions{}); err == nil {
+		t.Fatalf("expected error for unknown capability")
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
+)
+
+const (
+	// pluginProtocolVersion is the plugin RPC protocol spoken by core. Plugins must report the
+	// same major version in their handshake.
+	pluginProtocolVersion  = pluginsdk.ProtocolVersion
+	pluginHandshakeMethod  = pluginsdk.HandshakeMethod
+	pluginShutdownMethod   = pluginsdk.ShutdownMethod
+	pluginPingMethod       = pluginsdk.PingMethod
+	pluginHandshakeTimeout = 10 * time.Second
+)
+
+// pluginHandshakeRequest is the payload of the plugin.handshake frame sent to every new process.
+type pluginHandshakeRequest struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	Capability      string `json:"capability"`
+}
+
+// pluginManifest is a plugin's handshake answer: the protocol it speaks, the capability it
+// serves, and the methods it implements.
+type pluginManifest struct {
+	ProtocolVersion string   `json:"protocolVersion"`
+	Capability      string   `json:"capability"`
+	Methods         []string `json:"methods"`
+	Name            string   `json:"name,omitempty"`
+	Version         string   `json:"version,omitempty"`
+}
+
+// supports reports whether the plugin declared method. An empty method list declares nothing
+// and is treated as "everything", matching legacy plugins.
+func (m *pluginManifest) supports(method string) bool {
+	if m == nil || len(m.Methods) == 0 {
+		return true
+	}
+	for _, declared := range m.Methods {
+		if declared == method {
+			return true
+		}
+	}
+	return false
+}
+
+// declares reports whether the plugin listed method explicitly. Unlike supports, an empty or
+// missing manifest declares nothing.
+func (m *pluginManifest) declares(method string) bool {
+	return m != nil && slices.Contains(m.Methods, method)
+}
+
+// errPluginIncompatible marks handshake failures that restarting the same binary cannot fix.
+var errPluginIncompatible = errors.New("plugin incompatible")
+
+// start spawns a plugin process and completes the handshake. The process stays detached until
+// the handshake succeeds, so killing a rejected process is not reported as a crash.
+func (r *pluginRunner) start(ctx context.Context) (*pluginProcess, error) {
+	proc, err := r.spawn()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := r.handshake(ctx, proc)
+	if err != nil {
+		proc.terminate(err)
+		return nil, err
+	}
+	proc.manifest = manifest
+	proc.detached.Store(false)
+
+	r.stateMu.Lock()
+	r.state.manifest = manifest
+	r.stateMu.Unlock()
+	return proc, nil
+}
+
+// handshake exchanges protocol versions with a freshly spawned plugin. Plugins predating the
+// handshake answer with an error or without echoing the request ID; they are accepted with no
+// manifest and keep the lock-step protocol.
+func (r *pluginRunner) handshake(ctx context.Context, proc *pluginProcess) (*pluginManifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginHandshakeTimeout)
+	defer cancel()
+
+	req := rpcRequest{
+		ID:      r.nextID.Add(1),
+		Method:  pluginHandshakeMethod,
+		Config:  r.config,
+		Payload: pluginHandshakeRequest{ProtocolVersion: pluginProtocolVersion, Capability: r.capability},
+	}
+	resp, err := r.exchange(ctx, proc, req, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin handshake: %w", r.capability, err)
+	}
+	if resp.Error != nil || resp.ID != req.ID {
+		slog.WarnContext(ctx, "plugin does not support the handshake; using legacy lock-step protocol", "capability", r.capability, "plugin", r.path, "method", pluginHandshakeMethod)
+		return nil, nil
+	}
+
+	manifest, err := checkPluginManifest(r.capability, r.path, resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	proc.setDeadline(time.Time{})
+	proc.mux = newPluginMux(r.capability, proc, r.host)
+	return manifest, nil
+}
+
+// checkPluginManifest decodes a handshake result and verifies that the plugin speaks core's
+// protocol major version and serves capability.
+func checkPluginManifest(capability, target string, result json.RawMessage) (*pluginManifest, error) {
+	var manifest pluginManifest
+	if err := json.Unmarshal(result, &manifest); err != nil {
+		return nil, fmt.Errorf("%s plugin handshake: invalid manifest: %w", capability, err)
+	}
+	if major(manifest.ProtocolVersion) != major(pluginProtocolVersion) {
+		return nil, orcherr.New("plugin_incompatible", fmt.Sprintf("%s plugin speaks protocol %q, core requires %s.x", capability, manifest.ProtocolVersion, major(pluginProtocolVersion)), errPluginIncompatible)
+	}
+	if manifest.Capability != "" && manifest.Capability != capability {
+		return nil, orcherr.New("plugin_incompatible", fmt.Sprintf("plugin %s serves capability %q, not %q", target, manifest.Capability, capability), errPluginIncompatible)
+	}
+	slog.Info("plugin handshake", "capability", capability, "plugin", target, "name", manifest.Name, "version", manifest.Version, "protocol", manifest.ProtocolVersion, "methods", strings.Join(manifest.Methods, ","))
+	return &manifest, nil
+}
+
+// major returns the major component of a "major.minor" version string.
+func major(version string) string {
+	major, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	return major
+}
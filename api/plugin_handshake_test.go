@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// manifestPlugin answers the handshake with manifest and then echoes every request's method
+// as its result, recording received methods in "$0.calls".
+func manifestPlugin(t *testing.T, manifest string) string {
+	t.Helper()
+	return writePluginScript(t, shellIDHelpers+`echo spawn >> "$0.spawns"
+read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":`+manifest+`}"
+while read -r line; do
+  method_of "$line" >> "$0.calls"
+  echo "{\"id\":$(id_of "$line"),\"result\":\"$(method_of "$line")\"}"
+done
+`)
+}
+
+func TestPluginHandshakeRejectsUndeclaredMethods(t *testing.T) {
+	captureLog(t)
+	script := manifestPlugin(t, `{\"protocolVersion\":\"1.2\",\"capability\":\"incident\",\"methods\":[\"incident.query\"]}`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{})
+	defer runner.close()
+
+	err := runner.call(context.Background(), "incident.timeline.append", map[string]any{"id": "1"}, nil)
+	oe := asOpsOrchError(err)
+	if oe == nil || oe.Code != "not_implemented" {
+		t.Fatalf("expected not_implemented, got %v", err)
+	}
+
+	var got string
+	if err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, &got); err != nil {
+		t.Fatalf("declared method: %v", err)
+	}
+	if got != "incident.query" {
+		t.Fatalf("unexpected result %q", got)
+	}
+
+	calls, _ := os.ReadFile(script + ".calls")
+	if strings.Contains(string(calls), "incident.timeline.append") {
+		t.Fatalf("undeclared method must not reach the plugin, calls=%q", calls)
+	}
+	if h := runner.health(); h.Manifest == nil || h.Manifest.ProtocolVersion != "1.2" {
+		t.Fatalf("expected manifest in health, got %+v", h)
+	}
+}
+
+func TestPluginHandshakeRejectsMajorVersionMismatch(t *testing.T) {
+	captureLog(t)
+	script := manifestPlugin(t, `{\"protocolVersion\":\"2.0\",\"capability\":\"incident\",\"methods\":[\"incident.query\"]}`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{})
+	defer runner.close()
+
+	for i := 0; i < 2; i++ {
+		err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, nil)
+		oe := asOpsOrchError(err)
+		if oe == nil || oe.Code != "plugin_incompatible" {
+			t.Fatalf("call %d: expected plugin_incompatible, got %v", i, err)
+		}
+	}
+	spawns, _ := os.ReadFile(script + ".spawns")
+	if n := strings.Count(string(spawns), "spawn"); n != 1 {
+		t.Fatalf("incompatible plugin must not be restarted, spawned %d times", n)
+	}
+	if _, err := os.Stat(script + ".calls"); err == nil {
+		t.Fatalf("no call may reach an incompatible plugin")
+	}
+}
+
+func TestPluginHandshakeRejectsWrongCapability(t *testing.T) {
+	captureLog(t)
+	script := manifestPlugin(t, `{\"protocolVersion\":\"1.0\",\"capability\":\"log\",\"methods\":[\"log.query\"]}`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{})
+	defer runner.close()
+
+	err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, nil)
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "plugin_incompatible" {
+		t.Fatalf("expected plugin_incompatible, got %v", err)
+	}
+}
+
+func TestRPCErrorAcceptsLegacyStringErrors(t *testing.T) {
+	var resp rpcResponse
+	if err := json.Unmarshal([]byte(`{"error":"not found"}`), &resp); err != nil {
+		t.Fatalf("decode legacy error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Message != "not found" || resp.Error.Code != "" {
+		t.Fatalf("unexpected error %+v", resp.Error)
+	}
+
+	if err := json.Unmarshal([]byte(`{"error":{"code":"not_found","message":"missing"}}`), &resp); err != nil {
+		t.Fatalf("decode structured error: %v", err)
+	}
+	if resp.Error.Code != "not_found" || resp.Error.Message != "missing" {
+		t.Fatalf("unexpected error %+v", resp.Error)
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
+)
+
+// pluginHostCallTimeout bounds a host call made by a multiplexed plugin. Lock-step plugins
+// make host calls while answering a request and share that request's deadline instead.
+const pluginHostCallTimeout = 30 * time.Second
+
+// pluginHost is the part of core that answers host calls: its secret provider and the
+// providers of the other capabilities. *Server implements it.
+type pluginHost interface {
+	hostSecret() SecretProvider
+	capabilityProvider(capability string) any
+}
+
+// pluginHostRule allows the host methods matching method, without the "host." prefix. When
+// resource is set, it also has to match the call's resource: the key of a secret read.
+type pluginHostRule struct {
+	method   string
+	resource string
+}
+
+// pluginHostPolicy lists the host calls a plugin may make. The zero policy allows none.
+type pluginHostPolicy struct {
+	rules []pluginHostRule
+}
+
+// pluginHostPolicyFromEnv reads the host call allowlist for a capability.
+//
+//	OPSORCH_<CAP>_PLUGIN_HOST_ALLOW  comma-separated rules, e.g. "secret.get:jira/*,audit.log,capability.team.*"
+//
+// A rule is a host method without its "host." prefix, optionally followed by ":" and the
+// secret keys it covers. A trailing "*" matches a prefix in either part.
+func pluginHostPolicyFromEnv(capability string) pluginHostPolicy {
+	raw := os.Getenv(fmt.Sprintf("OPSORCH_%s_PLUGIN_HOST_ALLOW", strings.ToUpper(capability)))
+	var policy pluginHostPolicy
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		method, resource, _ := strings.Cut(entry, ":")
+		policy.rules = append(policy.rules, pluginHostRule{method: strings.TrimSpace(method), resource: strings.TrimSpace(resource)})
+	}
+	return policy
+}
+
+// allows reports whether a host call to method on resource is permitted.
+func (p pluginHostPolicy) allows(method, resource string) bool {
+	method = strings.TrimPrefix(method, "host.")
+	for _, rule := range p.rules {
+		if !matchWildcard(rule.method, method) {
+			continue
+		}
+		if rule.resource == "" || matchWildcard(rule.resource, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginHostCalls answers the host calls of one plugin.
+type pluginHostCalls struct {
+	capability string
+	plugin     string
+	policy     pluginHostPolicy
+	server     pluginHost // nil fails every allowed call with host_unavailable
+}
+
+// pluginHostReply is core's answer to a host call, written on the plugin's request stream.
+// It has no method, which is how the plugin tells it apart from a request.
+type pluginHostReply struct {
+	ID     uint64    `json:"id"`
+	Result any       `json:"result,omitempty"`
+	Error  *rpcError `json:"error,omitempty"`
+}
+
+// answer serves one host call and returns the reply frame. Calls the policy does not allow
+// are refused with a forbidden error and audited.
+func (h pluginHostCalls) answer(ctx context.Context, call rpcResponse) pluginHostReply {
+	result, err := h.serve(ctx, call)
+	if err != nil {
+		reply := pluginHostReply{ID: call.ID, Error: &rpcError{Message: err.Error()}}
+		if oe := asOpsOrchError(err); oe != nil {
+			reply.Error = &rpcError{Code: oe.Code, Message: oe.Message}
+		}
+		return reply
+	}
+	return pluginHostReply{ID: call.ID, Result: result}
+}
+
+func (h pluginHostCalls) serve(ctx context.Context, call rpcResponse) (any, error) {
+	switch {
+	case call.Method == pluginsdk.HostSecretGet:
+		var in struct {
+			Key string `json:"key"`
+		}
+		if err := decodeHostPayload(call, &in); err != nil {
+			return nil, err
+		}
+		if err := h.authorize(call.Method, in.Key); err != nil {
+			return nil, err
+		}
+		srv, err := h.host()
+		if err != nil {
+			return nil, err
+		}
+		sec := srv.hostSecret()
+		if sec == nil {
+			return nil, orcherr.New("secret_provider_missing", "secret provider not configured", nil)
+		}
+		return sec.Get(ctx, in.Key)
+
+	case call.Method == pluginsdk.HostAuditLog:
+		var in pluginsdk.AuditEntry
+		if err := decodeHostPayload(call, &in); err != nil {
+			return nil, err
+		}
+		if err := h.authorize(call.Method, ""); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(in.Action) == "" {
+			return nil, orcherr.New("bad_request", "audit entry requires an action", nil)
+		}
+		h.audit(in)
+		return map[string]string{"status": "ok"}, nil
+
+	case strings.HasPrefix(call.Method, pluginsdk.HostCapabilityPrefix):
+		method := strings.TrimPrefix(call.Method, pluginsdk.HostCapabilityPrefix)
+		name, _, _ := strings.Cut(method, ".")
+		capability, ok := normalizeCapability(name)
+		if !ok || capability != name {
+			return nil, orcherr.New("bad_request", fmt.Sprintf("unknown capability in host call %s", call.Method), nil)
+		}
+		if capability == h.capability {
+			// A plugin calling its own capability would wait on itself.
+			return nil, orcherr.New("bad_request", fmt.Sprintf("%s plugin cannot call its own capability", h.capability), nil)
+		}
+		if err := h.authorize(call.Method, ""); err != nil {
+			return nil, err
+		}
+		srv, err := h.host()
+		if err != nil {
+			return nil, err
+		}
+		provider := srv.capabilityProvider(capability)
+		if provider == nil {
+			return nil, orcherr.New(capability+"_provider_missing", capability+" provider not configured", nil)
+		}
+		return pluginsdk.Call(ctx, provider, method, call.Payload)
+
+	default:
+		return nil, orcherr.New("not_implemented", fmt.Sprintf("core does not implement host call %s", call.Method), nil)
+	}
+}
+
+// authorize checks a host call against the plugin's policy, auditing refusals.
+func (h pluginHostCalls) authorize(method, resource string) error {
+	if h.policy.allows(method, resource) {
+		return nil
+	}
+	details := map[string]string{"capability": h.capability, "plugin": h.plugin, "method": method}
+	if resource != "" {
+		details["resource"] = resource
+	}
+	logSystemAudit("plugin.host_denied", details)
+	slog.Warn("plugin host call denied", "capability", h.capability, "plugin", h.plugin, "method", method, "resource", resource)
+	return orcherr.New("forbidden", fmt.Sprintf("%s plugin is not allowed to call %s", h.capability, method), nil)
+}
+
+func (h pluginHostCalls) host() (pluginHost, error) {
+	if h.server == nil {
+		return nil, orcherr.New("host_unavailable", "core is not accepting host calls", nil)
+	}
+	return h.server, nil
+}
+
+// audit records an entry written by the plugin, with the plugin as the actor.
+func (h pluginHostCalls) audit(in pluginsdk.AuditEntry) {
+	requestID := in.RequestID
+	if requestID == "" {
+		requestID = fmt.Sprintf("generated-%d", time.Now().UnixNano())
+	}
+	details := map[string]string{}
+	for k, v := range in.Details {
+		details[k] = v
+	}
+	details["plugin"] = h.plugin
+	writeAudit(AuditLogEntry{
+		RequestID: requestID,
+		ActorType: "plugin",
+		ActorID:   h.capability + "-plugin",
+		Timestamp: time.Now().UTC(),
+		Action:    in.Action,
+		Details:   details,
+	})
+}
+
+func decodeHostPayload(call rpcResponse, out any) error {
+	if len(call.Payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(call.Payload, out); err != nil {
+		return orcherr.New("bad_request", fmt.Sprintf("invalid %s payload: %v", call.Method, err), err)
+	}
+	return nil
+}
+
+// hostSecret returns the secret provider plugins read through host.secret.get.
+func (s *Server) hostSecret() SecretProvider {
+	return s.secret
+}
+
+// capabilityProvider returns the provider currently serving capability, or nil when none is
+// configured.
+func (s *Server) capabilityProvider(capability string) any {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.installedProvider(capability)
+}
+
+// installedProvider is capabilityProvider for callers already holding handlersMu.
+func (s *Server) installedProvider(capability string) any {
+	var provider any
+	switch capability {
+	case "incident":
+		provider = s.incident.provider
+	case "alert":
+		provider = s.alert.provider
+	case "log":
+		provider = s.log.provider
+	case "metric":
+		provider = s.metric.provider
+	case "ticket":
+		provider = s.ticket.provider
+	case "messaging":
+		provider = s.messaging.provider
+	case "service":
+		provider = s.service.provider
+	case "deployment":
+		provider = s.deployment.provider
+	case "team":
+		provider = s.team.provider
+	case "orchestration":
+		provider = s.orchestration.provider
+	}
+	return provider
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/pluginsdk"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// hostIncidents is an SDK incident plugin that builds its answer from host calls.
+type hostIncidents struct{ stubIncidentProvider }
+
+func (hostIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	host := pluginsdk.HostFrom(ctx)
+	token, err := host.Secret(ctx, "jira/token")
+	if err != nil {
+		return schema.Incident{}, err
+	}
+	var team schema.Team
+	if err := host.Call(ctx, "team.get", map[string]string{"id": "payments"}, &team); err != nil {
+		return schema.Incident{}, err
+	}
+	if err := host.Audit(ctx, pluginsdk.AuditEntry{Action: "jira.incident.read", Details: map[string]string{"id": id}}); err != nil {
+		return schema.Incident{}, err
+	}
+	return schema.Incident{ID: id, Title: token, Service: team.Name}, nil
+}
+
+// startHostPlugin serves hostIncidents on a unix socket and returns a runner for it with the
+// given host policy, answering host calls from a server with a secret and team provider.
+func startHostPlugin(t *testing.T, allow string) *pluginRunner {
+	t.Helper()
+	sock := socketPath(t)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go pluginsdk.ServeListener(l, hostIncidents{})
+	t.Cleanup(func() { l.Close() })
+
+	teams := &mockTeamProvider{getFunc: func(ctx context.Context, id string) (schema.Team, error) {
+		return schema.Team{ID: id, Name: "Payments"}, nil
+	}}
+	srv := &Server{
+		secret: &memSecrets{values: map[string]string{"jira/token": "s3cret", "db/password": "hunter2"}},
+		team:   TeamHandler{provider: teams},
+	}
+	t.Setenv("OPSORCH_INCIDENT_PLUGIN_HOST_ALLOW", allow)
+	opts := pluginOptionsFromEnv("incident")
+	opts.server = srv
+	runner := newPluginRunner("incident", "unix://"+sock, nil, opts)
+	t.Cleanup(runner.close)
+	return runner
+}
+
+func TestPluginHostCallsAllowedByPolicy(t *testing.T) {
+	logs := captureLog(t)
+	runner := startHostPlugin(t, "secret.get:jira/*, capability.team.*, audit.log")
+
+	var inc schema.Incident
+	if err := runner.call(context.Background(), "incident.get", map[string]string{"id": "INC-1"}, &inc); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if inc.Title != "s3cret" || inc.Service != "Payments" {
+		t.Fatalf("unexpected incident built from host calls: %+v", inc)
+	}
+	out := logs.String()
+	if !strings.Contains(out, `"actor_type":"plugin","actor_id":"incident-plugin"`) || !strings.Contains(out, `"action":"jira.incident.read"`) {
+		t.Fatalf("expected plugin audit entry, got %q", out)
+	}
+}
+
+func TestPluginHostCallsDeniedByPolicy(t *testing.T) {
+	cases := map[string]string{
+		"no rules":          "",
+		"other secret keys": "secret.get:db/*,capability.team.*,audit.log",
+		"no capability":     "secret.get,audit.log",
+	}
+	for name, allow := range cases {
+		t.Run(name, func(t *testing.T) {
+			logs := captureLog(t)
+			runner := startHostPlugin(t, allow)
+
+			err := runner.call(context.Background(), "incident.get", map[string]string{"id": "INC-1"}, nil)
+			if oe := asOpsOrchError(err); oe == nil || oe.Code != "forbidden" {
+				t.Fatalf("expected forbidden, got %v", err)
+			}
+			if !strings.Contains(logs.String(), `"action":"plugin.host_denied"`) {
+				t.Fatalf("expected denied host call to be audited, got %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestPluginHostPolicy(t *testing.T) {
+	policy := pluginHostPolicy{rules: []pluginHostRule{
+		{method: "secret.get", resource: "jira/*"},
+		{method: "capability.team.*"},
+	}}
+	cases := []struct {
+		method, resource string
+		want             bool
+	}{
+		{"host.secret.get", "jira/token", true},
+		{"host.secret.get", "db/password", false},
+		{"host.capability.team.get", "", true},
+		{"host.capability.incident.get", "", false},
+		{"host.audit.log", "", false},
+	}
+	for _, c := range cases {
+		if got := policy.allows(c.method, c.resource); got != c.want {
+			t.Fatalf("allows(%s, %q) = %v, want %v", c.method, c.resource, got, c.want)
+		}
+	}
+}
+
+func TestPluginHostRejectsOwnCapability(t *testing.T) {
+	h := pluginHostCalls{capability: "team", policy: pluginHostPolicy{rules: []pluginHostRule{{method: "*"}}}}
+	reply := h.answer(context.Background(), rpcResponse{ID: 4, Method: "host.capability.team.get"})
+	if reply.ID != 4 || reply.Error == nil || reply.Error.Code != "bad_request" {
+		t.Fatalf("expected bad_request, got %+v", reply)
+	}
+}
+
+func TestPluginHostWithoutServerIsUnavailable(t *testing.T) {
+	h := pluginHostCalls{capability: "secret", policy: pluginHostPolicy{rules: []pluginHostRule{{method: "*"}}}}
+	reply := h.answer(context.Background(), rpcResponse{ID: 5, Method: "host.secret.get", Payload: json.RawMessage(`{"key":"jira/token"}`)})
+	if reply.Error == nil || reply.Error.Code != "host_unavailable" {
+		t.Fatalf("expected host_unavailable, got %+v", reply)
+	}
+}
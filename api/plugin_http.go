@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// httpPluginClient calls a plugin served over HTTP: every request frame is POSTed to the
+// endpoint and the response body holds the response frames, one per line, ending with the
+// terminal frame. The plugin runs with its own
+// lifecycle, so there is no process to supervise; an unreachable endpoint fails calls with
+// plugin_unavailable until it comes back.
+type httpPluginClient struct {
+	capability string
+	endpoint   string
+	config     map[string]any
+	timeout    time.Duration
+	client     *http.Client
+	// sendUndeclared sends methods missing from the manifest to the plugin; see pluginRunner.
+	sendUndeclared bool
+
+	nextID atomic.Uint64
+
+	mu        sync.Mutex
+	handshook bool
+	manifest  *pluginManifest
+}
+
+func newHTTPPluginClient(capability, endpoint string, config map[string]any, opts pluginOptions) *httpPluginClient {
+	return &httpPluginClient{capability: capability, endpoint: endpoint, config: config, timeout: opts.timeout, client: &http.Client{}}
+}
+
+func (c *httpPluginClient) call(ctx context.Context, method string, payload any, out any) error {
+	return c.invoke(ctx, method, payload, out, nil)
+}
+
+func (c *httpPluginClient) stream(ctx context.Context, method string, payload any, onChunk pluginChunkFunc) error {
+	return c.invoke(ctx, method, payload, nil, onChunk)
+}
+
+func (c *httpPluginClient) invoke(ctx context.Context, method string, payload any, out any, onChunk pluginChunkFunc) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	manifest, err := c.ensureHandshake(ctx)
+	if err != nil {
+		return err
+	}
+	if !c.sendUndeclared && !manifest.supports(method) {
+		return orcherr.New("not_implemented", fmt.Sprintf("%s plugin does not implement %s", c.capability, method), nil)
+	}
+	resp, err := c.post(ctx, rpcRequest{ID: c.nextID.Add(1), Method: method, Config: c.config, Payload: payload, Stream: onChunk != nil, Traceparent: traceparentFrom(ctx)}, onChunk)
+	if err != nil {
+		return err
+	}
+	return finishRPCResponse(resp, out, onChunk)
+}
+
+// ensureHandshake performs the handshake once the endpoint is reachable. Failures are not
+// cached: the plugin may be redeployed with a compatible version at any time.
+func (c *httpPluginClient) ensureHandshake(ctx context.Context) (*pluginManifest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handshook {
+		return c.manifest, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pluginHandshakeTimeout)
+	defer cancel()
+	req := rpcRequest{
+		ID:      c.nextID.Add(1),
+		Method:  pluginHandshakeMethod,
+		Config:  c.config,
+		Payload: pluginHandshakeRequest{ProtocolVersion: pluginProtocolVersion, Capability: c.capability},
+	}
+	resp, err := c.post(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		slog.WarnContext(ctx, "plugin does not support the handshake; skipping method checks", "capability", c.capability, "plugin", c.endpoint, "method", pluginHandshakeMethod)
+		c.handshook = true
+		return nil, nil
+	}
+	manifest, err := checkPluginManifest(c.capability, c.endpoint, resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	c.handshook, c.manifest = true, manifest
+	return manifest, nil
+}
+
+// post sends one request frame and returns the terminal response frame, passing chunk frames
+// before it to onChunk.
+func (c *httpPluginClient) post(ctx context.Context, req rpcRequest, onChunk pluginChunkFunc) (rpcResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return rpcResponse{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return rpcResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.Traceparent != "" {
+		httpReq.Header.Set("traceparent", req.Traceparent)
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return rpcResponse{}, pluginContextError(ctx, c.capability, req.Method, err)
+		}
+		return rpcResponse{}, orcherr.New("plugin_unavailable", fmt.Sprintf("%s plugin at %s is unreachable", c.capability, c.endpoint), err)
+	}
+	defer httpResp.Body.Close()
+
+	dec := json.NewDecoder(httpResp.Body)
+	for {
+		var resp rpcResponse
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return rpcResponse{}, pluginContextError(ctx, c.capability, req.Method, err)
+			}
+			if httpResp.StatusCode != http.StatusOK {
+				return rpcResponse{}, orcherr.New("plugin_unavailable", fmt.Sprintf("%s plugin at %s answered %s", c.capability, c.endpoint, httpResp.Status), err)
+			}
+			return rpcResponse{}, fmt.Errorf("%s plugin: read %s response: %w", c.capability, req.Method, err)
+		}
+		if resp.hostCall() {
+			// The request body is already sent, so there is no channel to answer on.
+			slog.WarnContext(ctx, "plugin host call ignored; host calls are not supported over HTTP", "capability", c.capability, "plugin", c.endpoint, "method", resp.Method)
+			continue
+		}
+		if resp.terminal() {
+			return resp, nil
+		}
+		if onChunk == nil {
+			continue
+		}
+		if err := onChunk(resp.Chunk); err != nil {
+			return rpcResponse{}, err
+		}
+	}
+}
+
+// ping handshakes with the endpoint if that has not happened yet, which proves it is reachable,
+// and asks plugins that declare plugin.ping.
+func (c *httpPluginClient) ping(ctx context.Context) error {
+	manifest, err := c.ensureHandshake(ctx)
+	if err != nil {
+		return err
+	}
+	if !manifest.declares(pluginPingMethod) {
+		return nil
+	}
+	return c.call(ctx, pluginPingMethod, nil, nil)
+}
+
+// shutdown only drops idle connections: remote plugins have their own lifecycle.
+func (c *httpPluginClient) shutdown(ctx context.Context) {
+	c.close()
+}
+
+func (c *httpPluginClient) close() {
+	c.client.CloseIdleConnections()
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// pluginMux multiplexes concurrent calls over one plugin stream. Requests carry an ID that the
+// plugin echoes back, so responses may arrive in any order. A process is only switched to this
+// mode after it has echoed an ID; plugins that ignore IDs keep the lock-step protocol.
+type pluginMux struct {
+	capability string
+	proc       *pluginProcess
+	host       pluginHostCalls
+
+	writeMu sync.Mutex // keeps request frames from interleaving on stdin
+
+	mu      sync.Mutex
+	pending map[uint64]*pendingCall
+	err     error // set once the reader stops; fails new and pending calls
+}
+
+// pluginStreamBuffer is how many chunk frames may queue for a slow consumer before the reader
+// waits for it. Streams share the plugin's stdout, so a stalled consumer eventually holds up
+// the other calls until its context ends.
+const pluginStreamBuffer = 16
+
+// pendingCall routes the frames of one call to its caller.
+type pendingCall struct {
+	frames chan rpcResponse
+	done   chan struct{} // closed when the caller stops listening
+}
+
+func newPluginMux(capability string, proc *pluginProcess, host pluginHostCalls) *pluginMux {
+	m := &pluginMux{capability: capability, proc: proc, host: host, pending: map[uint64]*pendingCall{}}
+	go m.readLoop()
+	return m
+}
+
+// call sends req and waits for the terminal frame with the same ID, passing chunk frames to
+// onChunk. A canceled call abandons its slot; late frames for it are discarded without
+// disturbing other calls.
+func (m *pluginMux) call(ctx context.Context, req rpcRequest, onChunk pluginChunkFunc) (rpcResponse, error) {
+	size := 1
+	if onChunk != nil {
+		size = pluginStreamBuffer
+	}
+	pc := &pendingCall{frames: make(chan rpcResponse, size), done: make(chan struct{})}
+	m.mu.Lock()
+	if m.err != nil {
+		err := m.err
+		m.mu.Unlock()
+		return rpcResponse{}, err
+	}
+	m.pending[req.ID] = pc
+	m.mu.Unlock()
+	defer m.forget(req.ID, pc)
+
+	m.writeMu.Lock()
+	deadline, _ := ctx.Deadline()
+	_ = m.proc.conn.SetWriteDeadline(deadline)
+	err := m.proc.enc.Encode(req)
+	m.writeMu.Unlock()
+	if err != nil {
+		// A partially written frame corrupts the stream for every caller.
+		go m.proc.terminate(err)
+		return rpcResponse{}, fmt.Errorf("%s plugin: send %s: %w", m.capability, req.Method, err)
+	}
+
+	for {
+		select {
+		case resp, ok := <-pc.frames:
+			if !ok {
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				return rpcResponse{}, m.err
+			}
+			if resp.terminal() {
+				return resp, nil
+			}
+			if onChunk == nil {
+				continue
+			}
+			if err := onChunk(resp.Chunk); err != nil {
+				return rpcResponse{}, err
+			}
+		case <-ctx.Done():
+			return rpcResponse{}, ctx.Err()
+		}
+	}
+}
+
+func (m *pluginMux) forget(id uint64, pc *pendingCall) {
+	close(pc.done)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[id] == pc {
+		delete(m.pending, id)
+	}
+}
+
+func (m *pluginMux) readLoop() {
+	for {
+		var resp rpcResponse
+		if err := m.proc.dec.Decode(&resp); err != nil {
+			m.fail(fmt.Errorf("%s plugin: read response: %w", m.capability, err))
+			m.proc.terminate(err)
+			return
+		}
+		if resp.hostCall() {
+			go m.answer(resp)
+			continue
+		}
+		m.mu.Lock()
+		pc, ok := m.pending[resp.ID]
+		if ok && resp.terminal() {
+			delete(m.pending, resp.ID)
+		}
+		m.mu.Unlock()
+		if !ok {
+			if resp.terminal() {
+				slog.Warn("plugin response dropped for an unknown or abandoned request", "capability", m.capability, "id", resp.ID)
+			}
+			continue
+		}
+		select {
+		case pc.frames <- resp:
+		case <-pc.done:
+		}
+	}
+}
+
+// answer serves a host call from the plugin and writes the reply on the request stream. Host
+// calls are not tied to one request, so each is bounded by pluginHostCallTimeout instead.
+func (m *pluginMux) answer(call rpcResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginHostCallTimeout)
+	defer cancel()
+	reply := m.host.answer(ctx, call)
+
+	m.writeMu.Lock()
+	_ = m.proc.conn.SetWriteDeadline(time.Now().Add(pluginHostCallTimeout))
+	err := m.proc.enc.Encode(reply)
+	m.writeMu.Unlock()
+	if err != nil {
+		go m.proc.terminate(err)
+	}
+}
+
+// fail closes every pending call with err and rejects new ones.
+func (m *pluginMux) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+	for id, pc := range m.pending {
+		close(pc.frames)
+		delete(m.pending, id)
+	}
+}
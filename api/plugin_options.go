@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPluginTimeout = 30 * time.Second
+
+// pluginOptions tunes how a capability's plugin binary is run. Each option is read from
+// OPSORCH_<CAP>_PLUGIN_<OPTION> next to the OPSORCH_<CAP>_PLUGIN path.
+type pluginOptions struct {
+	// timeout bounds each call; zero disables it.
+	timeout time.Duration
+	// poolSize is the number of plugin processes sharing the capability's calls.
+	poolSize int
+	// maxCalls recycles a process after it has served this many calls; zero never recycles.
+	maxCalls int
+	// healthInterval is how often pool members are probed with plugin.ping; zero disables it.
+	healthInterval time.Duration
+	// verify decides whether a local plugin binary may be executed.
+	verify pluginVerification
+	// sandbox restricts the environment and resources of local plugin processes.
+	sandbox pluginSandbox
+	// host lists the host calls the plugin may make back into core.
+	host pluginHostPolicy
+	// server answers those host calls. It is not read from the environment but set by the
+	// code constructing the plugin; nil fails every host call.
+	server pluginHost
+}
+
+// pluginOptionsFromEnv reads the plugin options for a capability. Invalid values are logged
+// and replaced by their defaults so a typo cannot disable a capability.
+//
+//	OPSORCH_<CAP>_PLUGIN_TIMEOUT    Go duration such as "45s" (default 30s, "0" disables)
+//	OPSORCH_<CAP>_PLUGIN_POOL_SIZE  number of plugin processes (default 1)
+//	OPSORCH_<CAP>_PLUGIN_MAX_CALLS  calls served before a process is recycled (default 0, never)
+//	OPSORCH_<CAP>_PLUGIN_HEALTH_INTERVAL  how often pool members are health checked (default 10s, "0" disables)
+func pluginOptionsFromEnv(capability string) pluginOptions {
+	prefix := fmt.Sprintf("OPSORCH_%s_PLUGIN_", strings.ToUpper(capability))
+	return pluginOptions{
+		timeout:        envDuration(prefix+"TIMEOUT", defaultPluginTimeout),
+		poolSize:       envInt(prefix+"POOL_SIZE", 1, 1),
+		maxCalls:       envInt(prefix+"MAX_CALLS", 0, 0),
+		healthInterval: envDuration(prefix+"HEALTH_INTERVAL", defaultPluginHealthInterval),
+		verify:         pluginVerificationFromEnv(capability),
+		sandbox:        pluginSandboxFromEnv(capability),
+		host:           pluginHostPolicyFromEnv(capability),
+	}
+}
+
+func envDuration(envVar string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(envVar))
+	if raw == "" {
+		return fallback
+	}
+	if raw == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		slog.Warn("invalid setting; using default", "env", envVar, "value", raw, "default", fallback.String())
+		return fallback
+	}
+	return d
+}
+
+func envInt(envVar string, fallback, min int) int {
+	raw := strings.TrimSpace(os.Getenv(envVar))
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min {
+		slog.Warn("invalid setting; using default", "env", envVar, "value", raw, "default", fallback)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPluginOptionsFromEnv(t *testing.T) {
+	t.Setenv("OPSORCH_LOG_PLUGIN_TIMEOUT", "2m")
+	t.Setenv("OPSORCH_LOG_PLUGIN_POOL_SIZE", "4")
+	t.Setenv("OPSORCH_LOG_PLUGIN_MAX_CALLS", "500")
+
+	opts := pluginOptionsFromEnv("log")
+	if opts.timeout != 2*time.Minute || opts.poolSize != 4 || opts.maxCalls != 500 {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestPluginOptionsDefaultsAndInvalidValues(t *testing.T) {
+	opts := pluginOptionsFromEnv("metric")
+	if opts.timeout != defaultPluginTimeout || opts.poolSize != 1 || opts.maxCalls != 0 {
+		t.Fatalf("unexpected defaults: %+v", opts)
+	}
+
+	t.Setenv("OPSORCH_METRIC_PLUGIN_TIMEOUT", "soon")
+	t.Setenv("OPSORCH_METRIC_PLUGIN_POOL_SIZE", "0")
+	t.Setenv("OPSORCH_METRIC_PLUGIN_MAX_CALLS", "-1")
+	opts = pluginOptionsFromEnv("metric")
+	if opts.timeout != defaultPluginTimeout || opts.poolSize != 1 || opts.maxCalls != 0 {
+		t.Fatalf("invalid values should fall back to defaults: %+v", opts)
+	}
+
+	t.Setenv("OPSORCH_METRIC_PLUGIN_TIMEOUT", "0")
+	if got := pluginOptionsFromEnv("metric").timeout; got != 0 {
+		t.Fatalf("expected timeout disabled, got %s", got)
+	}
+}
+
+func TestNewPluginClientUsesPoolWhenConfigured(t *testing.T) {
+	t.Setenv("OPSORCH_LOG_PLUGIN_POOL_SIZE", "3")
+	client := newPluginClient("log", "unused", nil, nil)
+	pool, ok := client.(*pluginPool)
+	if !ok || len(pool.members) != 3 {
+		t.Fatalf("expected pool of 3, got %T", client)
+	}
+
+	t.Setenv("OPSORCH_LOG_PLUGIN_POOL_SIZE", "")
+	if _, ok := newPluginClient("log", "unused", nil, nil).(*pluginRunner); !ok {
+		t.Fatalf("expected single runner by default")
+	}
+}
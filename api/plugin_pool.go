@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPluginHealthInterval = 10 * time.Second
+	// pluginProbeTimeout bounds each health probe of a pool member.
+	pluginProbeTimeout = 5 * time.Second
+	// pluginProbeFailures is how many probes in a row a member may fail before it is recycled.
+	// A single failure only takes it out of rotation, since a lock-step member busy with a long
+	// call cannot answer until the call ends.
+	pluginProbeFailures = 2
+)
+
+// pluginClient is the plugin surface used by plugin providers: either a single supervised
+// runner or a pool of them.
+type pluginClient interface {
+	call(ctx context.Context, method string, payload any, out any) error
+	stream(ctx context.Context, method string, payload any, onChunk pluginChunkFunc) error
+	// ping checks the plugin for health reports; see pluginRunner.ping.
+	ping(ctx context.Context) error
+	// shutdown asks the plugin to exit cleanly, giving up and killing it when ctx is done.
+	shutdown(ctx context.Context)
+	close()
+}
+
+// newPluginClient builds the plugin backend for a capability from its OPSORCH_<CAP>_PLUGIN_* options.
+// host answers the plugin's host calls.
+// path is a binary to exec, a unix:// socket, or an http(s):// endpoint.
+func newPluginClient(capability, path string, config map[string]any, host pluginHost) pluginClient {
+	opts := pluginOptionsFromEnv(capability)
+	opts.server = host
+	if isHTTPPluginTarget(path) {
+		return newHTTPPluginClient(capability, path, config, opts)
+	}
+	if opts.poolSize > 1 {
+		return newPluginPool(capability, path, config, opts)
+	}
+	return newPluginRunner(capability, path, config, opts)
+}
+
+// pluginPool runs several copies of one plugin binary and sends each call to the least busy
+// member. Members are supervised individually, so a crashed member is skipped while it
+// restarts and the others keep serving. Members are also probed with plugin.ping every
+// healthInterval: a member that fails a probe is skipped until it passes one, and a member that
+// keeps failing is alive but wedged, so it is recycled.
+type pluginPool struct {
+	members []*pluginRunner
+	next    atomic.Uint64 // rotates the starting member so ties spread across the pool
+
+	probeTimeout time.Duration
+	failures     []atomic.Int32 // consecutive failed probes per member
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+func newPluginPool(capability, path string, config map[string]any, opts pluginOptions) *pluginPool {
+	pool := &pluginPool{
+		members:      make([]*pluginRunner, opts.poolSize),
+		probeTimeout: pluginProbeTimeout,
+		failures:     make([]atomic.Int32, opts.poolSize),
+		stop:         make(chan struct{}),
+	}
+	for i := range pool.members {
+		pool.members[i] = newPluginRunner(capability, path, config, opts)
+	}
+	if opts.healthInterval > 0 {
+		go pool.probeLoop(opts.healthInterval)
+	}
+	return pool
+}
+
+func (p *pluginPool) probeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.probe()
+		}
+	}
+}
+
+// probe pings every member concurrently and records the outcome. Members that are waiting on
+// a restart are left to the supervisor.
+func (p *pluginPool) probe() {
+	var wg sync.WaitGroup
+	for i, m := range p.members {
+		if m.restartPending() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.probeTimeout)
+			defer cancel()
+			err := m.ping(ctx)
+			if err == nil {
+				p.failures[i].Store(0)
+				return
+			}
+			failures := p.failures[i].Add(1)
+			slog.Warn("plugin pool member failed its health check", "capability", m.capability, "plugin", m.path, "member", i, "failures", failures, "err", err)
+			if failures >= pluginProbeFailures {
+				p.failures[i].Store(0)
+				m.recycle(fmt.Errorf("plugin failed %d health checks: %w", failures, err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *pluginPool) stopProbes() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+func (p *pluginPool) call(ctx context.Context, method string, payload any, out any) error {
+	return p.pick().call(ctx, method, payload, out)
+}
+
+func (p *pluginPool) stream(ctx context.Context, method string, payload any, onChunk pluginChunkFunc) error {
+	return p.pick().stream(ctx, method, payload, onChunk)
+}
+
+// pick returns the member with the fewest in-flight calls among those neither waiting on a
+// restart nor failing health checks. When no member qualifies, the call goes to one of them and
+// fails fast or waits on it.
+func (p *pluginPool) pick() *pluginRunner {
+	start := int(p.next.Add(1) % uint64(len(p.members)))
+	var best *pluginRunner
+	for i := range p.members {
+		idx := (start + i) % len(p.members)
+		m := p.members[idx]
+		if m.restartPending() || p.failures[idx].Load() > 0 {
+			continue
+		}
+		if best == nil || m.inFlight.Load() < best.inFlight.Load() {
+			best = m
+		}
+	}
+	if best == nil {
+		best = p.members[start]
+	}
+	return best
+}
+
+// ping passes while any member does, since calls are routed around members that are down.
+func (p *pluginPool) ping(ctx context.Context) error {
+	var err error
+	for _, m := range p.members {
+		if err = m.ping(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *pluginPool) shutdown(ctx context.Context) {
+	p.stopProbes()
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *pluginRunner) {
+			defer wg.Done()
+			m.shutdown(ctx)
+		}(m)
+	}
+	wg.Wait()
+}
+
+func (p *pluginPool) close() {
+	p.stopProbes()
+	for _, m := range p.members {
+		m.close()
+	}
+}
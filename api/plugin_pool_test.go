@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPluginPoolPicksLeastBusyMember(t *testing.T) {
+	pool := newPluginPool("log", "unused", nil, pluginOptions{poolSize: 3})
+	pool.members[0].inFlight.Store(2)
+	pool.members[1].inFlight.Store(1)
+	pool.members[2].inFlight.Store(4)
+
+	for i := 0; i < 5; i++ {
+		if got := pool.pick(); got != pool.members[1] {
+			t.Fatalf("expected least busy member 1, got %+v", got)
+		}
+	}
+}
+
+func TestPluginPoolSkipsRestartingMembers(t *testing.T) {
+	pool := newPluginPool("log", "unused", nil, pluginOptions{poolSize: 2})
+	pool.members[0].state.restarting = true
+	pool.members[1].inFlight.Store(10)
+
+	for i := 0; i < 4; i++ {
+		if got := pool.pick(); got != pool.members[1] {
+			t.Fatalf("expected healthy member despite load")
+		}
+	}
+}
+
+func TestPluginPoolSpreadsConcurrentCalls(t *testing.T) {
+	// Each process answers with its PID and holds the call briefly so concurrent calls overlap.
+	script := writePluginScript(t, `while read -r line; do sleep 0.2; echo "{\"result\":\"$$\"}"; done
+`)
+	pool := newPluginPool("log", script, nil, pluginOptions{poolSize: 2, timeout: 5 * time.Second})
+	defer pool.close()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		pids = map[string]bool{}
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var pid string
+			if err := pool.call(context.Background(), "log.query", nil, &pid); err != nil {
+				t.Errorf("call: %v", err)
+				return
+			}
+			mu.Lock()
+			pids[pid] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(pids) != 2 {
+		t.Fatalf("expected concurrent calls on two processes, got %v", pids)
+	}
+}
+
+func TestPluginRunnerRecyclesAfterMaxCalls(t *testing.T) {
+	captureLog(t)
+	script := writePluginScript(t, `while read -r line; do echo "{\"result\":\"$$\"}"; done
+`)
+	runner := newPluginRunner("log", script, nil, pluginOptions{maxCalls: 2})
+	defer runner.close()
+
+	pids := make([]string, 3)
+	for i := range pids {
+		if err := runner.call(context.Background(), "log.query", nil, &pids[i]); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if pids[0] != pids[1] {
+		t.Fatalf("expected first two calls on the same process, got %v", pids)
+	}
+	if pids[2] == pids[1] {
+		t.Fatalf("expected a fresh process after max calls, got %v", pids)
+	}
+	if h := runner.health(); !h.Healthy || h.Restarts != 0 {
+		t.Fatalf("recycling must not count as a crash, got %+v", h)
+	}
+}
+
+func TestPluginPoolRecyclesWedgedMembers(t *testing.T) {
+	logs := captureLog(t)
+	handshake := shellIDHelpers + `read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\",\"capability\":\"log\",\"methods\":[\"log.query\",\"plugin.ping\"]}}"
+`
+	// Serves queries but never answers a health check.
+	wedged := writePluginScript(t, handshake+`while read -r line; do
+  if [ "$(method_of "$line")" = "plugin.ping" ]; then sleep 60; fi
+  echo "{\"id\":$(id_of "$line"),\"result\":null}"
+done
+`)
+	healthy := writePluginScript(t, handshake+`while read -r line; do echo "{\"id\":$(id_of "$line"),\"result\":null}"; done
+`)
+	opts := pluginOptions{poolSize: 2, timeout: 5 * time.Second}
+	pool := newPluginPool("log", healthy, nil, opts)
+	pool.members[0] = newPluginRunner("log", wedged, nil, opts)
+	pool.probeTimeout = 100 * time.Millisecond
+	defer pool.close()
+	for _, m := range pool.members {
+		if err := m.call(context.Background(), "log.query", nil, nil); err != nil {
+			t.Fatalf("call: %v", err)
+		}
+	}
+
+	pool.probe()
+	pool.members[1].inFlight.Store(10)
+	for i := 0; i < 4; i++ {
+		if got := pool.pick(); got != pool.members[1] {
+			t.Fatalf("expected the member failing health checks to be skipped")
+		}
+	}
+
+	pool.probe()
+	waitFor(t, 5*time.Second, func() bool { return pool.members[0].restartPending() || pool.members[0].health().Restarts > 0 })
+	if !strings.Contains(logs.String(), "plugin failed 2 health checks") {
+		t.Fatalf("expected the wedged member to be recycled, got %q", logs.String())
+	}
+}
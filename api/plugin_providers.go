@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/opsorch/opsorch-core/schema"
 )
@@ -9,11 +10,19 @@ import (
 // Alert plugin provider -------------------------------------------------------
 
 type alertPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
 }
 
-func newAlertPluginProvider(path string, cfg map[string]any) alertPluginProvider {
-	return alertPluginProvider{runner: newPluginRunner(path, cfg)}
+func newAlertPluginProvider(path string, cfg map[string]any, host pluginHost) alertPluginProvider {
+	return alertPluginProvider{runner: newPluginClient("alert", path, cfg, host)}
+}
+
+func (p alertPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p alertPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p alertPluginProvider) Query(ctx context.Context, query schema.AlertQuery) ([]schema.Alert, error) {
@@ -29,11 +38,19 @@ func (p alertPluginProvider) Get(ctx context.Context, id string) (schema.Alert,
 // Incident plugin provider ----------------------------------------------------
 
 type incidentPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newIncidentPluginProvider(path string, cfg map[string]any, host pluginHost) incidentPluginProvider {
+	return incidentPluginProvider{runner: newPluginClient("incident", path, cfg, host)}
 }
 
-func newIncidentPluginProvider(path string, cfg map[string]any) incidentPluginProvider {
-	return incidentPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p incidentPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p incidentPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p incidentPluginProvider) Query(ctx context.Context, query schema.IncidentQuery) ([]schema.Incident, error) {
@@ -75,11 +92,19 @@ func (p incidentPluginProvider) AppendTimeline(ctx context.Context, id string, e
 // Log plugin provider ---------------------------------------------------------
 
 type logPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newLogPluginProvider(path string, cfg map[string]any, host pluginHost) logPluginProvider {
+	return logPluginProvider{runner: newPluginClient("log", path, cfg, host)}
+}
+
+func (p logPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
 }
 
-func newLogPluginProvider(path string, cfg map[string]any) logPluginProvider {
-	return logPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p logPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p logPluginProvider) Query(ctx context.Context, query schema.LogQuery) (schema.LogEntries, error) {
@@ -87,14 +112,32 @@ func (p logPluginProvider) Query(ctx context.Context, query schema.LogQuery) (sc
 	return res, p.runner.call(ctx, "log.query", query, &res)
 }
 
+func (p logPluginProvider) QueryStream(ctx context.Context, query schema.LogQuery, send func(schema.LogEntries) error) error {
+	return p.runner.stream(ctx, "log.query", query, func(chunk json.RawMessage) error {
+		var batch schema.LogEntries
+		if err := json.Unmarshal(chunk, &batch); err != nil {
+			return err
+		}
+		return send(batch)
+	})
+}
+
 // Metric plugin provider ------------------------------------------------------
 
 type metricPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newMetricPluginProvider(path string, cfg map[string]any, host pluginHost) metricPluginProvider {
+	return metricPluginProvider{runner: newPluginClient("metric", path, cfg, host)}
+}
+
+func (p metricPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
 }
 
-func newMetricPluginProvider(path string, cfg map[string]any) metricPluginProvider {
-	return metricPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p metricPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p metricPluginProvider) Query(ctx context.Context, query schema.MetricQuery) ([]schema.MetricSeries, error) {
@@ -102,6 +145,16 @@ func (p metricPluginProvider) Query(ctx context.Context, query schema.MetricQuer
 	return res, p.runner.call(ctx, "metric.query", query, &res)
 }
 
+func (p metricPluginProvider) QueryStream(ctx context.Context, query schema.MetricQuery, send func([]schema.MetricSeries) error) error {
+	return p.runner.stream(ctx, "metric.query", query, func(chunk json.RawMessage) error {
+		var batch []schema.MetricSeries
+		if err := json.Unmarshal(chunk, &batch); err != nil {
+			return err
+		}
+		return send(batch)
+	})
+}
+
 func (p metricPluginProvider) Describe(ctx context.Context, scope schema.QueryScope) ([]schema.MetricDescriptor, error) {
 	var res []schema.MetricDescriptor
 	return res, p.runner.call(ctx, "metric.describe", scope, &res)
@@ -110,11 +163,19 @@ func (p metricPluginProvider) Describe(ctx context.Context, scope schema.QuerySc
 // Ticket plugin provider ------------------------------------------------------
 
 type ticketPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newTicketPluginProvider(path string, cfg map[string]any, host pluginHost) ticketPluginProvider {
+	return ticketPluginProvider{runner: newPluginClient("ticket", path, cfg, host)}
+}
+
+func (p ticketPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
 }
 
-func newTicketPluginProvider(path string, cfg map[string]any) ticketPluginProvider {
-	return ticketPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p ticketPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p ticketPluginProvider) Query(ctx context.Context, query schema.TicketQuery) ([]schema.Ticket, error) {
@@ -141,11 +202,19 @@ func (p ticketPluginProvider) Update(ctx context.Context, id string, in schema.U
 // Messaging plugin provider ---------------------------------------------------
 
 type messagingPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
 }
 
-func newMessagingPluginProvider(path string, cfg map[string]any) messagingPluginProvider {
-	return messagingPluginProvider{runner: newPluginRunner(path, cfg)}
+func newMessagingPluginProvider(path string, cfg map[string]any, host pluginHost) messagingPluginProvider {
+	return messagingPluginProvider{runner: newPluginClient("messaging", path, cfg, host)}
+}
+
+func (p messagingPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p messagingPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p messagingPluginProvider) Send(ctx context.Context, msg schema.Message) (schema.MessageResult, error) {
@@ -156,11 +225,19 @@ func (p messagingPluginProvider) Send(ctx context.Context, msg schema.Message) (
 // Service plugin provider ----------------------------------------------------
 
 type servicePluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newServicePluginProvider(path string, cfg map[string]any, host pluginHost) servicePluginProvider {
+	return servicePluginProvider{runner: newPluginClient("service", path, cfg, host)}
 }
 
-func newServicePluginProvider(path string, cfg map[string]any) servicePluginProvider {
-	return servicePluginProvider{runner: newPluginRunner(path, cfg)}
+func (p servicePluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p servicePluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p servicePluginProvider) Query(ctx context.Context, query schema.ServiceQuery) ([]schema.Service, error) {
@@ -171,11 +248,19 @@ func (p servicePluginProvider) Query(ctx context.Context, query schema.ServiceQu
 // Secret plugin provider -----------------------------------------------------
 
 type secretPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newSecretPluginProvider(path string, cfg map[string]any, host pluginHost) secretPluginProvider {
+	return secretPluginProvider{runner: newPluginClient("secret", path, cfg, host)}
+}
+
+func (p secretPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
 }
 
-func newSecretPluginProvider(path string, cfg map[string]any) secretPluginProvider {
-	return secretPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p secretPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p secretPluginProvider) Get(ctx context.Context, key string) (string, error) {
@@ -191,11 +276,19 @@ func (p secretPluginProvider) Put(ctx context.Context, key, value string) error
 // Deployment plugin provider -------------------------------------------------
 
 type deploymentPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
 }
 
-func newDeploymentPluginProvider(path string, cfg map[string]any) deploymentPluginProvider {
-	return deploymentPluginProvider{runner: newPluginRunner(path, cfg)}
+func newDeploymentPluginProvider(path string, cfg map[string]any, host pluginHost) deploymentPluginProvider {
+	return deploymentPluginProvider{runner: newPluginClient("deployment", path, cfg, host)}
+}
+
+func (p deploymentPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p deploymentPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p deploymentPluginProvider) Query(ctx context.Context, query schema.DeploymentQuery) ([]schema.Deployment, error) {
@@ -211,11 +304,19 @@ func (p deploymentPluginProvider) Get(ctx context.Context, id string) (schema.De
 // Team plugin provider -------------------------------------------------------
 
 type teamPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newTeamPluginProvider(path string, cfg map[string]any, host pluginHost) teamPluginProvider {
+	return teamPluginProvider{runner: newPluginClient("team", path, cfg, host)}
 }
 
-func newTeamPluginProvider(path string, cfg map[string]any) teamPluginProvider {
-	return teamPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p teamPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
+}
+
+func (p teamPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p teamPluginProvider) Query(ctx context.Context, query schema.TeamQuery) ([]schema.Team, error) {
@@ -236,11 +337,19 @@ func (p teamPluginProvider) Members(ctx context.Context, teamID string) ([]schem
 // Orchestration plugin provider ----------------------------------------------
 
 type orchestrationPluginProvider struct {
-	runner *pluginRunner
+	runner pluginClient
+}
+
+func newOrchestrationPluginProvider(path string, cfg map[string]any, host pluginHost) orchestrationPluginProvider {
+	return orchestrationPluginProvider{runner: newPluginClient("orchestration", path, cfg, host)}
+}
+
+func (p orchestrationPluginProvider) shutdown(ctx context.Context) {
+	p.runner.shutdown(ctx)
 }
 
-func newOrchestrationPluginProvider(path string, cfg map[string]any) orchestrationPluginProvider {
-	return orchestrationPluginProvider{runner: newPluginRunner(path, cfg)}
+func (p orchestrationPluginProvider) Ping(ctx context.Context) error {
+	return p.runner.ping(ctx)
 }
 
 func (p orchestrationPluginProvider) QueryPlans(ctx context.Context, query schema.OrchestrationPlanQuery) ([]schema.OrchestrationPlan, error) {
@@ -3,78 +3,259 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/opsorch/opsorch-core/orcherr"
 )
 
-// pluginRunner executes a local plugin binary per call, passing config and payload via stdin JSON.
+// pluginRunner executes a local plugin binary, passing config and payload via stdin JSON.
+// The process is kept alive across calls and supervised: if it crashes or its stream breaks,
+// it is marked unhealthy and restarted with exponential backoff. Each request frame carries
+// an ID; plugins that echo it back are switched to concurrent, out-of-order dispatch.
 type pluginRunner struct {
-	path   string
-	config map[string]any
+	capability string
+	path       string
+	config     map[string]any
+	timeout    time.Duration // per-call bound; zero disables it
+	maxCalls   int           // calls per process before it is recycled; zero never recycles
+	verify     pluginVerification
+	sandbox    pluginSandbox
+	host       pluginHostCalls
+	// sendUndeclared sends methods missing from the manifest to the plugin instead of
+	// answering not_implemented, so conformance checks see the plugin's own answer.
+	sendUndeclared bool
 
-	mu  sync.Mutex
-	cmd *exec.Cmd
-	enc *json.Encoder
-	dec *json.Decoder
+	backoffInitial time.Duration
+	backoffMax     time.Duration
+
+	sem      chan struct{} // one-slot lock guarding proc, workDir, and lock-step exchanges
+	proc     *pluginProcess
+	live     atomic.Pointer[pluginProcess] // proc, readable without the stream lock
+	workDir  string                        // private working directory created for the plugin, if any
+	nextID   atomic.Uint64
+	inFlight atomic.Int64 // calls queued or running, used for least-busy pool dispatch
+
+	stateMu sync.Mutex
+	state   pluginSupervisorState
 }
 
-func newPluginRunner(path string, config map[string]any) *pluginRunner {
+func newPluginRunner(capability, path string, config map[string]any, opts pluginOptions) *pluginRunner {
 	if config == nil {
 		config = map[string]any{}
 	}
-	return &pluginRunner{path: path, config: config}
+	return &pluginRunner{
+		capability:     capability,
+		path:           path,
+		config:         config,
+		timeout:        opts.timeout,
+		maxCalls:       opts.maxCalls,
+		verify:         opts.verify,
+		sandbox:        opts.sandbox,
+		host:           pluginHostCalls{capability: capability, plugin: path, policy: opts.host, server: opts.server},
+		backoffInitial: defaultPluginBackoffInitial,
+		backoffMax:     defaultPluginBackoffMax,
+		sem:            make(chan struct{}, 1),
+	}
 }
 
 type rpcRequest struct {
+	ID      uint64         `json:"id,omitempty"`
 	Method  string         `json:"method"`
 	Config  map[string]any `json:"config"`
 	Payload any            `json:"payload"`
+	Stream  bool           `json:"stream,omitempty"` // the caller accepts chunk frames before the result
+	// Traceparent is the W3C trace context of the provider call, so plugins can continue the trace.
+	Traceparent string `json:"traceparent,omitempty"`
 }
 
+// rpcResponse is one frame from a plugin. A frame carrying a chunk is a partial result of a
+// streamed call; every call ends with exactly one terminal frame without a chunk. A frame
+// carrying a method is not a response at all but a host call from the plugin to core.
 type rpcResponse struct {
+	ID     uint64          `json:"id,omitempty"`
+	Chunk  json.RawMessage `json:"chunk,omitempty"`
 	Result json.RawMessage `json:"result,omitempty"`
 	Error  *rpcError       `json:"error,omitempty"`
+
+	Method  string          `json:"method,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
+func (resp rpcResponse) terminal() bool {
+	return resp.Chunk == nil && !resp.hostCall()
+}
+
+func (resp rpcResponse) hostCall() bool {
+	return resp.Method != ""
+}
+
+// pluginChunkFunc receives each partial result of a streamed call, in order. Returning an
+// error abandons the call.
+type pluginChunkFunc func(chunk json.RawMessage) error
+
 type rpcError struct {
 	Code    string `json:"code,omitempty"`
 	Message string `json:"message"`
 }
 
+// UnmarshalJSON also accepts the bare string errors written by older plugins.
+func (e *rpcError) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		*e = rpcError{Message: msg}
+		return nil
+	}
+	type plain rpcError
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// call sends one request and waits for its response, bounded by ctx and the runner's timeout.
+// Lock-step plugins serve one call at a time; an interrupted exchange kills the process so the
+// supervisor respawns it with a clean stream. Plugins that echo request IDs are multiplexed.
 func (r *pluginRunner) call(ctx context.Context, method string, payload any, out any) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	return r.invoke(ctx, method, payload, out, nil)
+}
+
+// stream is call for results that may arrive in chunks. The plugin's chunks are passed to
+// onChunk as they arrive; a plugin that answers with a single result delivers it as one chunk.
+func (r *pluginRunner) stream(ctx context.Context, method string, payload any, onChunk pluginChunkFunc) error {
+	return r.invoke(ctx, method, payload, nil, onChunk)
+}
+
+func (r *pluginRunner) invoke(ctx context.Context, method string, payload any, out any, onChunk pluginChunkFunc) error {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	r.inFlight.Add(1)
+	selfMetrics.pluginQueue.add(1, r.capability, r.path)
+	defer func() {
+		r.inFlight.Add(-1)
+		selfMetrics.pluginQueue.add(-1, r.capability, r.path)
+	}()
 
-	if r.cmd == nil {
-		// Keep plugin process alive across calls; don't tie its lifetime to the request context.
-		cmd := exec.CommandContext(context.Background(), r.path)
-		stdin, err := cmd.StdinPipe()
+	if err := r.acquire(ctx); err != nil {
+		return r.contextError(ctx, method, err)
+	}
+	proc, err := r.ensureProcess(ctx)
+	if err != nil {
+		r.release()
+		return err
+	}
+	if !r.sendUndeclared && !proc.manifest.supports(method) {
+		r.release()
+		return orcherr.New("not_implemented", fmt.Sprintf("%s plugin does not implement %s", r.capability, method), nil)
+	}
+	proc.active.Add(1)
+	defer proc.finishCall()
+	proc.calls++
+	if r.maxCalls > 0 && proc.calls >= r.maxCalls {
+		r.retire(proc)
+	}
+	req := rpcRequest{ID: r.nextID.Add(1), Method: method, Config: r.config, Payload: payload, Stream: onChunk != nil, Traceparent: traceparentFrom(ctx)}
+
+	if proc.mux != nil {
+		r.release()
+		resp, err := proc.mux.call(ctx, req, onChunk)
 		if err != nil {
+			if ctx.Err() != nil {
+				return r.contextError(ctx, method, err)
+			}
 			return err
 		}
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return err
+		r.markHealthy(proc)
+		return finishRPCResponse(resp, out, onChunk)
+	}
+
+	defer r.release()
+	resp, err := r.exchange(ctx, proc, req, onChunk)
+	if err != nil {
+		return err
+	}
+	r.markHealthy(proc)
+	if resp.ID == req.ID {
+		// The plugin echoes IDs, so later calls can share the stream concurrently.
+		proc.setDeadline(time.Time{})
+		proc.mux = newPluginMux(r.capability, proc, r.host)
+	}
+	return finishRPCResponse(resp, out, onChunk)
+}
+
+// exchange performs one lock-step round trip and returns the terminal frame, passing any chunk
+// frames before it to onChunk. The caller holds the stream lock.
+func (r *pluginRunner) exchange(ctx context.Context, proc *pluginProcess, req rpcRequest, onChunk pluginChunkFunc) (rpcResponse, error) {
+	deadline, _ := ctx.Deadline()
+	proc.setDeadline(deadline)
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		proc.interrupt()
+		close(interrupted)
+	})
+	defer func() {
+		if !stop() {
+			<-interrupted
 		}
-		if err := cmd.Start(); err != nil {
-			return err
+	}()
+
+	if err := proc.enc.Encode(req); err != nil {
+		proc.terminate(err)
+		if ctx.Err() != nil {
+			return rpcResponse{}, r.contextError(ctx, req.Method, err)
 		}
-		r.cmd = cmd
-		r.enc = json.NewEncoder(stdin)
-		r.dec = json.NewDecoder(stdout)
+		return rpcResponse{}, fmt.Errorf("%s plugin: send %s: %w", r.capability, req.Method, err)
 	}
 
-	if err := r.enc.Encode(rpcRequest{Method: method, Config: r.config, Payload: payload}); err != nil {
-		return err
+	for {
+		var resp rpcResponse
+		if err := proc.dec.Decode(&resp); err != nil {
+			// A failed decode leaves the stream at an unknown offset; recycle the process.
+			proc.terminate(err)
+			if ctx.Err() != nil {
+				return rpcResponse{}, r.contextError(ctx, req.Method, err)
+			}
+			return rpcResponse{}, fmt.Errorf("%s plugin: read %s response: %w", r.capability, req.Method, err)
+		}
+		if resp.hostCall() {
+			if err := proc.enc.Encode(r.host.answer(ctx, resp)); err != nil {
+				proc.terminate(err)
+				return rpcResponse{}, fmt.Errorf("%s plugin: answer %s: %w", r.capability, resp.Method, err)
+			}
+			continue
+		}
+		if resp.terminal() {
+			return resp, nil
+		}
+		if onChunk == nil {
+			continue
+		}
+		if err := onChunk(resp.Chunk); err != nil {
+			// The rest of the stream is still in flight; recycle the process.
+			proc.terminate(err)
+			return rpcResponse{}, err
+		}
 	}
+}
 
-	var resp rpcResponse
-	if err := r.dec.Decode(&resp); err != nil {
-		return err
+// finishRPCResponse decodes a terminal frame. For streamed calls a terminal result is the
+// final chunk.
+func finishRPCResponse(resp rpcResponse, out any, onChunk pluginChunkFunc) error {
+	if onChunk == nil || resp.Error != nil {
+		return decodeRPCResponse(resp, out)
 	}
+	if len(resp.Result) == 0 || string(resp.Result) == "null" {
+		return nil
+	}
+	return onChunk(resp.Result)
+}
+
+func decodeRPCResponse(resp rpcResponse, out any) error {
 	if resp.Error != nil {
 		if resp.Error.Code != "" {
 			return orcherr.New(resp.Error.Code, resp.Error.Message, nil)
@@ -88,3 +269,93 @@ func (r *pluginRunner) call(ctx context.Context, method string, payload any, out
 	}
 	return nil
 }
+
+// contextError converts an expired or canceled call context into an OpsOrchError.
+func (r *pluginRunner) contextError(ctx context.Context, method string, err error) error {
+	return pluginContextError(ctx, r.capability, method, err)
+}
+
+func pluginContextError(ctx context.Context, capability, method string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return orcherr.New("timeout", fmt.Sprintf("%s plugin did not answer %s in time", capability, method), err)
+	}
+	return orcherr.New("canceled", fmt.Sprintf("%s plugin call %s was canceled", capability, method), err)
+}
+
+// acquire takes the stream lock, giving up when ctx is done.
+func (r *pluginRunner) acquire(ctx context.Context) error {
+	select {
+	case r.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *pluginRunner) release() {
+	<-r.sem
+}
+
+// setProc replaces the current process. The caller holds the stream lock.
+func (r *pluginRunner) setProc(proc *pluginProcess) {
+	r.proc = proc
+	r.live.Store(proc)
+}
+
+// recycle kills the current process without waiting for the stream lock, so a call wedged on
+// it is interrupted. The supervisor restarts the plugin as it would after a crash.
+func (r *pluginRunner) recycle(cause error) {
+	if proc := r.live.Load(); proc != nil {
+		proc.terminate(cause)
+	}
+}
+
+// ensureProcess returns the live plugin process, starting it on first use. While a crashed
+// plugin is waiting out its restart backoff, calls fail fast with plugin_unavailable; a plugin
+// rejected by the handshake keeps failing with its plugin_incompatible error.
+func (r *pluginRunner) ensureProcess(ctx context.Context) (*pluginProcess, error) {
+	r.stateMu.Lock()
+	closed, fatal := r.state.closed, r.state.fatal
+	r.stateMu.Unlock()
+	if closed {
+		return nil, orcherr.New("plugin_unavailable", fmt.Sprintf("%s plugin is shut down", r.capability), nil)
+	}
+	if fatal != nil {
+		return nil, fatal
+	}
+	if r.proc != nil && r.proc.alive() {
+		return r.proc, nil
+	}
+	if r.proc != nil || r.restartPending() {
+		return nil, orcherr.New("plugin_unavailable", fmt.Sprintf("%s plugin is restarting", r.capability), nil)
+	}
+
+	proc, err := r.start(ctx)
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if err != nil {
+		if isFatalPluginError(err) {
+			r.state.fatal = err
+			r.state.lastError = err.Error()
+			slog.ErrorContext(ctx, "plugin rejected", "capability", r.capability, "plugin", r.path, "err", err)
+			return nil, err
+		}
+		r.scheduleRestart(nil, err)
+		return nil, err
+	}
+	r.setProc(proc)
+	r.state.running = true
+	r.state.restarting = false
+	return proc, nil
+}
+
+// retire detaches proc so the next call starts a fresh process. proc finishes the calls it
+// already accepted and is then shut down. The caller holds the stream lock.
+func (r *pluginRunner) retire(proc *pluginProcess) {
+	proc.detached.Store(true)
+	r.setProc(nil)
+	r.stateMu.Lock()
+	r.state.running = false
+	r.stateMu.Unlock()
+	slog.Info("plugin recycling", "capability", r.capability, "plugin", r.path, "calls", proc.calls)
+}
@@ -1,27 +1,83 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/opsorch/opsorch-core/schema"
 )
 
+// mockPluginDir holds the mock plugin binaries built for this test run. They share one build
+// cache, so each mock is compiled once however many tests use it.
+var (
+	mockPluginDir    string
+	mockPluginBuilds sync.Map // name -> *mockPluginBuild
+)
+
+type mockPluginBuild struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if mockPluginDir != "" {
+		os.RemoveAll(mockPluginDir)
+	}
+	os.Exit(code)
+}
+
+// buildMockPlugin compiles ./plugins/<name> and returns the binary path.
+func buildMockPlugin(t *testing.T, name string) string {
+	t.Helper()
+	v, _ := mockPluginBuilds.LoadOrStore(name, &mockPluginBuild{})
+	b := v.(*mockPluginBuild)
+	b.once.Do(func() {
+		dir, err := mockPluginCacheDir()
+		if err != nil {
+			b.err = err
+			return
+		}
+		b.path = filepath.Join(dir, name)
+		build := exec.Command("go", "build", "-o", b.path, "../plugins/"+name)
+		build.Env = append(os.Environ(), "GOCACHE="+filepath.Join(dir, "gocache"), "GOMODCACHE="+filepath.Join(dir, "gomodcache"), "CGO_ENABLED=0")
+		if out, err := build.CombinedOutput(); err != nil {
+			b.err = fmt.Errorf("%v output=%s", err, out)
+		}
+	})
+	if b.err != nil {
+		t.Fatalf("build %s plugin: %v", name, b.err)
+	}
+	return b.path
+}
+
+var (
+	mockPluginDirOnce sync.Once
+	mockPluginDirErr  error
+)
+
+func mockPluginCacheDir() (string, error) {
+	mockPluginDirOnce.Do(func() {
+		mockPluginDir, mockPluginDirErr = os.MkdirTemp("", "opsorch-mock-plugins")
+	})
+	return mockPluginDir, mockPluginDirErr
+}
+
 // Ensure the plugin runner keeps the plugin process alive across requests even when
 // individual request contexts are canceled.
 func TestPluginRunnerSurvivesContextCancel(t *testing.T) {
-	tmp := t.TempDir()
-	pluginPath := filepath.Join(tmp, "incidentmock")
-	build := exec.Command("go", "build", "-o", pluginPath, "../plugins/incidentmock")
-	build.Env = append(os.Environ(), "GOCACHE="+filepath.Join(tmp, "gocache"), "GOMODCACHE="+filepath.Join(tmp, "gomodcache"), "CGO_ENABLED=0")
-	if out, err := build.CombinedOutput(); err != nil {
-		t.Fatalf("build plugin: %v output=%s", err, string(out))
-	}
+	pluginPath := buildMockPlugin(t, "incidentmock")
 
-	runner := newPluginRunner(pluginPath, nil)
+	runner := newPluginRunner("incident", pluginPath, nil, pluginOptions{})
 
 	ctx1, cancel1 := context.WithCancel(context.Background())
 	var res1 []schema.Incident
@@ -40,3 +96,264 @@ func TestPluginRunnerSurvivesContextCancel(t *testing.T) {
 		t.Fatalf("unexpected plugin response: %+v", res2)
 	}
 }
+
+// writePluginScript writes an executable shell plugin used to simulate misbehaving adapters.
+func writePluginScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("write plugin script: %v", err)
+	}
+	return path
+}
+
+// shellIDHelpers extracts the request ID and method from a request frame in shell plugins.
+const shellIDHelpers = `id_of() { echo "$1" | sed 's/^{"id":\([0-9]*\),.*/\1/'; }
+method_of() { echo "$1" | sed 's/.*"method":"\([^"]*\)".*/\1/'; }
+`
+
+// syncBuffer is a goroutine-safe log sink for asserting on supervisor output.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	setLogOutput(buf, buf)
+	t.Cleanup(func() { setLogOutput(os.Stderr, os.Stderr) })
+	return buf
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPluginRunnerRestartsCrashedPlugin(t *testing.T) {
+	logs := captureLog(t)
+	// The first instance crashes after one request; restarted instances keep serving.
+	script := writePluginScript(t, `if [ -e "$0.crashed" ]; then
+  while read -r line; do echo '{"result":[{"id":"p1"}]}'; done
+  exit 0
+fi
+touch "$0.crashed"
+read -r handshake
+echo '{"error":"unknown method plugin.handshake"}'
+read -r line
+echo "handled one request" >&2
+echo '{"result":[{"id":"p1"}]}'
+exit 3
+`)
+	runner := newPluginRunner("incident", script, map[string]any{"token": "x"}, pluginOptions{})
+	runner.backoffInitial = 10 * time.Millisecond
+	defer runner.close()
+
+	var res []schema.Incident
+	if err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, &res); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "p1" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	waitFor(t, 2*time.Second, func() bool {
+		h := runner.health()
+		return h.Restarts == 1 && h.Running
+	})
+	if h := runner.health(); h.Healthy || !strings.Contains(h.LastError, "exit status 3") {
+		t.Fatalf("expected unhealthy plugin with exit error, got %+v", h)
+	}
+
+	if err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, &res); err != nil {
+		t.Fatalf("call after restart: %v", err)
+	}
+	if h := runner.health(); !h.Healthy {
+		t.Fatalf("expected plugin healthy after successful call, got %+v", h)
+	}
+	if !strings.Contains(logs.String(), `"msg":"plugin_stderr","capability":"incident","line":"handled one request"`) {
+		t.Fatalf("expected plugin stderr in logs, got %q", logs.String())
+	}
+}
+
+func TestPluginRunnerUnavailableDuringBackoff(t *testing.T) {
+	captureLog(t)
+	script := writePluginScript(t, "exit 1\n")
+	runner := newPluginRunner("log", script, nil, pluginOptions{})
+	runner.backoffInitial = time.Hour
+	defer runner.close()
+
+	if err := runner.call(context.Background(), "log.query", schema.LogQuery{}, nil); err == nil {
+		t.Fatalf("expected error from crashing plugin")
+	}
+	waitFor(t, 2*time.Second, runner.restartPending)
+
+	err := runner.call(context.Background(), "log.query", schema.LogQuery{}, nil)
+	oe := asOpsOrchError(err)
+	if oe == nil || oe.Code != "plugin_unavailable" {
+		t.Fatalf("expected plugin_unavailable, got %v", err)
+	}
+}
+
+func TestPluginRunnerBackoff(t *testing.T) {
+	runner := newPluginRunner("metric", "unused", nil, pluginOptions{})
+	runner.backoffInitial = 100 * time.Millisecond
+	runner.backoffMax = time.Second
+
+	cases := map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	}
+	for failures, want := range cases {
+		if got := runner.backoff(failures); got != want {
+			t.Fatalf("backoff(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
+
+func TestPluginRunnerTimeoutRecoversStream(t *testing.T) {
+	captureLog(t)
+	// The first instance swallows requests without answering; restarted instances reply.
+	script := writePluginScript(t, `if [ -e "$0.hung" ]; then
+  while read -r line; do echo '{"result":[{"id":"p1"}]}'; done
+  exit 0
+fi
+touch "$0.hung"
+read -r handshake
+echo '{"error":"unknown method plugin.handshake"}'
+while read -r line; do :; done
+`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{})
+	runner.timeout = 100 * time.Millisecond
+	runner.backoffInitial = 10 * time.Millisecond
+	defer runner.close()
+
+	start := time.Now()
+	err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, nil)
+	oe := asOpsOrchError(err)
+	if oe == nil || oe.Code != "timeout" {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("timeout took too long: %s", elapsed)
+	}
+
+	waitFor(t, 2*time.Second, func() bool { return runner.health().Restarts == 1 })
+	var res []schema.Incident
+	if err := runner.call(context.Background(), "incident.query", schema.IncidentQuery{}, &res); err != nil {
+		t.Fatalf("call after recovery: %v", err)
+	}
+	if len(res) != 1 || res[0].ID != "p1" {
+		t.Fatalf("unexpected response after recovery: %+v", res)
+	}
+}
+
+func TestPluginRunnerHonorsContextWhileQueued(t *testing.T) {
+	runner := newPluginRunner("log", "unused", nil, pluginOptions{})
+	if err := runner.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer runner.release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := runner.call(ctx, "log.query", schema.LogQuery{}, nil)
+	oe := asOpsOrchError(err)
+	if oe == nil || oe.Code != "canceled" {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+}
+
+func TestPluginRunnerMultiplexesOutOfOrderResponses(t *testing.T) {
+	captureLog(t)
+	// Completes the handshake, then reads two requests and answers them in reverse order,
+	// so both calls must be in flight at once to complete.
+	script := writePluginScript(t, shellIDHelpers+`read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\",\"capability\":\"metric\"}}"
+read -r a
+read -r b
+echo "{\"id\":$(id_of "$b"),\"result\":\"$(method_of "$b")\"}"
+echo "{\"id\":$(id_of "$a"),\"result\":\"$(method_of "$a")\"}"
+while read -r line; do :; done
+`)
+	runner := newPluginRunner("metric", script, nil, pluginOptions{})
+	defer runner.close()
+
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	errs := make([]error, 2)
+	for i, method := range []string{"metric.query.a", "metric.query.b"} {
+		wg.Add(1)
+		go func(i int, method string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			errs[i] = runner.call(ctx, method, nil, &results[i])
+		}(i, method)
+	}
+	wg.Wait()
+
+	for i, want := range []string{"metric.query.a", "metric.query.b"} {
+		if errs[i] != nil {
+			t.Fatalf("call %s: %v", want, errs[i])
+		}
+		if results[i] != want {
+			t.Fatalf("call %s got response %q", want, results[i])
+		}
+	}
+}
+
+func TestPluginRunnerLockStepPluginNotMultiplexed(t *testing.T) {
+	script := writePluginScript(t, `while read -r line; do echo '{"result":"ok"}'; done
+`)
+	runner := newPluginRunner("service", script, nil, pluginOptions{})
+	defer runner.close()
+
+	var res string
+	for i := 0; i < 2; i++ {
+		if err := runner.call(context.Background(), "service.query", nil, &res); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if runner.proc.mux != nil {
+		t.Fatalf("plugin without request IDs must stay lock-step")
+	}
+}
+
+func TestPluginLogWriterBoundsUnterminatedLines(t *testing.T) {
+	logs := captureLog(t)
+	w := &pluginLogWriter{capability: "incident"}
+	chunk := bytes.Repeat([]byte("x"), 1000)
+	for i := 0; i < 3*pluginLogLineMax/len(chunk); i++ {
+		w.Write(chunk)
+	}
+	if len(w.buf) >= pluginLogLineMax {
+		t.Fatalf("expected the partial line to be capped, buffered %d bytes", len(w.buf))
+	}
+	if n := strings.Count(logs.String(), `"msg":"plugin_stderr"`); n != 2 {
+		t.Fatalf("expected the long line to be logged in 2 pieces so far, got %d", n)
+	}
+}
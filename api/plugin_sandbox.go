@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPluginEnv is passed to plugins when OPSORCH_<CAP>_PLUGIN_ENV is not set. It carries
+// nothing secret, so a plugin never sees core's tokens or another capability's config.
+var defaultPluginEnv = []string{"PATH", "TZ", "LANG", "LC_ALL"}
+
+// pluginSandbox restricts what a plugin process can see and use.
+type pluginSandbox struct {
+	// env lists the variables copied from core's environment. A trailing "*" matches a prefix.
+	env []string
+	// workDir is the plugin's working directory; empty gives each runner a private temporary one.
+	workDir string
+	// memory caps the address space in bytes (RLIMIT_AS); zero leaves it unlimited.
+	memory uint64
+	// cpu caps CPU time (RLIMIT_CPU), rounded up to whole seconds; zero leaves it unlimited.
+	cpu time.Duration
+	// openFiles caps open file descriptors (RLIMIT_NOFILE); zero leaves it unlimited.
+	openFiles uint64
+	// uid and gid switch the plugin to another user and group; nil keeps core's.
+	uid, gid *uint32
+}
+
+// pluginSandboxFromEnv reads the sandbox options for a capability.
+//
+//	OPSORCH_<CAP>_PLUGIN_ENV           comma-separated variables to pass, e.g. "PATH,JIRA_*"
+//	OPSORCH_<CAP>_PLUGIN_WORKDIR       working directory (default: private temporary directory)
+//	OPSORCH_<CAP>_PLUGIN_MEMORY_LIMIT  address space limit such as "512M" or "2G"
+//	OPSORCH_<CAP>_PLUGIN_CPU_LIMIT     CPU time limit such as "10m"
+//	OPSORCH_<CAP>_PLUGIN_OPEN_FILES    open file descriptor limit
+//	OPSORCH_<CAP>_PLUGIN_UID           user ID to run the plugin as (core must run as root)
+//	OPSORCH_<CAP>_PLUGIN_GID           group ID to run the plugin as
+func pluginSandboxFromEnv(capability string) pluginSandbox {
+	prefix := fmt.Sprintf("OPSORCH_%s_PLUGIN_", strings.ToUpper(capability))
+	sandbox := pluginSandbox{
+		env:       defaultPluginEnv,
+		workDir:   strings.TrimSpace(os.Getenv(prefix + "WORKDIR")),
+		memory:    envBytes(prefix + "MEMORY_LIMIT"),
+		cpu:       envDuration(prefix+"CPU_LIMIT", 0),
+		openFiles: uint64(envInt(prefix+"OPEN_FILES", 0, 0)),
+		uid:       envID(prefix + "UID"),
+		gid:       envID(prefix + "GID"),
+	}
+	if raw, ok := os.LookupEnv(prefix + "ENV"); ok {
+		sandbox.env = nil
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				sandbox.env = append(sandbox.env, name)
+			}
+		}
+	}
+	return sandbox
+}
+
+// environ returns the plugin environment: only the allowlisted variables of core's own. The
+// result is never nil, so exec does not fall back to inheriting everything.
+func (s pluginSandbox) environ() []string {
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, allowed := range s.env {
+			if matchWildcard(allowed, name) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// matchWildcard reports whether name equals pattern or, for a pattern with a trailing "*",
+// starts with the rest of it.
+func matchWildcard(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return name == pattern
+}
+
+func (s pluginSandbox) limited() bool {
+	return s.memory > 0 || s.cpu > 0 || s.openFiles > 0
+}
+
+// ensureWorkDir returns the runner's plugin working directory, creating a private temporary
+// directory on first use. The directory is handed to the plugin's user when one is configured.
+func (r *pluginRunner) ensureWorkDir() (string, error) {
+	if r.sandbox.workDir != "" {
+		return r.sandbox.workDir, nil
+	}
+	if r.workDir != "" {
+		return r.workDir, nil
+	}
+	dir, err := os.MkdirTemp("", "opsorch-"+r.capability+"-plugin-")
+	if err != nil {
+		return "", fmt.Errorf("create %s plugin working directory: %w", r.capability, err)
+	}
+	if r.sandbox.uid != nil || r.sandbox.gid != nil {
+		uid, gid := -1, -1
+		if r.sandbox.uid != nil {
+			uid = int(*r.sandbox.uid)
+		}
+		if r.sandbox.gid != nil {
+			gid = int(*r.sandbox.gid)
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("hand %s plugin working directory to uid %d gid %d: %w", r.capability, uid, gid, err)
+		}
+	}
+	r.workDir = dir
+	return dir, nil
+}
+
+// removeWorkDir deletes the temporary working directory created by ensureWorkDir. The caller
+// holds the stream lock.
+func (r *pluginRunner) removeWorkDir() {
+	if r.workDir == "" {
+		return
+	}
+	if err := os.RemoveAll(r.workDir); err != nil {
+		slog.Warn("plugin working directory not removed", "capability", r.capability, "plugin", r.path, "dir", r.workDir, "err", err)
+	}
+	r.workDir = ""
+}
+
+// envID parses a numeric user or group ID. Invalid values are logged and ignored.
+func envID(envVar string) *uint32 {
+	raw := strings.TrimSpace(os.Getenv(envVar))
+	if raw == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		slog.Warn("invalid setting; ignoring it", "env", envVar, "value", raw)
+		return nil
+	}
+	id32 := uint32(id)
+	return &id32
+}
+
+// envBytes parses a byte size such as "1048576", "512K", "512M", or "2G" (powers of 1024).
+// Invalid values are logged and ignored.
+func envBytes(envVar string) uint64 {
+	raw := strings.ToUpper(strings.TrimSpace(os.Getenv(envVar)))
+	if raw == "" {
+		return 0
+	}
+	num, shift := strings.TrimSuffix(strings.TrimSuffix(raw, "B"), "I"), 0
+	switch {
+	case strings.HasSuffix(num, "K"):
+		shift = 10
+	case strings.HasSuffix(num, "M"):
+		shift = 20
+	case strings.HasSuffix(num, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n > (1<<63)>>shift {
+		slog.Warn("invalid setting; ignoring it", "env", envVar, "value", raw)
+		return 0
+	}
+	return n << shift
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// pluginLimitsShim is the argv[0] under which core re-executes itself to start a plugin with
+// resource limits. Go cannot run code in the child between fork and exec, so the shim sets
+// the rlimits on itself and then execs the plugin binary, which inherits them.
+const pluginLimitsShim = "opsorch-plugin-limits"
+
+func init() {
+	if len(os.Args) > 0 && os.Args[0] == pluginLimitsShim {
+		err := runPluginLimitsShim(os.Args[1:])
+		fmt.Fprintf(os.Stderr, "%s: %v\n", pluginLimitsShim, err)
+		os.Exit(126)
+	}
+}
+
+// sysProcAttr returns the process attributes that switch the plugin to its configured user.
+// Supplementary groups are dropped along with it.
+func (s pluginSandbox) sysProcAttr() (*syscall.SysProcAttr, error) {
+	if s.uid == nil && s.gid == nil {
+		return nil, nil
+	}
+	cred := &syscall.Credential{Uid: uint32(syscall.Getuid()), Gid: uint32(syscall.Getgid())}
+	if s.uid != nil {
+		cred.Uid = *s.uid
+	}
+	if s.gid != nil {
+		cred.Gid = *s.gid
+	}
+	return &syscall.SysProcAttr{Credential: cred}, nil
+}
+
+// command returns the command that starts binary inside the sandbox. With resource limits
+// configured it runs the plugin through the limits shim, so the limits hold from the plugin's
+// first instruction and apply to every process it starts.
+func (s pluginSandbox) command(binary string) (*exec.Cmd, error) {
+	sysProcAttr, err := s.sysProcAttr()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(binary)
+	cmd.SysProcAttr = sysProcAttr
+	if !s.limited() || cmd.Err != nil {
+		return cmd, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate core executable for the limits shim: %w", err)
+	}
+	cpu := uint64((s.cpu + 999_999_999) / 1_000_000_000)
+	cmd.Args = []string{pluginLimitsShim, strconv.FormatUint(s.memory, 10), strconv.FormatUint(cpu, 10), strconv.FormatUint(s.openFiles, 10), cmd.Path}
+	cmd.Path = self
+	return cmd, nil
+}
+
+// runPluginLimitsShim applies the limits passed by command to the current process and execs the
+// plugin in its place. It only returns on failure. Everything execve needs is allocated before
+// the limits are set, since a tight address space limit can leave the Go runtime unable to
+// map more memory.
+func runPluginLimitsShim(args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("expected memory, cpu, open files, and binary, got %q", args)
+	}
+	var values [3]uint64
+	for i := range values {
+		v, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid limit %q", args[i])
+		}
+		values[i] = v
+	}
+	binary, err := syscall.BytePtrFromString(args[3])
+	if err != nil {
+		return err
+	}
+	argv, err := syscall.SlicePtrFromStrings(args[3:])
+	if err != nil {
+		return err
+	}
+	envv, err := syscall.SlicePtrFromStrings(os.Environ())
+	if err != nil {
+		return err
+	}
+
+	limits := []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"memory", syscall.RLIMIT_AS, values[0]},
+		{"cpu", syscall.RLIMIT_CPU, values[1]},
+		{"open files", syscall.RLIMIT_NOFILE, values[2]},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		rl := syscall.Rlimit{Cur: l.value, Max: l.value}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, 0, uintptr(l.resource), uintptr(unsafe.Pointer(&rl)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("set %s limit: %w", l.name, errno)
+		}
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_EXECVE, uintptr(unsafe.Pointer(binary)), uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envv[0])))
+	return fmt.Errorf("exec %s: %w", args[3], errno)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPluginSandboxAppliesResourceLimits(t *testing.T) {
+	captureLog(t)
+	sandbox := pluginSandbox{memory: 1 << 30, cpu: 90 * time.Second, openFiles: 64}
+	runner := newPluginRunner("incident", writePluginScript(t, sandboxProbe), nil, pluginOptions{sandbox: sandbox})
+	defer runner.close()
+
+	var got sandboxReport
+	if err := runner.call(context.Background(), "incident.query", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got.Files != "64" || got.Memory != "1048576" || got.CPU != "90" {
+		t.Fatalf("unexpected limits inside the plugin: %+v", got)
+	}
+}
+
+func TestPluginSandboxLimitsHoldFromFirstInstruction(t *testing.T) {
+	captureLog(t)
+	// The limit is read by a child started before the plugin does anything else.
+	script := writePluginScript(t, `early=$(sh -c 'ulimit -n')
+read -r handshake
+echo '{"error":"no handshake"}'
+read -r line
+echo "{\"result\":\"$early\"}"
+`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{sandbox: pluginSandbox{openFiles: 48}})
+	defer runner.close()
+
+	var got string
+	if err := runner.call(context.Background(), "incident.query", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got != "48" {
+		t.Fatalf("expected the plugin's first child to inherit the open files limit, got %q", got)
+	}
+}
+
+func TestPluginSandboxDropsToConfiguredUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("switching the plugin user requires root")
+	}
+	captureLog(t)
+	script := writePluginScript(t, `read -r handshake
+echo '{"error":"no handshake"}'
+read -r line
+echo "{\"result\":\"$(id -u):$(id -g)\"}"
+`)
+	// Let the unprivileged user reach the script inside the test's private temp directories.
+	for dir := filepath.Dir(script); dir != os.TempDir() && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0o755); err != nil {
+			t.Fatalf("chmod %s: %v", dir, err)
+		}
+	}
+
+	nobody := uint32(65534)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{sandbox: pluginSandbox{uid: &nobody, gid: &nobody}})
+	defer runner.close()
+
+	var got string
+	if err := runner.call(context.Background(), "incident.query", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got != "65534:65534" {
+		t.Fatalf("expected plugin to run as 65534:65534, got %q", got)
+	}
+}
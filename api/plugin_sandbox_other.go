@@ -0,0 +1,19 @@
+//go:build !linux
+
+package api
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// errSandboxUnsupported is returned when resource limits or a plugin user are configured on a
+// platform where core cannot enforce them; the plugin is not started unconfined.
+var errSandboxUnsupported = errors.New("plugin resource limits and uid/gid are only supported on Linux")
+
+func (s pluginSandbox) command(binary string) (*exec.Cmd, error) {
+	if s.limited() || s.uid != nil || s.gid != nil {
+		return nil, errSandboxUnsupported
+	}
+	return exec.Command(binary), nil
+}
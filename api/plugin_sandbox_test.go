@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sandboxProbe is a legacy plugin that reports what it can see from inside its sandbox.
+const sandboxProbe = `read -r handshake
+echo '{"error":"no handshake"}'
+read -r line
+echo "{\"result\":{\"pwd\":\"$(pwd)\",\"secret\":\"$OPSORCH_BEARER_TOKEN\",\"allowed\":\"$ADAPTER_REGION\",\"prefixed\":\"$JIRA_URL\",\"files\":\"$(ulimit -n)\",\"memory\":\"$(ulimit -v)\",\"cpu\":\"$(ulimit -t)\"}}"
+`
+
+type sandboxReport struct {
+	Pwd      string `json:"pwd"`
+	Secret   string `json:"secret"`
+	Allowed  string `json:"allowed"`
+	Prefixed string `json:"prefixed"`
+	Files    string `json:"files"`
+	Memory   string `json:"memory"`
+	CPU      string `json:"cpu"`
+}
+
+func TestPluginSandboxScrubsEnvironment(t *testing.T) {
+	captureLog(t)
+	t.Setenv("OPSORCH_BEARER_TOKEN", "core-secret")
+	t.Setenv("ADAPTER_REGION", "eu-west-1")
+	t.Setenv("JIRA_URL", "https://jira.example.com")
+	workDir := t.TempDir()
+
+	runner := newPluginRunner("incident", writePluginScript(t, sandboxProbe), nil, pluginOptions{sandbox: pluginSandbox{env: []string{"PATH", "ADAPTER_REGION", "JIRA_*"}, workDir: workDir}})
+	defer runner.close()
+
+	var got sandboxReport
+	if err := runner.call(context.Background(), "incident.query", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got.Secret != "" {
+		t.Fatalf("plugin must not inherit OPSORCH_BEARER_TOKEN, got %q", got.Secret)
+	}
+	if got.Allowed != "eu-west-1" || got.Prefixed != "https://jira.example.com" {
+		t.Fatalf("allowlisted variables missing: %+v", got)
+	}
+	if got.Pwd != workDir {
+		t.Fatalf("expected working directory %s, got %s", workDir, got.Pwd)
+	}
+}
+
+func TestPluginSandboxPrivateWorkDir(t *testing.T) {
+	captureLog(t)
+	runner := newPluginRunner("incident", writePluginScript(t, sandboxProbe), nil, pluginOptions{})
+
+	var got sandboxReport
+	if err := runner.call(context.Background(), "incident.query", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(got.Pwd), "opsorch-incident-plugin-") {
+		t.Fatalf("expected a private temporary working directory, got %q", got.Pwd)
+	}
+	runner.close()
+	if _, err := os.Stat(got.Pwd); !os.IsNotExist(err) {
+		t.Fatalf("expected working directory to be removed on close, stat err=%v", err)
+	}
+}
+
+func TestPluginSandboxFromEnv(t *testing.T) {
+	captureLog(t)
+	t.Setenv("OPSORCH_TICKET_PLUGIN_ENV", "PATH, JIRA_*,")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_WORKDIR", "/var/lib/opsorch/jira")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_MEMORY_LIMIT", "512MiB")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_CPU_LIMIT", "10m")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_OPEN_FILES", "256")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_UID", "1001")
+	t.Setenv("OPSORCH_TICKET_PLUGIN_GID", "bogus")
+
+	s := pluginSandboxFromEnv("ticket")
+	if strings.Join(s.env, ",") != "PATH,JIRA_*" || s.workDir != "/var/lib/opsorch/jira" {
+		t.Fatalf("unexpected env/workdir: %+v", s)
+	}
+	if s.memory != 512<<20 || s.cpu != 10*time.Minute || s.openFiles != 256 {
+		t.Fatalf("unexpected limits: %+v", s)
+	}
+	if s.uid == nil || *s.uid != 1001 || s.gid != nil {
+		t.Fatalf("unexpected uid/gid: uid=%v gid=%v", s.uid, s.gid)
+	}
+
+	if d := pluginSandboxFromEnv("log"); strings.Join(d.env, ",") != strings.Join(defaultPluginEnv, ",") || d.limited() || d.uid != nil {
+		t.Fatalf("unexpected defaults: %+v", d)
+	}
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+const (
+	defaultPluginBackoffInitial = 100 * time.Millisecond
+	defaultPluginBackoffMax     = 30 * time.Second
+	// pluginWaitDelay bounds how long reaping waits on stray children still holding stderr.
+	pluginWaitDelay = 2 * time.Second
+	// pluginLogLineMax caps a buffered stderr line; longer output is logged in pieces of this size.
+	pluginLogLineMax = 64 << 10
+)
+
+// pluginProcess is one running plugin instance and its stream: a child process's stdio or a
+// connection to a plugin socket.
+type pluginProcess struct {
+	conn     pluginConn
+	kill     func() error // stops the instance; its exit is then observed by the exit monitor
+	enc      *json.Encoder
+	dec      *json.Decoder
+	mux      *pluginMux      // non-nil once the plugin has echoed a request ID
+	manifest *pluginManifest // nil for legacy plugins without a handshake
+
+	calls    int          // calls routed to this process, guarded by the runner's stream lock
+	active   atomic.Int64 // calls currently using the stream
+	detached atomic.Bool  // not the runner's current process: takes no new calls, exits once idle
+
+	exited  chan struct{}
+	exitErr error
+
+	mu     sync.Mutex
+	reason error // set when core tears the process down itself
+}
+
+func (p *pluginProcess) alive() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// pluginHealth is a point-in-time snapshot of a supervised plugin.
+type pluginHealth struct {
+	Capability string          `json:"capability"`
+	Path       string          `json:"path"`
+	Healthy    bool            `json:"healthy"`
+	Running    bool            `json:"running"`
+	Restarts   int             `json:"restarts"`
+	LastError  string          `json:"lastError,omitempty"`
+	LastExitAt time.Time       `json:"lastExitAt,omitempty"`
+	Manifest   *pluginManifest `json:"manifest,omitempty"`
+}
+
+// pluginSupervisorState tracks crash and restart bookkeeping for a runner.
+type pluginSupervisorState struct {
+	healthy    bool
+	running    bool
+	restarting bool // a backoff timer owns the next spawn
+	closed     bool
+	restarts   int
+	failures   int // consecutive failures, reset by a successful call
+	lastError  string
+	lastExitAt time.Time
+	manifest   *pluginManifest
+	fatal      error // verification or the handshake rejected the binary; it is not restarted
+	timer      *time.Timer
+}
+
+func newPluginProcess(conn pluginConn, kill func() error) *pluginProcess {
+	proc := &pluginProcess{
+		conn:   conn,
+		kill:   kill,
+		enc:    json.NewEncoder(conn),
+		dec:    json.NewDecoder(conn),
+		exited: make(chan struct{}),
+	}
+	proc.detached.Store(true) // until start completes the handshake
+	return proc
+}
+
+// spawn starts a plugin instance: it dials the plugin socket for unix:// targets and otherwise
+// execs the plugin binary.
+func (r *pluginRunner) spawn() (*pluginProcess, error) {
+	if socket, ok := pluginSocketPath(r.path); ok {
+		return r.dialSocket(socket)
+	}
+	return r.exec()
+}
+
+// exec verifies the plugin binary and starts it inside its sandbox with private stdio pipes and
+// stderr forwarded to the core log. Verification runs before every spawn, so a binary replaced
+// on disk is caught at the next restart. Stdout is an os.Pipe rather than cmd.StdoutPipe so that reaping the
+// process never closes the read side before a final response has been decoded.
+func (r *pluginRunner) exec() (*pluginProcess, error) {
+	binary, err := r.verify.check(r.capability, r.path)
+	if err != nil {
+		logSystemAudit("plugin.rejected", map[string]string{"capability": r.capability, "plugin": r.path, "reason": err.Error()})
+		return nil, err
+	}
+	workDir, err := r.ensureWorkDir()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := r.sandbox.command(binary)
+	if err != nil {
+		return nil, fmt.Errorf("start %s plugin %s: %w", r.capability, r.path, err)
+	}
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+
+	stderr := &pluginLogWriter{capability: r.capability}
+	cmd.Stdin = stdinR
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderr
+	cmd.WaitDelay = pluginWaitDelay
+	cmd.Env = r.sandbox.environ()
+	cmd.Dir = workDir
+	if err := cmd.Start(); err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, fmt.Errorf("start %s plugin %s: %w", r.capability, r.path, err)
+	}
+	// The child owns its ends of the pipes now.
+	stdinR.Close()
+	stdoutW.Close()
+
+	proc := newPluginProcess(&stdioConn{r: stdoutR, w: stdinW}, cmd.Process.Kill)
+	go func() {
+		proc.exitErr = cmd.Wait()
+		stderr.flush()
+		close(proc.exited)
+		r.handleExit(proc)
+	}()
+	return proc, nil
+}
+
+// terminate kills a process whose stream can no longer be trusted and waits for it to be reaped.
+func (p *pluginProcess) terminate(reason error) {
+	if p.alive() {
+		p.mu.Lock()
+		p.reason = reason
+		p.mu.Unlock()
+		_ = p.kill()
+	}
+	<-p.exited
+	p.conn.Close()
+}
+
+// errPluginRecycled marks a process that was shut down on purpose after serving its call quota.
+var errPluginRecycled = errors.New("plugin recycled")
+
+// finishCall ends one call on the process, shutting a detached process down once it is idle.
+func (p *pluginProcess) finishCall() {
+	if p.active.Add(-1) == 0 && p.detached.Load() {
+		go p.terminate(errPluginRecycled)
+	}
+}
+
+// interrupt unblocks any in-flight encode or decode on the process stream.
+func (p *pluginProcess) interrupt() {
+	now := time.Now()
+	_ = p.conn.SetWriteDeadline(now)
+	_ = p.conn.SetReadDeadline(now)
+}
+
+// setDeadline bounds the next request/response exchange; a zero time clears it.
+func (p *pluginProcess) setDeadline(deadline time.Time) {
+	_ = p.conn.SetWriteDeadline(deadline)
+	_ = p.conn.SetReadDeadline(deadline)
+}
+
+// handleExit runs once per process when it exits, marking the plugin unhealthy and scheduling a restart.
+func (r *pluginRunner) handleExit(proc *pluginProcess) {
+	proc.mu.Lock()
+	cause := proc.reason
+	proc.mu.Unlock()
+	if cause == nil {
+		cause = proc.exitErr
+	}
+	if cause == nil {
+		cause = fmt.Errorf("plugin exited")
+	}
+	if proc.detached.Load() {
+		// Detached processes were recycled or never finished their handshake; their exit
+		// says nothing about the health of the current process.
+		if !errors.Is(cause, errPluginRecycled) && !r.isClosed() {
+			slog.Info("plugin detached process exited", "capability", r.capability, "plugin", r.path, "err", cause)
+		}
+		return
+	}
+
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.state.healthy = false
+	r.state.running = false
+	r.state.lastExitAt = time.Now().UTC()
+	if r.state.closed {
+		return
+	}
+	delay := r.scheduleRestart(proc, cause)
+	slog.Warn("plugin exited; restarting", "capability", r.capability, "plugin", r.path, "err", cause, "retry_in", delay.String())
+}
+
+// scheduleRestart records a failure and arms the backoff timer that replaces dead, which is
+// nil when no process was started. The caller holds stateMu.
+func (r *pluginRunner) scheduleRestart(dead *pluginProcess, cause error) time.Duration {
+	r.state.restarting = true
+	r.state.failures++
+	r.state.lastError = cause.Error()
+	delay := r.backoff(r.state.failures)
+	r.state.timer = time.AfterFunc(delay, func() { r.restart(dead) })
+	return delay
+}
+
+// restart replaces a dead process. Config is attached to every request frame, so the new
+// process is re-configured by the first call it serves.
+func (r *pluginRunner) restart(dead *pluginProcess) {
+	_ = r.acquire(context.Background())
+	defer r.release()
+
+	if r.isClosed() {
+		return
+	}
+	if r.proc != dead {
+		// A call already replaced the process; its spawn supersedes this restart.
+		r.stateMu.Lock()
+		r.state.restarting = false
+		r.stateMu.Unlock()
+		return
+	}
+	if dead != nil {
+		dead.conn.Close()
+	}
+	r.setProc(nil)
+
+	proc, err := r.start(context.Background())
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if err != nil {
+		if isFatalPluginError(err) {
+			r.state.restarting = false
+			r.state.fatal = err
+			r.state.lastError = err.Error()
+			slog.Error("plugin rejected", "capability", r.capability, "plugin", r.path, "err", err)
+			return
+		}
+		delay := r.scheduleRestart(nil, err)
+		slog.Warn("plugin restart failed", "capability", r.capability, "plugin", r.path, "err", err, "retry_in", delay.String())
+		return
+	}
+	r.setProc(proc)
+	r.state.running = true
+	r.state.restarting = false
+	r.state.restarts++
+	selfMetrics.pluginRestarts.add(1, r.capability, r.path)
+	slog.Info("plugin restarted", "capability", r.capability, "plugin", r.path, "restarts", r.state.restarts)
+}
+
+// backoff returns the restart delay after the given number of consecutive failures.
+func (r *pluginRunner) backoff(failures int) time.Duration {
+	delay := r.backoffInitial
+	for i := 1; i < failures && delay < r.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > r.backoffMax {
+		delay = r.backoffMax
+	}
+	return delay
+}
+
+// markHealthy records a successful call. A process that already exited stays unhealthy:
+// exited is closed before handleExit takes stateMu, so the two cannot interleave wrongly.
+func (r *pluginRunner) markHealthy(proc *pluginProcess) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if !proc.alive() {
+		return
+	}
+	r.state.healthy = true
+	r.state.failures = 0
+}
+
+// restartPending reports whether a dead plugin is waiting out its restart backoff.
+func (r *pluginRunner) restartPending() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state.restarting
+}
+
+func (r *pluginRunner) isClosed() bool {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state.closed
+}
+
+// health reports the supervisor's view of the plugin without waiting on in-flight calls.
+func (r *pluginRunner) health() pluginHealth {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return pluginHealth{
+		Capability: r.capability,
+		Path:       r.path,
+		Healthy:    r.state.healthy,
+		Running:    r.state.running,
+		Restarts:   r.state.restarts,
+		LastError:  r.state.lastError,
+		LastExitAt: r.state.lastExitAt,
+		Manifest:   r.state.manifest,
+	}
+}
+
+// ping fails while the plugin waits out a restart. Otherwise plugins that declare plugin.ping
+// are asked, and the rest pass: a running process is all core can vouch for.
+func (r *pluginRunner) ping(ctx context.Context) error {
+	if r.restartPending() {
+		return orcherr.New("plugin_unavailable", fmt.Sprintf("%s plugin is restarting", r.capability), nil)
+	}
+	if !r.health().Manifest.declares(pluginPingMethod) {
+		return nil
+	}
+	return r.call(ctx, pluginPingMethod, nil, nil)
+}
+
+// close stops supervision and kills the plugin process, if any.
+func (r *pluginRunner) close() {
+	r.stateMu.Lock()
+	r.state.closed = true
+	if r.state.timer != nil {
+		r.state.timer.Stop()
+	}
+	r.stateMu.Unlock()
+
+	_ = r.acquire(context.Background())
+	defer r.release()
+	if r.proc != nil {
+		r.proc.terminate(fmt.Errorf("plugin closed"))
+	}
+	r.setProc(nil)
+	r.removeWorkDir()
+}
+
+// errPluginShutdown marks a process stopped by an orderly shutdown.
+var errPluginShutdown = errors.New("plugin shut down")
+
+// shutdown stops supervision and lets the plugin exit cleanly: it sends the shutdown frame,
+// closes the plugin's input, and waits for the process to exit until ctx is done, then kills
+// it. Connections to remote plugins are closed once the plugin has answered.
+func (r *pluginRunner) shutdown(ctx context.Context) {
+	r.stateMu.Lock()
+	r.state.closed = true
+	if r.state.timer != nil {
+		r.state.timer.Stop()
+	}
+	r.stateMu.Unlock()
+
+	if err := r.acquire(ctx); err != nil {
+		// A lock-step call is still running past the deadline. Killing the process fails the
+		// call, which releases the stream lock for the cleanup below.
+		slog.Warn("plugin busy at shutdown; killing it", "capability", r.capability, "plugin", r.path)
+		if proc := r.live.Load(); proc != nil {
+			proc.detached.Store(true)
+			proc.terminate(errPluginShutdown)
+		}
+		_ = r.acquire(context.Background())
+	}
+	defer r.release()
+	proc := r.proc
+	r.setProc(nil)
+	defer r.removeWorkDir()
+	if proc == nil || !proc.alive() {
+		return
+	}
+	// The exit that follows is expected, not a crash to restart from.
+	proc.detached.Store(true)
+
+	req := rpcRequest{ID: r.nextID.Add(1), Method: pluginShutdownMethod, Config: r.config}
+	var err error
+	if proc.mux != nil {
+		_, err = proc.mux.call(ctx, req, nil)
+	} else {
+		_, err = r.exchange(ctx, proc, req, nil)
+	}
+	if err != nil {
+		slog.Warn("plugin did not acknowledge shutdown", "capability", r.capability, "plugin", r.path, "err", err)
+	}
+	if !isRemotePluginTarget(r.path) {
+		if c, ok := proc.conn.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+		select {
+		case <-proc.exited:
+		case <-ctx.Done():
+			slog.Warn("plugin did not exit in time; killing it", "capability", r.capability, "plugin", r.path)
+		}
+	}
+	proc.terminate(errPluginShutdown)
+	slog.Info("plugin shut down", "capability", r.capability, "plugin", r.path)
+}
+
+// pluginLogWriter forwards plugin stderr to the core log line by line, tagged with the capability.
+type pluginLogWriter struct {
+	capability string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *pluginLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	// A plugin that never writes a newline must not grow the buffer without bound.
+	for len(w.buf) >= pluginLogLineMax {
+		w.emit(w.buf[:pluginLogLineMax])
+		w.buf = w.buf[pluginLogLineMax:]
+	}
+	return len(p), nil
+}
+
+func (w *pluginLogWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *pluginLogWriter) emit(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+	slog.Info("plugin_stderr", "capability", w.capability, "line", string(line))
+}
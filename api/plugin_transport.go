@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginDialTimeout bounds connecting to a plugin socket.
+const pluginDialTimeout = 5 * time.Second
+
+// pluginConn is the byte stream to one plugin instance. Deadlines let a timed-out call
+// interrupt a blocked read or write.
+type pluginConn interface {
+	io.ReadWriteCloser
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// stdioConn joins a child process's stdout (r) and stdin (w) into one stream.
+type stdioConn struct {
+	r *os.File
+	w *os.File
+}
+
+func (c *stdioConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *stdioConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func (c *stdioConn) SetReadDeadline(t time.Time) error  { return c.r.SetReadDeadline(t) }
+func (c *stdioConn) SetWriteDeadline(t time.Time) error { return c.w.SetWriteDeadline(t) }
+
+// CloseWrite closes the plugin's stdin, which a plugin reads as the end of its input.
+func (c *stdioConn) CloseWrite() error { return c.w.Close() }
+
+func (c *stdioConn) Close() error {
+	return errors.Join(c.w.Close(), c.r.Close())
+}
+
+// pluginSocketPath returns the socket path of a unix:///path/to.sock plugin target.
+func pluginSocketPath(target string) (string, bool) {
+	path, ok := strings.CutPrefix(target, "unix://")
+	return path, ok && path != ""
+}
+
+// isRemotePluginTarget reports whether target names a plugin core connects to rather than a
+// binary it executes.
+func isRemotePluginTarget(target string) bool {
+	_, socket := pluginSocketPath(target)
+	return socket || isHTTPPluginTarget(target)
+}
+
+// isHTTPPluginTarget reports whether target is an http:// or https:// plugin endpoint.
+func isHTTPPluginTarget(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// errPluginDisconnected is the exit cause of a plugin socket that was closed.
+var errPluginDisconnected = errors.New("plugin connection closed")
+
+// dialSocket connects to a plugin listening on a Unix socket. The connection is supervised like
+// a child process: when either side closes it, the instance counts as exited and the runner
+// redials with backoff. Stopping the instance only closes core's connection; the plugin's own
+// lifecycle is managed outside OpsOrch.
+func (r *pluginRunner) dialSocket(socket string) (*pluginProcess, error) {
+	conn, err := net.DialTimeout("unix", socket, pluginDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s plugin %s: %w", r.capability, r.path, err)
+	}
+
+	hangup := make(chan struct{})
+	var once sync.Once
+	proc := newPluginProcess(conn, func() error {
+		once.Do(func() { close(hangup) })
+		return conn.Close()
+	})
+	go func() {
+		<-hangup
+		proc.exitErr = errPluginDisconnected
+		close(proc.exited)
+		r.handleExit(proc)
+	}()
+	return proc, nil
+}
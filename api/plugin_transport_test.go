@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
+)
+
+// memSecrets is a secret.Provider served through the plugin SDK in transport tests.
+type memSecrets struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (m *memSecrets) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	if !ok {
+		return "", orcherr.New("not_found", key+" not found", nil)
+	}
+	return v, nil
+}
+
+func (m *memSecrets) Put(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+// socketPath returns a short socket path; t.TempDir can exceed the sun_path limit.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "opsorch")
+	if err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "plugin.sock")
+}
+
+func exerciseSecretPlugin(t *testing.T, client pluginClient) {
+	t.Helper()
+	ctx := context.Background()
+	if err := client.call(ctx, "secret.put", map[string]string{"key": "db", "value": "hunter2"}, nil); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var got string
+	if err := client.call(ctx, "secret.get", map[string]string{"key": "db"}, &got); err != nil || got != "hunter2" {
+		t.Fatalf("get: got %q err=%v", got, err)
+	}
+	err := client.call(ctx, "secret.get", map[string]string{"key": "missing"}, &got)
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "not_found" {
+		t.Fatalf("expected not_found, got %v", err)
+	}
+	err = client.call(ctx, "secret.list", nil, nil)
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "not_implemented" {
+		t.Fatalf("expected not_implemented for an undeclared method, got %v", err)
+	}
+}
+
+func TestPluginOverUnixSocket(t *testing.T) {
+	captureLog(t)
+	sock := socketPath(t)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	go pluginsdk.ServeListener(l, &memSecrets{values: map[string]string{}})
+
+	client := newPluginClient("secret", "unix://"+sock, nil, nil)
+	defer client.close()
+	if _, ok := client.(*pluginRunner); !ok {
+		t.Fatalf("expected a supervised runner for a unix target, got %T", client)
+	}
+	exerciseSecretPlugin(t, client)
+}
+
+func TestPluginSocketReconnectsAfterDisconnect(t *testing.T) {
+	captureLog(t)
+	sock := socketPath(t)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	// Each connection answers the handshake and one call with its connection number, then
+	// the first connection hangs up.
+	go func() {
+		for n := 1; ; n++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(n int, conn net.Conn) {
+				defer conn.Close()
+				dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+				for served := 0; n > 1 || served < 2; served++ {
+					var req rpcRequest
+					if err := dec.Decode(&req); err != nil {
+						return
+					}
+					var result any = n
+					if req.Method == pluginHandshakeMethod {
+						result = pluginManifest{ProtocolVersion: pluginProtocolVersion, Capability: "secret"}
+					}
+					_ = enc.Encode(map[string]any{"id": req.ID, "result": result})
+				}
+			}(n, conn)
+		}
+	}()
+
+	runner := newPluginRunner("secret", "unix://"+sock, nil, pluginOptions{})
+	runner.backoffInitial = 10 * time.Millisecond
+	defer runner.close()
+
+	var got int
+	if err := runner.call(context.Background(), "secret.get", nil, &got); err != nil || got != 1 {
+		t.Fatalf("first call: got %d err=%v", got, err)
+	}
+	waitFor(t, 2*time.Second, func() bool { return runner.health().Restarts == 1 })
+	if err := runner.call(context.Background(), "secret.get", nil, &got); err != nil || got != 2 {
+		t.Fatalf("call after reconnect: got %d err=%v", got, err)
+	}
+	if h := runner.health(); !h.Healthy || h.LastError == "" {
+		t.Fatalf("unexpected health after reconnect: %+v", h)
+	}
+}
+
+func TestPluginOverHTTP(t *testing.T) {
+	captureLog(t)
+	handler, err := pluginsdk.NewHTTPHandler(&memSecrets{values: map[string]string{}})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := newPluginClient("secret", srv.URL+"/rpc", nil, nil)
+	defer client.close()
+	if _, ok := client.(*httpPluginClient); !ok {
+		t.Fatalf("expected an HTTP client for an http target, got %T", client)
+	}
+	exerciseSecretPlugin(t, client)
+}
+
+func TestHTTPPluginUnreachable(t *testing.T) {
+	captureLog(t)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	endpoint := srv.URL
+	srv.Close()
+
+	client := newHTTPPluginClient("secret", endpoint, nil, pluginOptions{})
+	err := client.call(context.Background(), "secret.get", map[string]string{"key": "db"}, nil)
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "plugin_unavailable" {
+		t.Fatalf("expected plugin_unavailable, got %v", err)
+	}
+}
+
+func TestHTTPPluginTimeout(t *testing.T) {
+	captureLog(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == pluginHandshakeMethod {
+			fmt.Fprintf(w, `{"id":%d,"result":{"protocolVersion":"1.0","capability":"secret"}}`, req.ID)
+			return
+		}
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := newHTTPPluginClient("secret", srv.URL, nil, pluginOptions{timeout: 50 * time.Millisecond})
+	err := client.call(context.Background(), "secret.get", map[string]string{"key": "db"}, nil)
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "timeout" {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
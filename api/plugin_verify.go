@@ -0,0 +1,199 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// pluginSignatureSuffix names the detached signature that sits next to a plugin binary.
+const pluginSignatureSuffix = ".sig"
+
+// errPluginRejected marks plugin binaries that failed verification. Like an incompatible
+// plugin, a rejected binary is not restarted until it is reconfigured.
+var errPluginRejected = errors.New("plugin rejected")
+
+// pluginVerification decides which plugin binaries core may exec. The zero value accepts any
+// binary, matching deployments that predate verification.
+type pluginVerification struct {
+	// digest is the pinned lowercase hex SHA-256 of the binary; empty skips the pin.
+	digest string
+	// dir confines binaries to a directory tree; empty allows any path.
+	dir string
+	// trustedKeys, when non-nil, requires <binary>.sig to be an ed25519 signature of the
+	// binary's SHA-256 digest by one of the keys.
+	trustedKeys []ed25519.PublicKey
+	// remoteAllow lists the unix:// and http(s):// targets POST /providers may configure
+	// while verification is on. A trailing "*" matches a prefix.
+	remoteAllow []string
+}
+
+// pluginVerificationFromEnv reads the verification settings for a capability.
+//
+//	OPSORCH_<CAP>_PLUGIN_SHA256    pinned hex SHA-256 of the capability's plugin binary
+//	OPSORCH_PLUGIN_DIR             directory every plugin binary must live under
+//	OPSORCH_PLUGIN_TRUSTED_KEYS    comma-separated base64 ed25519 public keys
+//	OPSORCH_PLUGIN_REMOTE_ALLOW    comma-separated remote targets the API may configure
+//
+// Malformed keys are logged and dropped. A key list with no usable key rejects every
+// binary rather than silently turning signature checks off.
+func pluginVerificationFromEnv(capability string) pluginVerification {
+	v := pluginVerification{
+		digest: strings.ToLower(strings.TrimSpace(os.Getenv(fmt.Sprintf("OPSORCH_%s_PLUGIN_SHA256", strings.ToUpper(capability))))),
+		dir:    strings.TrimSpace(os.Getenv("OPSORCH_PLUGIN_DIR")),
+	}
+	if raw := strings.TrimSpace(os.Getenv("OPSORCH_PLUGIN_TRUSTED_KEYS")); raw != "" {
+		v.trustedKeys = []ed25519.PublicKey{}
+		for _, encoded := range strings.Split(raw, ",") {
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil || len(key) != ed25519.PublicKeySize {
+				slog.Warn("invalid setting entry; not a base64 ed25519 public key", "env", "OPSORCH_PLUGIN_TRUSTED_KEYS", "value", encoded)
+				continue
+			}
+			v.trustedKeys = append(v.trustedKeys, ed25519.PublicKey(key))
+		}
+	}
+	for _, target := range strings.Split(os.Getenv("OPSORCH_PLUGIN_REMOTE_ALLOW"), ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			v.remoteAllow = append(v.remoteAllow, target)
+		}
+	}
+	return v
+}
+
+// enabled reports whether any check is configured.
+func (v pluginVerification) enabled() bool {
+	return v.digest != "" || v.dir != "" || v.trustedKeys != nil
+}
+
+// checkRemote decides whether POST /providers may point a capability at a remote target. Core
+// cannot verify what answers on a socket or URL, so while any check is configured only
+// targets in the allowlist are accepted.
+func (v pluginVerification) checkRemote(capability, target string) error {
+	if !v.enabled() {
+		return nil
+	}
+	for _, allowed := range v.remoteAllow {
+		if matchWildcard(allowed, target) {
+			return nil
+		}
+	}
+	return v.reject(capability, target, "remote plugin target is not in OPSORCH_PLUGIN_REMOTE_ALLOW")
+}
+
+// check verifies the binary at path and returns the resolved path to exec. Bare names are
+// looked up on PATH and symlinks are resolved first, so the file that is hashed is the file
+// that runs. With no check configured path is returned unchanged.
+func (v pluginVerification) check(capability, path string) (string, error) {
+	if !v.enabled() {
+		return path, nil
+	}
+	found := path
+	if !strings.ContainsRune(path, filepath.Separator) {
+		var err error
+		if found, err = exec.LookPath(path); err != nil {
+			return "", v.reject(capability, path, fmt.Sprintf("cannot find plugin: %v", err))
+		}
+	}
+	resolved, err := filepath.EvalSymlinks(found)
+	if err != nil {
+		return "", v.reject(capability, path, fmt.Sprintf("cannot resolve plugin path: %v", err))
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", v.reject(capability, path, fmt.Sprintf("cannot resolve plugin path: %v", err))
+	}
+
+	if v.dir != "" {
+		dir, err := filepath.EvalSymlinks(v.dir)
+		if err == nil {
+			dir, err = filepath.Abs(dir)
+		}
+		if err != nil {
+			return "", v.reject(capability, path, fmt.Sprintf("cannot resolve plugin directory %s: %v", v.dir, err))
+		}
+		if rel, err := filepath.Rel(dir, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", v.reject(capability, path, fmt.Sprintf("plugin is outside the plugin directory %s", v.dir))
+		}
+	}
+
+	if v.digest == "" && v.trustedKeys == nil {
+		return resolved, nil
+	}
+	sum, err := fileSHA256(resolved)
+	if err != nil {
+		return "", v.reject(capability, path, fmt.Sprintf("cannot hash plugin: %v", err))
+	}
+	if v.digest != "" && hex.EncodeToString(sum) != v.digest {
+		return "", v.reject(capability, path, fmt.Sprintf("plugin sha256 %x does not match the pinned digest", sum))
+	}
+	if v.trustedKeys != nil {
+		sig, err := readPluginSignature(resolved + pluginSignatureSuffix)
+		if err != nil {
+			return "", v.reject(capability, path, fmt.Sprintf("cannot read plugin signature: %v", err))
+		}
+		if !v.signedByTrustedKey(sum, sig) {
+			return "", v.reject(capability, path, "plugin signature does not verify with any trusted key")
+		}
+	}
+	return resolved, nil
+}
+
+func (v pluginVerification) signedByTrustedKey(digest, sig []byte) bool {
+	for _, key := range v.trustedKeys {
+		if ed25519.Verify(key, digest, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v pluginVerification) reject(capability, path, reason string) error {
+	return orcherr.New("plugin_rejected", fmt.Sprintf("%s plugin %s rejected: %s", capability, path, reason), errPluginRejected)
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// readPluginSignature reads a detached signature stored either raw or base64 encoded.
+func readPluginSignature(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == ed25519.SignatureSize {
+		return raw, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%s is not an ed25519 signature", path)
+	}
+	return sig, nil
+}
+
+// isFatalPluginError reports whether a plugin start failure cannot be fixed by restarting
+// the same target.
+func isFatalPluginError(err error) bool {
+	return errors.Is(err, errPluginIncompatible) || errors.Is(err, errPluginRejected)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func expectRejected(t *testing.T, err error, reason string) {
+	t.Helper()
+	if oe := asOpsOrchError(err); oe == nil || oe.Code != "plugin_rejected" || !strings.Contains(oe.Message, reason) {
+		t.Fatalf("expected plugin_rejected mentioning %q, got %v", reason, err)
+	}
+}
+
+func TestPluginVerificationDigestPin(t *testing.T) {
+	plugin := writePluginScript(t, "exit 0\n")
+
+	if _, err := (pluginVerification{digest: sha256Hex(t, plugin)}).check("incident", plugin); err != nil {
+		t.Fatalf("expected pinned digest to pass: %v", err)
+	}
+	_, err := pluginVerification{digest: strings.Repeat("0", 64)}.check("incident", plugin)
+	expectRejected(t, err, "does not match the pinned digest")
+}
+
+func TestPluginVerificationConfinesToPluginDir(t *testing.T) {
+	dir := t.TempDir()
+	inside := filepath.Join(dir, "jira")
+	if err := os.WriteFile(inside, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	outside := writePluginScript(t, "exit 0\n")
+	link := filepath.Join(dir, "escape")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	v := pluginVerification{dir: dir}
+	if got, err := v.check("incident", inside); err != nil || got != inside {
+		t.Fatalf("expected binary inside the plugin dir to pass, got %q err=%v", got, err)
+	}
+	_, err := v.check("incident", outside)
+	expectRejected(t, err, "outside the plugin directory")
+	_, err = v.check("incident", link)
+	expectRejected(t, err, "outside the plugin directory")
+	_, err = v.check("incident", filepath.Join(dir, "..", filepath.Base(dir)+"-sibling"))
+	expectRejected(t, err, "cannot resolve plugin path")
+}
+
+func TestPluginVerificationSignature(t *testing.T) {
+	plugin := writePluginScript(t, "exit 0\n")
+	trustedPub, trustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	raw, _ := os.ReadFile(plugin)
+	digest := sha256.Sum256(raw)
+	v := pluginVerification{trustedKeys: []ed25519.PublicKey{trustedPub}}
+
+	_, err := v.check("incident", plugin)
+	expectRejected(t, err, "cannot read plugin signature")
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(otherKey, digest[:]))
+	if err := os.WriteFile(plugin+pluginSignatureSuffix, []byte(sig+"\n"), 0o644); err != nil {
+		t.Fatalf("write sig: %v", err)
+	}
+	_, err = v.check("incident", plugin)
+	expectRejected(t, err, "does not verify with any trusted key")
+
+	if err := os.WriteFile(plugin+pluginSignatureSuffix, ed25519.Sign(trustedKey, digest[:]), 0o644); err != nil {
+		t.Fatalf("write sig: %v", err)
+	}
+	if _, err := v.check("incident", plugin); err != nil {
+		t.Fatalf("expected trusted signature to pass: %v", err)
+	}
+
+	// A configured key list with no usable keys fails closed.
+	_, err = pluginVerification{trustedKeys: []ed25519.PublicKey{}}.check("incident", plugin)
+	expectRejected(t, err, "does not verify with any trusted key")
+}
+
+func TestPluginVerificationFromEnv(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	t.Setenv("OPSORCH_INCIDENT_PLUGIN_SHA256", " ABCDEF ")
+	t.Setenv("OPSORCH_PLUGIN_DIR", "/opt/opsorch/plugins")
+	t.Setenv("OPSORCH_PLUGIN_TRUSTED_KEYS", base64.StdEncoding.EncodeToString(pub)+", not-a-key")
+	captureLog(t)
+
+	v := pluginVerificationFromEnv("incident")
+	if v.digest != "abcdef" || v.dir != "/opt/opsorch/plugins" || len(v.trustedKeys) != 1 || !v.trustedKeys[0].Equal(pub) {
+		t.Fatalf("unexpected verification settings: %+v", v)
+	}
+	if v := pluginVerificationFromEnv("log"); v.digest != "" {
+		t.Fatalf("expected no digest pin for log, got %q", v.digest)
+	}
+}
+
+func TestPluginRunnerRejectsReplacedBinaryOnRestart(t *testing.T) {
+	logs := captureLog(t)
+	script := writePluginScript(t, shellIDHelpers+`read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\"}}"
+read -r line
+echo "{\"id\":$(id_of "$line"),\"result\":\"ok\"}"
+exit 1
+`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{verify: pluginVerification{digest: sha256Hex(t, script)}})
+	runner.backoffInitial = 10 * time.Millisecond
+	defer runner.close()
+
+	if err := runner.call(context.Background(), "incident.query", nil, nil); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho tampered\n"), 0o755); err != nil {
+		t.Fatalf("replace plugin: %v", err)
+	}
+
+	waitFor(t, 2*time.Second, func() bool {
+		runner.stateMu.Lock()
+		defer runner.stateMu.Unlock()
+		return runner.state.fatal != nil
+	})
+	err := runner.call(context.Background(), "incident.query", nil, nil)
+	expectRejected(t, err, "does not match the pinned digest")
+	if !strings.Contains(logs.String(), `"action":"plugin.rejected"`) {
+		t.Fatalf("expected an audit entry for the rejected plugin, got logs:\n%s", logs.String())
+	}
+}
+
+func TestProviderConfigRejectsPluginOutsidePluginDir(t *testing.T) {
+	logs := captureLog(t)
+	t.Setenv("OPSORCH_PLUGIN_DIR", t.TempDir())
+	mem := &memorySecret{store: map[string]string{}}
+	srv := &Server{secret: mem}
+	body, _ := json.Marshal(map[string]any{"provider": "stub", "plugin": writePluginScript(t, "exit 0\n")})
+	req := httptest.NewRequest(http.MethodPost, "/providers/incident", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "plugin_rejected") {
+		t.Fatalf("expected 403 plugin_rejected, got %d %s", w.Code, w.Body.String())
+	}
+	if srv.incident.provider != nil || len(mem.store) != 0 {
+		t.Fatalf("rejected plugin must not be applied or persisted")
+	}
+	if !strings.Contains(logs.String(), `"action":"provider.plugin_rejected"`) {
+		t.Fatalf("expected audit entry, got logs:\n%s", logs.String())
+	}
+}
+
+func TestPluginVerificationLooksUpBareNames(t *testing.T) {
+	if got, err := (pluginVerification{}).check("log", "opsorch-log-datadog"); err != nil || got != "opsorch-log-datadog" {
+		t.Fatalf("expected an unverified name to pass through unchanged, got %q %v", got, err)
+	}
+
+	plugin := writePluginScript(t, "exit 0\n")
+	t.Setenv("PATH", filepath.Dir(plugin))
+	got, err := pluginVerification{digest: sha256Hex(t, plugin)}.check("log", filepath.Base(plugin))
+	if err != nil {
+		t.Fatalf("expected a pinned binary on PATH to pass: %v", err)
+	}
+	if want, _ := filepath.EvalSymlinks(plugin); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestProviderConfigRemoteTargetsNeedAllowlist(t *testing.T) {
+	logs := captureLog(t)
+	t.Setenv("OPSORCH_PLUGIN_DIR", t.TempDir())
+	t.Setenv("OPSORCH_PLUGIN_REMOTE_ALLOW", "unix:///run/opsorch/*")
+	srv := &Server{secret: &memorySecret{store: map[string]string{}}}
+	configure := func(target string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{"provider": "stub", "plugin": target})
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/providers/incident", bytes.NewReader(body)))
+		return w
+	}
+	defer srv.stopPlugins()
+
+	if w := configure("https://attacker.example/rpc"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "OPSORCH_PLUGIN_REMOTE_ALLOW") {
+		t.Fatalf("expected 403 for a remote target outside the allowlist, got %d %s", w.Code, w.Body.String())
+	}
+	if out := logs.String(); !strings.Contains(out, `"action":"provider.plugin_rejected"`) || !strings.Contains(out, `"plugin":"https://attacker.example/rpc"`) {
+		t.Fatalf("expected the rejection to be audited, got logs:\n%s", out)
+	}
+	if w := configure("unix:///run/opsorch/incident.sock"); w.Code != http.StatusOK {
+		t.Fatalf("expected an allowlisted target to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+const defaultPolicyReloadInterval = 10 * time.Second
+
+// policyEngine decides whether a caller may perform a route's action. Policies are read from
+// OPSORCH_POLICY_FILE and reloaded when the file changes, checked at most once per interval.
+type policyEngine struct {
+	path     string
+	interval time.Duration // zero disables reloading
+
+	mu      sync.Mutex
+	set     *policySet
+	stamp   string
+	checked time.Time
+}
+
+// policySet is the JSON document in the policy file. Rules are evaluated in order and the first
+// matching rule decides; a request no rule matches gets Default.
+type policySet struct {
+	Default string       `json:"default,omitempty"` // "allow" (the default) or "deny"
+	Rules   []policyRule `json:"rules"`
+}
+
+// policyRule matches a request when every condition it sets matches. A condition lists
+// alternatives, each of which may use path.Match wildcards such as "incident.*".
+type policyRule struct {
+	Name         string   `json:"name"`
+	Effect       string   `json:"effect"` // "allow" or "deny"
+	Actors       []string `json:"actors,omitempty"`
+	ActorTypes   []string `json:"actorTypes,omitempty"`
+	Groups       []string `json:"groups,omitempty"` // matches when the caller is in any of them
+	Actions      []string `json:"actions,omitempty"`
+	Services     []string `json:"services,omitempty"`
+	Teams        []string `json:"teams,omitempty"`
+	Environments []string `json:"environments,omitempty"`
+	Resources    []string `json:"resources,omitempty"` // IDs from the request path
+}
+
+// policyInput is what a decision is made on.
+type policyInput struct {
+	actorID   string
+	actorType string
+	groups    []string
+	action    string
+	scope     schema.QueryScope
+	resource  string
+}
+
+// newPolicyEngineFromEnv loads OPSORCH_POLICY_FILE. It returns nil when the variable is unset.
+func newPolicyEngineFromEnv() (*policyEngine, error) {
+	path := strings.TrimSpace(os.Getenv("OPSORCH_POLICY_FILE"))
+	if path == "" {
+		return nil, nil
+	}
+	e := &policyEngine{path: path, interval: envDuration("OPSORCH_POLICY_RELOAD_INTERVAL", defaultPolicyReloadInterval)}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// load reads the policy file. A file that fails to parse leaves the current policies in place.
+func (e *policyEngine) load() error {
+	e.stamp = fileStamp(e.path)
+	e.checked = time.Now()
+	raw, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("read OPSORCH_POLICY_FILE: %w", err)
+	}
+	set, err := parsePolicySet(raw)
+	if err != nil {
+		return fmt.Errorf("OPSORCH_POLICY_FILE: %w", err)
+	}
+	e.set = set
+	return nil
+}
+
+func parsePolicySet(raw []byte) (*policySet, error) {
+	var set policySet
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+	switch set.Default {
+	case "":
+		set.Default = "allow"
+	case "allow", "deny":
+	default:
+		return nil, fmt.Errorf("default must be allow or deny, got %q", set.Default)
+	}
+	for i, rule := range set.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if rule.Effect != "allow" && rule.Effect != "deny" {
+			return nil, fmt.Errorf("rule %s: effect must be allow or deny, got %q", rule.Name, rule.Effect)
+		}
+		for _, patterns := range [][]string{rule.Actors, rule.ActorTypes, rule.Groups, rule.Actions, rule.Services, rule.Teams, rule.Environments, rule.Resources} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("rule %s: invalid pattern %q", rule.Name, pattern)
+				}
+			}
+		}
+	}
+	return &set, nil
+}
+
+// decide returns whether in is allowed and the name of the rule that decided, or "default".
+func (e *policyEngine) decide(in policyInput) (bool, string) {
+	e.mu.Lock()
+	if e.interval > 0 && time.Since(e.checked) >= e.interval {
+		e.checked = time.Now()
+		if fileStamp(e.path) != e.stamp {
+			if err := e.load(); err != nil {
+				slog.Error("policy_reload_error", "file", e.path, "err", err)
+			} else {
+				logSystemAudit("policy.reloaded", map[string]string{"file": e.path, "rules": strconv.Itoa(len(e.set.Rules))})
+			}
+		}
+	}
+	set := e.set
+	e.mu.Unlock()
+
+	for _, rule := range set.Rules {
+		if rule.matches(in) {
+			return rule.Effect == "allow", rule.Name
+		}
+	}
+	return set.Default == "allow", "default"
+}
+
+// usesScope reports whether any rule has a service, team, or environment condition, so a
+// decision needs the request's scope.
+func (e *policyEngine) usesScope() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rule := range e.set.Rules {
+		if len(rule.Services) > 0 || len(rule.Teams) > 0 || len(rule.Environments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule policyRule) matches(in policyInput) bool {
+	groupMatch := len(rule.Groups) == 0
+	for _, group := range in.groups {
+		groupMatch = groupMatch || matchAny(rule.Groups, group)
+	}
+	// A deny rule limited to a scope also applies when the request's scope is unknown, so
+	// leaving it out cannot slip past the rule.
+	scopeMatch := matchAny
+	if rule.Effect == "deny" {
+		scopeMatch = matchAnyOrMissing
+	}
+	return groupMatch &&
+		matchAny(rule.Actors, in.actorID) &&
+		matchAny(rule.ActorTypes, in.actorType) &&
+		matchAny(rule.Actions, in.action) &&
+		scopeMatch(rule.Services, in.scope.Service) &&
+		scopeMatch(rule.Teams, in.scope.Team) &&
+		scopeMatch(rule.Environments, in.scope.Environment) &&
+		matchAny(rule.Resources, in.resource)
+}
+
+// matchAny reports whether value matches one of patterns. An empty list matches anything; an
+// empty value matches no pattern, so a rule about services never applies to a request that
+// names none.
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if value == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyOrMissing is matchAny, except that a missing value matches too.
+func matchAnyOrMissing(patterns []string, value string) bool {
+	return value == "" || matchAny(patterns, value)
+}
+
+// maxPolicyBody bounds how much of a request body is buffered to find its scope.
+const maxPolicyBody = 1 << 20
+
+// anonymousActor is the actor type policies see for a request without a credential identity:
+// authentication is disabled or the caller used OPSORCH_BEARER_TOKEN.
+const anonymousActor = "anonymous"
+
+// policyInputFor builds the decision input for a request to rt. The actor comes from the
+// caller's credential only, never from the X-Actor-Type and X-User-Id headers. Routes on an
+// {id} take their scope from the stored resource; the others read it from the body's "scope"
+// object or "service" field, or from the query string of GET requests, and the body is
+// restored for the handler. An error means the stored resource could not be looked up.
+func (s *Server) policyInputFor(r *http.Request, rt *compiledRoute, params map[string]string) (policyInput, error) {
+	in := policyInput{action: rt.Action, actorType: anonymousActor}
+	if caller := principalFrom(r.Context()); caller != nil {
+		in.groups = caller.groups
+		if caller.actorID != "" {
+			in.actorType, in.actorID = caller.actorType, caller.actorID
+		}
+	}
+	for _, name := range []string{"id", "planId", "runId", "capability"} {
+		if params[name] != "" {
+			in.resource = params[name]
+			break
+		}
+	}
+
+	if id := params["id"]; id != "" {
+		if !s.policy.usesScope() {
+			return in, nil
+		}
+		var err error
+		in.scope, err = s.resourceScope(r.Context(), rt.Capability, id)
+		return in, err
+	}
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		in.scope = schema.QueryScope{Service: q.Get("service"), Team: q.Get("team"), Environment: q.Get("environment")}
+		return in, nil
+	}
+	if rt.request == nil || r.Body == nil {
+		return in, nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxPolicyBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), r.Body))
+	if err != nil {
+		return in, nil
+	}
+	if _, ok := rt.request.(schema.QueryScope); ok {
+		_ = json.Unmarshal(raw, &in.scope)
+		return in, nil
+	}
+	var body struct {
+		Scope   schema.QueryScope `json:"scope"`
+		Service string            `json:"service"`
+	}
+	_ = json.Unmarshal(raw, &body) // a malformed body is rejected by the handler
+	in.scope = body.Scope
+	if in.scope.Service == "" {
+		in.scope.Service = body.Service
+	}
+	return in, nil
+}
+
+// resourceScope returns the scope of the resource with id, looked up through capability's
+// provider. Tickets carry no scope, so their routes get an empty one.
+func (s *Server) resourceScope(ctx context.Context, capability, id string) (schema.QueryScope, error) {
+	switch capability {
+	case "incident":
+		inc, err := s.incidentProvider().Get(ctx, id)
+		return schema.QueryScope{Service: inc.Service}, err
+	case "alert":
+		alert, err := s.alertProvider().Get(ctx, id)
+		return schema.QueryScope{Service: alert.Service}, err
+	case "deployment":
+		dep, err := s.deploymentProvider().Get(ctx, id)
+		return schema.QueryScope{Service: dep.Service, Environment: dep.Environment}, err
+	case "team":
+		return schema.QueryScope{Team: id}, nil
+	}
+	return schema.QueryScope{}, nil
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+const testPolicies = `{
+	"default": "allow",
+	"rules": [
+		{"name": "copilot-read-only", "effect": "deny", "actorTypes": ["copilot"], "actions": ["*.created", "*.updated", "*.appended", "message.sent"]},
+		{"name": "payments-own-services", "effect": "allow", "groups": ["payments"], "actions": ["incident.updated"], "services": ["checkout", "billing"]},
+		{"name": "payments-other-services", "effect": "deny", "groups": ["payments"], "actions": ["incident.updated"]},
+		{"name": "no-prod-logs", "effect": "deny", "actors": ["intern-*"], "actions": ["log.query"], "environments": ["prod"]}
+	]
+}`
+
+func writePolicyFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func policyServer(t *testing.T, body string) *Server {
+	t.Helper()
+	t.Setenv("OPSORCH_POLICY_FILE", writePolicyFile(t, body))
+	engine, err := newPolicyEngineFromEnv()
+	if err != nil {
+		t.Fatalf("load policies: %v", err)
+	}
+	return &Server{policy: engine, incident: IncidentHandler{provider: stubIncidentProvider{}}, log: LogHandler{provider: stubLogProvider{}}}
+}
+
+// servePolicy serves a request on behalf of caller; a nil caller sends it without a credential.
+func servePolicy(srv *Server, method, path, body string, caller *principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if caller != nil {
+		req = req.WithContext(withPrincipal(req.Context(), caller))
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// servicedIncidents serves incidents owned by the services in its map.
+type servicedIncidents struct {
+	stubIncidentProvider
+	services map[string]string
+}
+
+func (p servicedIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	service, ok := p.services[id]
+	if !ok {
+		return schema.Incident{}, orcherr.New("not_found", id+" not found", nil)
+	}
+	return schema.Incident{ID: id, Service: service}, nil
+}
+
+func TestPolicyRulesDecideBeforeHandlers(t *testing.T) {
+	srv := policyServer(t, testPolicies)
+	copilot := &principal{name: "copilot", actorID: "assistant", actorType: "copilot", scopes: []string{"*"}}
+	if w := servePolicy(srv, http.MethodPost, "/incidents/query", `{}`, copilot); w.Code != http.StatusOK {
+		t.Fatalf("expected copilot queries to be allowed, got %d", w.Code)
+	}
+	if w := servePolicy(srv, http.MethodPost, "/incidents", `{"title":"x"}`, copilot); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "copilot-read-only") {
+		t.Fatalf("expected copilot creates to be denied, got %d: %s", w.Code, w.Body.String())
+	}
+	alice := &principal{name: "alice", actorID: "alice", actorType: "user", scopes: []string{"*"}}
+	if w := servePolicy(srv, http.MethodPost, "/incidents", `{"title":"x"}`, alice); w.Code != http.StatusCreated {
+		t.Fatalf("expected the default to allow, got %d", w.Code)
+	}
+
+	intern := &principal{name: "intern", actorID: "intern-bob", actorType: "user", scopes: []string{"*"}}
+	if w := servePolicy(srv, http.MethodPost, "/logs/query", `{"scope":{"environment":"prod"}}`, intern); w.Code != http.StatusForbidden {
+		t.Fatalf("expected prod log queries to be denied, got %d", w.Code)
+	}
+	if w := servePolicy(srv, http.MethodPost, "/logs/query", `{"scope":{"environment":"staging"}}`, intern); w.Code != http.StatusOK {
+		t.Fatalf("expected staging log queries to be allowed, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPolicyDenyRulesApplyWithoutScope(t *testing.T) {
+	srv := policyServer(t, `{"rules":[
+		{"name": "freeze-checkout", "effect": "deny", "actions": ["incident.updated"], "services": ["checkout"]},
+		{"name": "no-prod-logs", "effect": "deny", "actions": ["log.query"], "environments": ["prod"]}
+	]}`)
+	srv.incident = IncidentHandler{provider: servicedIncidents{services: map[string]string{"INC-1": "search", "INC-2": ""}}}
+	alice := &principal{name: "alice", actorID: "alice", actorType: "user", scopes: []string{"*"}}
+
+	if w := servePolicy(srv, http.MethodPost, "/logs/query", `{}`, alice); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "no-prod-logs") {
+		t.Fatalf("expected a log query without an environment to be denied, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := servePolicy(srv, http.MethodPatch, "/incidents/INC-2", `{"status":"resolved"}`, alice); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "freeze-checkout") {
+		t.Fatalf("expected an update to an incident without a service to be denied, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := servePolicy(srv, http.MethodPatch, "/incidents/INC-1", `{"status":"resolved"}`, alice); w.Code != http.StatusOK {
+		t.Fatalf("expected an update outside the frozen service to be allowed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := servePolicy(srv, http.MethodPost, "/logs/query", `{"scope":{"environment":"staging"}}`, alice); w.Code != http.StatusOK {
+		t.Fatalf("expected staging log queries to be allowed, got %d", w.Code)
+	}
+}
+
+func TestPolicyIgnoresActorHeaders(t *testing.T) {
+	srv := policyServer(t, `{"rules":[
+		{"name": "copilot-read-only", "effect": "deny", "actorTypes": ["copilot"], "actions": ["*.created"]},
+		{"name": "anonymous-read-only", "effect": "deny", "actorTypes": ["anonymous"], "actions": ["*.created"]}
+	]}`)
+	// Without a credential identity the caller is anonymous, whatever the headers claim.
+	req := httptest.NewRequest(http.MethodPost, "/incidents", strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("X-Actor-Type", "user")
+	req.Header.Set("X-User-Id", "alice")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "anonymous-read-only") {
+		t.Fatalf("expected the anonymous rule to decide, got %d: %s", w.Code, w.Body.String())
+	}
+	legacy := &principal{name: "OPSORCH_BEARER_TOKEN", scopes: []string{"*"}}
+	if w := servePolicy(srv, http.MethodPost, "/incidents", `{"title":"x"}`, legacy); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "anonymous-read-only") {
+		t.Fatalf("expected the legacy token to be anonymous, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPolicyMatchesGroupsAndServices(t *testing.T) {
+	srv := policyServer(t, testPolicies)
+	srv.incident = IncidentHandler{provider: servicedIncidents{services: map[string]string{"INC-1": "checkout", "INC-2": "search"}}}
+	payments := &principal{name: "oidc", actorID: "pat", actorType: "user", groups: []string{"eng", "payments"}, scopes: []string{"*"}}
+	serve := func(id, body string) *httptest.ResponseRecorder {
+		return servePolicy(srv, http.MethodPatch, "/incidents/"+id, body, payments)
+	}
+	if w := serve("INC-1", `{"status":"resolved"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected updates to an owned service, got %d", w.Code)
+	}
+	// The scope is the stored incident's service, not the one named in the body.
+	if w := serve("INC-2", `{"service":"checkout","status":"resolved"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected updates to another service to be denied, got %d", w.Code)
+	}
+	if w := serve("INC-1", `{"service":"search"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected the stored service to decide, got %d", w.Code)
+	}
+	if w := serve("INC-404", `{"service":"checkout"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected a missing incident to be not found, got %d", w.Code)
+	}
+}
+
+func TestPolicyDeniesAreAudited(t *testing.T) {
+	srv := policyServer(t, testPolicies)
+	logs := captureLog(t)
+	intern := &principal{name: "intern", actorID: "intern-bob", actorType: "user", scopes: []string{"*"}}
+	servePolicy(srv, http.MethodPost, "/logs/query", `{"scope":{"service":"api","environment":"prod"}}`, intern)
+	out := logs.String()
+	for _, want := range []string{`"action":"policy.denied"`, `"actor_id":"intern-bob"`, `"action":"log.query"`, `"rule":"no-prod-logs"`, `"service":"api"`, `"environment":"prod"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %s in the audit log, got %q", want, out)
+		}
+	}
+}
+
+func TestPolicyFileReloads(t *testing.T) {
+	t.Setenv("OPSORCH_POLICY_RELOAD_INTERVAL", "1ns")
+	srv := policyServer(t, `{"rules":[]}`)
+	if w := servePolicy(srv, http.MethodGet, "/incidents/INC-1", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if err := os.WriteFile(srv.policy.path, []byte(`{"default":"deny","rules":[{"name":"reads","effect":"allow","actions":["*.query"]}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if w := servePolicy(srv, http.MethodGet, "/incidents/INC-1", "", nil); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "rule default") {
+		t.Fatalf("expected the reloaded default to deny, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// An invalid file keeps the last good policies.
+	if err := os.WriteFile(srv.policy.path, []byte(`{"rules":[{"name":"x","effect":"maybe"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if w := servePolicy(srv, http.MethodPost, "/incidents/query", `{}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the previous policies to stay in force, got %d", w.Code)
+	}
+}
+
+func TestParsePolicySetRejectsInvalidRules(t *testing.T) {
+	cases := map[string]string{
+		`{"default":"maybe"}`:                                               "default must be allow or deny",
+		`{"rules":[{"effect":"allow"}]}`:                                    "name is required",
+		`{"rules":[{"name":"a","effect":"permit"}]}`:                        "effect must be allow or deny",
+		`{"rules":[{"name":"a","effect":"deny","actions":["incident.["]}]}`: "invalid pattern",
+		`{"rules":[{"name":"a","effect":"deny","service":["x"]}]}`:          "unknown field",
+	}
+	for raw, want := range cases {
+		if _, err := parsePolicySet([]byte(raw)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", raw, want, err)
+		}
+	}
+}
@@ -27,7 +27,8 @@ type providerConfigRequest struct {
 
 func (s *Server) handleIncidentProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.incident.provider = newIncidentPluginProvider(pluginPath, cfg)
+		provider := observeIncident(pluginPath, newIncidentPluginProvider(pluginPath, cfg, s))
+		s.install("incident", func() { s.incident.provider = provider })
 		return nil
 	}
 	constructor, ok := incident.LookupProvider(name)
@@ -38,13 +39,14 @@ func (s *Server) handleIncidentProviderConfig(name, pluginPath string, cfg map[s
 	if err != nil {
 		return err
 	}
-	s.incident.provider = provider
+	s.install("incident", func() { s.incident.provider = observeIncident(name, provider) })
 	return nil
 }
 
 func (s *Server) handleLogProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.log.provider = newLogPluginProvider(pluginPath, cfg)
+		provider := observeLog(pluginPath, newLogPluginProvider(pluginPath, cfg, s))
+		s.install("log", func() { s.log.provider = provider })
 		return nil
 	}
 	constructor, ok := log.LookupProvider(name)
@@ -55,13 +57,14 @@ func (s *Server) handleLogProviderConfig(name, pluginPath string, cfg map[string
 	if err != nil {
 		return err
 	}
-	s.log.provider = provider
+	s.install("log", func() { s.log.provider = observeLog(name, provider) })
 	return nil
 }
 
 func (s *Server) handleMetricProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.metric.provider = newMetricPluginProvider(pluginPath, cfg)
+		provider := observeMetric(pluginPath, newMetricPluginProvider(pluginPath, cfg, s))
+		s.install("metric", func() { s.metric.provider = provider })
 		return nil
 	}
 	constructor, ok := metric.LookupProvider(name)
@@ -72,13 +75,14 @@ func (s *Server) handleMetricProviderConfig(name, pluginPath string, cfg map[str
 	if err != nil {
 		return err
 	}
-	s.metric.provider = provider
+	s.install("metric", func() { s.metric.provider = observeMetric(name, provider) })
 	return nil
 }
 
 func (s *Server) handleTicketProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.ticket.provider = newTicketPluginProvider(pluginPath, cfg)
+		provider := observeTicket(pluginPath, newTicketPluginProvider(pluginPath, cfg, s))
+		s.install("ticket", func() { s.ticket.provider = provider })
 		return nil
 	}
 	constructor, ok := ticket.LookupProvider(name)
@@ -89,13 +93,14 @@ func (s *Server) handleTicketProviderConfig(name, pluginPath string, cfg map[str
 	if err != nil {
 		return err
 	}
-	s.ticket.provider = provider
+	s.install("ticket", func() { s.ticket.provider = observeTicket(name, provider) })
 	return nil
 }
 
 func (s *Server) handleMessagingProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.messaging.provider = newMessagingPluginProvider(pluginPath, cfg)
+		provider := observeMessaging(pluginPath, newMessagingPluginProvider(pluginPath, cfg, s))
+		s.install("messaging", func() { s.messaging.provider = provider })
 		return nil
 	}
 	constructor, ok := messaging.LookupProvider(name)
@@ -106,13 +111,14 @@ func (s *Server) handleMessagingProviderConfig(name, pluginPath string, cfg map[
 	if err != nil {
 		return err
 	}
-	s.messaging.provider = provider
+	s.install("messaging", func() { s.messaging.provider = observeMessaging(name, provider) })
 	return nil
 }
 
 func (s *Server) handleServiceProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.service.provider = newServicePluginProvider(pluginPath, cfg)
+		provider := observeService(pluginPath, newServicePluginProvider(pluginPath, cfg, s))
+		s.install("service", func() { s.service.provider = provider })
 		return nil
 	}
 	constructor, ok := service.LookupProvider(name)
@@ -123,13 +129,14 @@ func (s *Server) handleServiceProviderConfig(name, pluginPath string, cfg map[st
 	if err != nil {
 		return err
 	}
-	s.service.provider = provider
+	s.install("service", func() { s.service.provider = observeService(name, provider) })
 	return nil
 }
 
 func (s *Server) handleOrchestrationProviderConfig(name, pluginPath string, cfg map[string]any) error {
 	if pluginPath != "" {
-		s.orchestration.provider = newOrchestrationPluginProvider(pluginPath, cfg)
+		provider := observeOrchestration(pluginPath, newOrchestrationPluginProvider(pluginPath, cfg, s))
+		s.install("orchestration", func() { s.orchestration.provider = provider })
 		return nil
 	}
 	constructor, ok := orchestration.LookupProvider(name)
@@ -140,33 +147,44 @@ func (s *Server) handleOrchestrationProviderConfig(name, pluginPath string, cfg
 	if err != nil {
 		return err
 	}
-	s.orchestration.provider = provider
+	s.install("orchestration", func() { s.orchestration.provider = observeOrchestration(name, provider) })
 	return nil
 }
 
-func (s *Server) handleProviderConfig(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/providers/") || r.Method != http.MethodPost {
-		return false
-	}
+func (s *Server) handleProviderConfig(w http.ResponseWriter, r *http.Request) {
 	if s.secret == nil {
 		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "secret_provider_missing", Message: "secret provider not configured"})
-		return true
+		return
 	}
 
-	raw := strings.TrimPrefix(r.URL.Path, "/providers/")
-	capability, ok := normalizeCapability(raw)
+	capability, ok := normalizeCapability(r.PathValue("capability"))
 	if !ok {
 		writeError(w, http.StatusNotFound, orcherr.OpsOrchError{Code: "not_found", Message: "unknown capability"})
-		return true
+		return
 	}
 	var req providerConfigRequest
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-		return true
+		return
 	}
 	if req.Provider == "" {
 		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: "provider required"})
-		return true
+		return
+	}
+
+	if req.Plugin != "" {
+		verify := pluginVerificationFromEnv(capability)
+		var err error
+		if isRemotePluginTarget(req.Plugin) {
+			err = verify.checkRemote(capability, req.Plugin)
+		} else {
+			_, err = verify.check(capability, req.Plugin)
+		}
+		if err != nil {
+			logAuditDetails(r, "provider.plugin_rejected", map[string]string{"plugin": req.Plugin, "reason": err.Error()})
+			writeError(w, http.StatusForbidden, *asOpsOrchError(err))
+			return
+		}
 	}
 
 	var applyErr error
@@ -187,24 +205,25 @@ func (s *Server) handleProviderConfig(w http.ResponseWriter, r *http.Request) bo
 		applyErr = s.handleOrchestrationProviderConfig(req.Provider, req.Plugin, req.Config)
 	default:
 		writeError(w, http.StatusNotFound, orcherr.OpsOrchError{Code: "not_found", Message: "unknown capability"})
-		return true
+		return
 	}
 
 	if applyErr != nil {
 		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: applyErr.Error()})
-		return true
+		return
 	}
+	// The new configuration replaces the one that failed at startup.
+	s.stopProviderRetry(capability)
 
 	// Persist config via secret provider for reuse.
 	if err := s.storeProviderConfig(capability, req); err != nil {
 		writeError(w, http.StatusBadGateway, orcherr.OpsOrchError{Code: "secret_store_error", Message: err.Error()})
-		return true
+		return
 	}
 
 	logAudit(r, "provider.configured")
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	return true
 }
 
 func (s *Server) storeProviderConfig(capability string, req providerConfigRequest) error {
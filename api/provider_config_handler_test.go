@@ -67,7 +67,7 @@ func TestProviderConfigAppliesStoredConfigOnLoad(t *testing.T) {
 	srv.secret = mem
 
 	// reload incident handler from stored secret
-	inc, err := newIncidentHandlerFromEnv(mem)
+	inc, err := newIncidentHandlerFromEnv(mem, nil)
 	if err != nil {
 		t.Fatalf("load from secret: %v", err)
 	}
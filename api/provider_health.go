@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opsorch/opsorch-core/health"
+)
+
+const (
+	// providerPingTTL is how long a Ping result is reused, so frequent health polling does not
+	// turn into load on every backend.
+	providerPingTTL     = 10 * time.Second
+	providerPingTimeout = 5 * time.Second
+)
+
+// Capability health statuses reported by /ready and /health/providers.
+const (
+	capabilityOK           = "ok"
+	capabilityUnhealthy    = "unhealthy"    // the provider's health check fails
+	capabilityInitializing = "initializing" // the provider failed to construct and is being retried
+	capabilityUnconfigured = "unconfigured" // no provider or plugin is set
+)
+
+// providerState records the outcome of calls to one provider. Calls that fail with a caller
+// error such as not_found count as neither a success nor an error.
+type providerState struct {
+	mu            sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+	pingedAt      time.Time
+	pingErr       error
+	pinging       chan struct{} // closed when the health check in flight finishes; nil when none is
+}
+
+func (st *providerState) record(err error) {
+	now := time.Now()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	switch {
+	case err == nil:
+		st.lastSuccessAt = now
+	case providerFault(err):
+		st.lastError, st.lastErrorAt = err.Error(), now
+	}
+}
+
+// providerFault reports whether err points at the provider or its backend rather than at the
+// caller's request.
+func providerFault(err error) bool {
+	if oe := asOpsOrchError(err); oe != nil {
+		switch oe.Code {
+		case "not_found", "bad_request", "forbidden", "not_implemented":
+			return false
+		}
+	}
+	return true
+}
+
+// ping runs the provider's health check, reusing a result younger than providerPingTTL, and
+// returns when the check ran. The time is zero when the provider does not implement
+// health.Pinger.
+func (o providerObserver) ping(ctx context.Context) (time.Time, error) {
+	pinger, ok := o.inner.(health.Pinger)
+	if !ok {
+		return time.Time{}, nil
+	}
+	st := o.state
+	st.mu.Lock()
+	if time.Since(st.pingedAt) < providerPingTTL {
+		defer st.mu.Unlock()
+		return st.pingedAt, st.pingErr
+	}
+	if done := st.pinging; done != nil {
+		// Another request is running the check; share its result.
+		st.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		return st.pingedAt, st.pingErr
+	}
+	done := make(chan struct{})
+	st.pinging = done
+	st.mu.Unlock()
+
+	// The lock is not held while the provider answers, so calls recording their outcome are
+	// not stalled. The check outlives this caller's cancellation since others may wait on it.
+	pingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), providerPingTimeout)
+	err := pinger.Ping(pingCtx)
+	cancel()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pingErr, st.pingedAt, st.pinging = err, time.Now(), nil
+	close(done)
+	return st.pingedAt, st.pingErr
+}
+
+// capabilityHealth is one capability's entry in GET /health/providers.
+type capabilityHealth struct {
+	Capability string `json:"capability"`
+	Status     string `json:"status"` // ok, unhealthy, initializing, or unconfigured
+	Configured bool   `json:"configured"`
+	Provider   string `json:"provider,omitempty"` // registered provider name
+	Plugin     string `json:"plugin,omitempty"`   // plugin path or endpoint
+	// Error says why the provider last failed to construct or why its health check fails.
+	Error         string     `json:"error,omitempty"`
+	Attempts      int        `json:"attempts,omitempty"`      // failed construction attempts while initializing
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"` // when construction is next retried
+	CheckedAt     *time.Time `json:"checkedAt,omitempty"`     // when the health check last ran
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+}
+
+// providerHealthReport is the body of GET /health/providers.
+type providerHealthReport struct {
+	Status       string             `json:"status"` // ok, or degraded when a configured capability is not ok
+	Capabilities []capabilityHealth `json:"capabilities"`
+}
+
+// readyResponse is the body of GET /ready.
+type readyResponse struct {
+	Status       string            `json:"status"` // ready, not_ready, or draining
+	Capabilities map[string]string `json:"capabilities,omitempty"`
+}
+
+// capabilityHealth reports on one capability. Health checks run only when ping is set.
+func (s *Server) capabilityHealth(ctx context.Context, capability string, ping bool) capabilityHealth {
+	out := capabilityHealth{Capability: capability, Status: capabilityOK, Configured: true}
+	provider := s.capabilityProvider(capability)
+	if provider == nil {
+		out.Status, out.Configured = capabilityUnconfigured, false
+		if state, ok := s.initializing(capability); ok {
+			out.Status, out.Configured, out.Error = capabilityInitializing, true, state.lastError.Error()
+			out.Attempts, out.NextAttemptAt = state.attempts, timePtr(state.next)
+		}
+		return out
+	}
+	observed, ok := provider.(interface{ observer() providerObserver })
+	if !ok {
+		return out
+	}
+	o := observed.observer()
+	if _, plugin := o.inner.(interface{ shutdown(context.Context) }); plugin {
+		out.Plugin = o.name
+	} else {
+		out.Provider = o.name
+	}
+
+	o.state.mu.Lock()
+	out.LastError = o.state.lastError
+	out.LastErrorAt = timePtr(o.state.lastErrorAt)
+	out.LastSuccessAt = timePtr(o.state.lastSuccessAt)
+	o.state.mu.Unlock()
+
+	if !ping {
+		return out
+	}
+	checkedAt, err := o.ping(ctx)
+	out.CheckedAt = timePtr(checkedAt)
+	if err != nil {
+		out.Status, out.Error = capabilityUnhealthy, err.Error()
+	}
+	return out
+}
+
+// observer exposes the state shared by every observed provider wrapper.
+func (o providerObserver) observer() providerObserver {
+	return o
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// handleProviderHealth runs every configured provider's health check concurrently and reports
+// each capability. The report is informational, so it is always served with 200.
+func (s *Server) handleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	report := providerHealthReport{Status: capabilityOK, Capabilities: make([]capabilityHealth, len(capabilities))}
+	var wg sync.WaitGroup
+	for i, capability := range capabilities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Capabilities[i] = s.capabilityHealth(r.Context(), capability, true)
+		}()
+	}
+	wg.Wait()
+	for _, c := range report.Capabilities {
+		if c.Configured && c.Status != capabilityOK {
+			report.Status = "degraded"
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
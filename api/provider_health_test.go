@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// pingingLogProvider counts health checks and fails them while down is set.
+type pingingLogProvider struct {
+	stubLogProvider
+	pings *atomic.Int32
+	down  *atomic.Bool
+}
+
+func (p pingingLogProvider) Ping(ctx context.Context) error {
+	p.pings.Add(1)
+	if p.down.Load() {
+		return errors.New("backend unreachable")
+	}
+	return nil
+}
+
+// slowPingLogProvider holds health checks until release is closed.
+type slowPingLogProvider struct {
+	stubLogProvider
+	pings   *atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p slowPingLogProvider) Ping(ctx context.Context) error {
+	if p.pings.Add(1) == 1 {
+		close(p.started)
+	}
+	<-p.release
+	return nil
+}
+
+// failingAlertProvider fails every call with a provider error.
+type failingAlertProvider struct{ stubAlertProvider }
+
+func (failingAlertProvider) Get(ctx context.Context, id string) (schema.Alert, error) {
+	return schema.Alert{}, orcherr.New("provider_error", "upstream returned 500", nil)
+}
+
+func getProviderHealth(t *testing.T, srv *Server) (providerHealthReport, map[string]capabilityHealth) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/providers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report providerHealthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	byCapability := map[string]capabilityHealth{}
+	for _, c := range report.Capabilities {
+		byCapability[c.Capability] = c
+	}
+	return report, byCapability
+}
+
+func TestProviderHealthReportsEachCapability(t *testing.T) {
+	var pings atomic.Int32
+	var down atomic.Bool
+	srv := &Server{
+		incident: IncidentHandler{provider: observeIncident("health-test", missingIncidentProvider{})},
+		alert:    AlertHandler{provider: observeAlert("flaky", failingAlertProvider{})},
+		log:      LogHandler{provider: observeLog("elastic", pingingLogProvider{pings: &pings, down: &down})},
+		retries: map[string]*providerRetry{"deployment": {
+			capability: "deployment",
+			attempts:   2,
+			lastError:  errors.New("deployment provider github not registered"),
+			next:       time.Now().Add(time.Minute),
+		}},
+	}
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/incidents/INC-1", nil))
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/alerts/A-1", nil))
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`)))
+
+	report, got := getProviderHealth(t, srv)
+	if report.Status != "degraded" || len(report.Capabilities) != len(capabilities) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if c := got["log"]; c.Status != capabilityOK || c.Provider != "elastic" || c.CheckedAt == nil || c.LastSuccessAt == nil || c.LastError != "" {
+		t.Fatalf("unexpected log health %+v", c)
+	}
+	// A not_found answer is the caller's problem and leaves the provider's record alone.
+	if c := got["incident"]; c.Status != capabilityOK || c.LastError != "" || c.LastSuccessAt != nil || c.CheckedAt != nil {
+		t.Fatalf("unexpected incident health %+v", c)
+	}
+	if c := got["alert"]; c.LastError == "" || c.LastErrorAt == nil || c.LastSuccessAt != nil {
+		t.Fatalf("expected the alert provider error to be recorded, got %+v", c)
+	}
+	if c := got["deployment"]; c.Status != capabilityInitializing || !c.Configured || c.Error == "" || c.Attempts != 2 || c.NextAttemptAt == nil {
+		t.Fatalf("expected the pending retry to be reported, got %+v", c)
+	}
+	if c := got["ticket"]; c.Status != capabilityUnconfigured || c.Configured {
+		t.Fatalf("unexpected ticket health %+v", c)
+	}
+
+	// Results are cached, so polling does not ping the backend every time.
+	down.Store(true)
+	if _, got := getProviderHealth(t, srv); got["log"].Status != capabilityOK || pings.Load() != 1 {
+		t.Fatalf("expected the cached ping result, got %+v after %d pings", got["log"], pings.Load())
+	}
+	o := srv.log.provider.(interface{ observer() providerObserver }).observer()
+	o.state.mu.Lock()
+	o.state.pingedAt = time.Now().Add(-providerPingTTL)
+	o.state.mu.Unlock()
+	if _, got := getProviderHealth(t, srv); got["log"].Status != capabilityUnhealthy || got["log"].Error != "backend unreachable" {
+		t.Fatalf("expected a failed ping to mark log unhealthy, got %+v", got["log"])
+	}
+}
+
+func TestProviderHealthCheckDoesNotStallCalls(t *testing.T) {
+	var pings atomic.Int32
+	provider := slowPingLogProvider{pings: &pings, started: make(chan struct{}), release: make(chan struct{})}
+	srv := &Server{log: LogHandler{provider: observeLog("elastic", provider)}}
+	checks := make(chan map[string]capabilityHealth, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, got := getProviderHealth(t, srv)
+			checks <- got
+		}()
+	}
+	<-provider.started
+
+	// Queries record their outcome while the backend is still answering the check.
+	done := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`)))
+		done <- w.Code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Fatalf("expected the query to succeed, got %d", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("query waited on the health check")
+	}
+
+	close(provider.release)
+	for i := 0; i < 2; i++ {
+		if got := <-checks; got["log"].Status != capabilityOK || got["log"].CheckedAt == nil {
+			t.Fatalf("unexpected log health %+v", got["log"])
+		}
+	}
+	if pings.Load() != 1 {
+		t.Fatalf("expected concurrent checks to share one ping, got %d", pings.Load())
+	}
+}
+
+func TestReadyReportsInitializingCapabilities(t *testing.T) {
+	srv := &Server{incident: IncidentHandler{provider: observeIncident("stub", stubIncidentProvider{})}}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var resp readyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || resp.Status != "ready" || resp.Capabilities["incident"] != capabilityOK || len(resp.Capabilities) != 1 {
+		t.Fatalf("expected ready with one capability, got %d %s", w.Code, w.Body.String())
+	}
+
+	// A provider still being retried keeps the server out of rotation.
+	srv.retries = map[string]*providerRetry{"service": {capability: "service", attempts: 1, lastError: errors.New("bad config")}}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusServiceUnavailable || resp.Status != "not_ready" || resp.Capabilities["service"] != capabilityInitializing {
+		t.Fatalf("expected service to be reported as initializing, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyFailsWhileAProviderIsUnhealthy(t *testing.T) {
+	var pings atomic.Int32
+	var down atomic.Bool
+	down.Store(true)
+	srv := &Server{log: LogHandler{provider: observeLog("elastic", pingingLogProvider{pings: &pings, down: &down})}}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var resp readyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusServiceUnavailable || resp.Capabilities["log"] != capabilityUnhealthy {
+		t.Fatalf("expected 503 with log unhealthy, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProviderHealthRequiresProvidersRead(t *testing.T) {
+	srv := &Server{tokens: testTokenStore(t)}
+	if w := serveWithToken(srv, http.MethodGet, "/health/providers", "dash-secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected providers:read to be required, got %d", w.Code)
+	}
+}
+
+func TestPluginPingFollowsManifest(t *testing.T) {
+	captureLog(t)
+	// Declares plugin.ping and fails it.
+	script := writePluginScript(t, shellIDHelpers+`read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\",\"capability\":\"log\",\"methods\":[\"log.query\",\"plugin.ping\"]}}"
+while read -r line; do
+  echo "{\"id\":$(id_of "$line"),\"error\":{\"code\":\"provider_error\",\"message\":\"$(method_of "$line") failed\"}}"
+done
+`)
+	provider := observeLog(script, newLogPluginProvider(script, nil, nil))
+	defer provider.(interface{ shutdown(context.Context) }).shutdown(context.Background())
+	srv := &Server{log: LogHandler{provider: provider}}
+
+	// Before the first call there is no manifest, so core has nothing to ask.
+	if _, got := getProviderHealth(t, srv); got["log"].Status != capabilityOK || got["log"].Plugin != script {
+		t.Fatalf("expected an idle plugin to pass, got %+v", got["log"])
+	}
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`)))
+	o := provider.(interface{ observer() providerObserver }).observer()
+	o.state.mu.Lock()
+	o.state.pingedAt = time.Time{}
+	o.state.mu.Unlock()
+	if _, got := getProviderHealth(t, srv); got["log"].Status != capabilityUnhealthy || got["log"].Error != "provider_error: plugin.ping failed" {
+		t.Fatalf("expected the plugin's ping answer, got %+v", got["log"])
+	}
+}
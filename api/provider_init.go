@@ -0,0 +1,214 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+const (
+	defaultProviderRetryInitial = time.Second
+	defaultProviderRetryMax     = 5 * time.Minute
+)
+
+// providerRetry rebuilds a capability's handler in the background after it failed to construct
+// at startup. Attempts back off exponentially and stop once one succeeds, the capability is
+// configured through POST /providers/{capability}, or the server shuts down. Until then the
+// capability's routes answer 503 provider_initializing.
+type providerRetry struct {
+	capability string
+	initial    time.Duration
+	max        time.Duration
+
+	mu        sync.Mutex
+	attempts  int
+	lastError error
+	next      time.Time // when the next attempt runs
+	timer     *time.Timer
+	stopped   bool // succeeded or abandoned
+}
+
+// loadCapability constructs capability's handler from the environment and returns a function
+// that installs it, to be run under handlersMu. Construction runs without holding any lock,
+// since constructors may dial their backends.
+func (s *Server) loadCapability(capability string) (func(), error) {
+	switch capability {
+	case "incident":
+		h, err := newIncidentHandlerFromEnv(s.secret, s)
+		return func() { s.incident = h }, err
+	case "alert":
+		h, err := newAlertHandlerFromEnv(s.secret, s)
+		return func() { s.alert = h }, err
+	case "log":
+		h, err := newLogHandlerFromEnv(s.secret, s)
+		return func() { s.log = h }, err
+	case "metric":
+		h, err := newMetricHandlerFromEnv(s.secret, s)
+		return func() { s.metric = h }, err
+	case "ticket":
+		h, err := newTicketHandlerFromEnv(s.secret, s)
+		return func() { s.ticket = h }, err
+	case "messaging":
+		h, err := newMessagingHandlerFromEnv(s.secret, s)
+		return func() { s.messaging = h }, err
+	case "service":
+		h, err := newServiceHandlerFromEnv(s.secret, s)
+		return func() { s.service = h }, err
+	case "deployment":
+		h, err := newDeploymentHandlerFromEnv(s.secret, s)
+		return func() { s.deployment = h }, err
+	case "team":
+		h, err := newTeamHandlerFromEnv(s.secret, s)
+		return func() { s.team = h }, err
+	case "orchestration":
+		h, err := newOrchestrationHandlerFromEnv(s.secret, s)
+		return func() { s.orchestration = h }, err
+	default:
+		return nil, fmt.Errorf("unknown capability %s", capability)
+	}
+}
+
+// loadCapabilities constructs every capability's handler. A capability whose constructor
+// fails starts degraded and is retried in the background instead of failing startup.
+func (s *Server) loadCapabilities() {
+	s.retries = make(map[string]*providerRetry)
+	for _, capability := range capabilities {
+		install, err := s.loadCapability(capability)
+		if err == nil {
+			s.install(capability, install)
+			continue
+		}
+		r := &providerRetry{capability: capability, initial: cmp.Or(s.retryInitial, defaultProviderRetryInitial), max: defaultProviderRetryMax}
+		s.retries[capability] = r
+		r.mu.Lock()
+		s.scheduleProviderRetry(r, err)
+		r.mu.Unlock()
+	}
+}
+
+// scheduleProviderRetry records a failed attempt and arms the timer for the next one. The
+// caller holds r.mu.
+func (s *Server) scheduleProviderRetry(r *providerRetry, err error) {
+	r.attempts++
+	r.lastError = err
+	delay := r.initial * time.Duration(math.Pow(2, float64(min(r.attempts-1, 30))))
+	if delay > r.max || delay <= 0 {
+		delay = r.max
+	}
+	r.next = time.Now().Add(delay)
+	r.timer = time.AfterFunc(delay, func() { s.retryProvider(r) })
+	slog.Error("provider_init_error", "capability", r.capability, "attempt", r.attempts, "retry_in", delay.String(), "err", err)
+}
+
+func (s *Server) retryProvider(r *providerRetry) {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	install, err := s.loadCapability(r.capability)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	// Checking and installing under one lock keeps a provider configured at runtime from
+	// being overwritten by this attempt.
+	s.handlersMu.Lock()
+	configured := s.installedProvider(r.capability) != nil
+	if !configured && err == nil {
+		install()
+	}
+	s.handlersMu.Unlock()
+	switch {
+	case configured:
+		// Configured at runtime while this attempt ran.
+		r.stopped = true
+	case err != nil:
+		s.scheduleProviderRetry(r, err)
+	default:
+		r.stopped, r.lastError = true, nil
+		slog.Info("provider_initialized", "capability", r.capability, "attempts", r.attempts+1)
+	}
+}
+
+// install runs set, which replaces capability's handler, under handlersMu. A plugin-backed
+// provider it replaces is shut down, so its process does not outlive it.
+func (s *Server) install(capability string, set func()) {
+	s.handlersMu.Lock()
+	previous := s.installedProvider(capability)
+	set()
+	s.handlersMu.Unlock()
+	if p, ok := previous.(pluginBacked); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), pluginShutdownTimeout)
+		defer cancel()
+		p.shutdown(ctx)
+	}
+}
+
+// stopProviderRetry abandons capability's background retry, if any.
+func (s *Server) stopProviderRetry(capability string) {
+	if r := s.retries[capability]; r != nil {
+		r.stop()
+	}
+}
+
+// stopProviderRetries abandons every background retry.
+func (s *Server) stopProviderRetries() {
+	for _, r := range s.retries {
+		r.stop()
+	}
+}
+
+func (r *providerRetry) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// providerRetryState is a snapshot of a pending retry.
+type providerRetryState struct {
+	attempts  int
+	lastError error
+	next      time.Time
+}
+
+// initializing reports capability's pending retry. ok is false when the capability is not
+// waiting on one.
+func (s *Server) initializing(capability string) (providerRetryState, bool) {
+	r := s.retries[capability]
+	if r == nil {
+		return providerRetryState{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return providerRetryState{}, false
+	}
+	return providerRetryState{attempts: r.attempts, lastError: r.lastError, next: r.next}, true
+}
+
+// writeProviderInitializing answers a request for a capability whose provider is still being
+// retried. Retry-After points at the next attempt.
+func writeProviderInitializing(w http.ResponseWriter, capability string, state providerRetryState) {
+	wait := max(1, int(math.Ceil(time.Until(state.next).Seconds())))
+	w.Header().Set("Retry-After", strconv.Itoa(wait))
+	writeError(w, http.StatusServiceUnavailable, orcherr.OpsOrchError{
+		Code:    "provider_initializing",
+		Message: fmt.Sprintf("%s provider is initializing after %d failed attempts: %v", capability, state.attempts, state.lastError),
+	})
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/service"
+)
+
+// flakyServiceUp holds the switch of each provider registered by registerFlakyServiceProvider.
+// The registry outlives a single test run, so the switch is shared across runs.
+var flakyServiceUp sync.Map
+
+// registerFlakyServiceProvider registers a service provider whose constructor fails until the
+// returned switch is set.
+func registerFlakyServiceProvider(t *testing.T, name string) *atomic.Bool {
+	t.Helper()
+	v, loaded := flakyServiceUp.LoadOrStore(name, &atomic.Bool{})
+	up := v.(*atomic.Bool)
+	up.Store(false)
+	if loaded {
+		return up
+	}
+	err := service.RegisterProvider(name, func(cfg map[string]any) (service.Provider, error) {
+		if !up.Load() {
+			return nil, errors.New("catalog unreachable")
+		}
+		return stubServiceProvider{}, nil
+	})
+	if err != nil {
+		t.Fatalf("register service provider: %v", err)
+	}
+	return up
+}
+
+func TestFailedProviderRetriesUntilItStarts(t *testing.T) {
+	logs := captureLog(t)
+	up := registerFlakyServiceProvider(t, "flaky-init-retry")
+	t.Setenv("OPSORCH_SERVICE_PROVIDER", "flaky-init-retry")
+	srv := &Server{retryInitial: 10 * time.Millisecond}
+	srv.loadCapabilities()
+	defer srv.stopProviderRetries()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/services/query", strings.NewReader(`{}`)))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"provider_initializing"`) || !strings.Contains(w.Body.String(), "catalog unreachable") {
+		t.Fatalf("expected 503 provider_initializing, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After, got %q", w.Header().Get("Retry-After"))
+	}
+	// Capabilities that were never configured still answer 501.
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tickets/1", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for an unconfigured capability, got %d", w.Code)
+	}
+
+	up.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := srv.initializing("service"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("service provider never started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/services/query", strings.NewReader(`{}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the retried provider to serve, got %d %s", w.Code, w.Body.String())
+	}
+	if out := logs.String(); !strings.Contains(out, `"msg":"provider_init_error","capability":"service","attempt":1`) || !strings.Contains(out, `"msg":"provider_initialized","capability":"service"`) {
+		t.Fatalf("expected retry logs, got %q", out)
+	}
+}
+
+func TestProviderHealthReportsInitializingCapability(t *testing.T) {
+	captureLog(t)
+	registerFlakyServiceProvider(t, "flaky-init-health")
+	t.Setenv("OPSORCH_SERVICE_PROVIDER", "flaky-init-health")
+	srv := &Server{retryInitial: time.Minute}
+	srv.loadCapabilities()
+	defer srv.stopProviderRetries()
+
+	report, got := getProviderHealth(t, srv)
+	c := got["service"]
+	if report.Status != "degraded" || c.Status != capabilityInitializing || !c.Configured || c.Error != "catalog unreachable" || c.Attempts != 1 || c.NextAttemptAt == nil {
+		t.Fatalf("expected service to be initializing, got %+v", c)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/services/query", strings.NewReader(`{}`)))
+	if retryAfter := w.Header().Get("Retry-After"); w.Code != http.StatusServiceUnavailable || retryAfter != "60" {
+		t.Fatalf("expected Retry-After to point at the next attempt, got %d %q", w.Code, retryAfter)
+	}
+}
+
+func TestProviderConfigStopsRetry(t *testing.T) {
+	captureLog(t)
+	registerFlakyServiceProvider(t, "flaky-init-config")
+	t.Setenv("OPSORCH_SERVICE_PROVIDER", "flaky-init-config")
+	srv := &Server{secret: &memorySecret{store: map[string]string{}}, retryInitial: time.Hour}
+	srv.loadCapabilities()
+	defer srv.stopProviderRetries()
+
+	if err := service.RegisterProvider("init-config-stub", func(cfg map[string]any) (service.Provider, error) { return stubServiceProvider{}, nil }); err != nil && !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("register service provider: %v", err)
+	}
+	body, _ := json.Marshal(map[string]any{"provider": "init-config-stub"})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/providers/service", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if _, ok := srv.initializing("service"); ok {
+		t.Fatalf("expected runtime configuration to stop the retry")
+	}
+	if _, got := getProviderHealth(t, srv); got["service"].Status != capabilityOK {
+		t.Fatalf("expected service to be ok, got %+v", got["service"])
+	}
+}
+
+func TestProviderRetryRacesRuntimeConfig(t *testing.T) {
+	captureLog(t)
+	up := registerFlakyServiceProvider(t, "flaky-init-race")
+	t.Setenv("OPSORCH_SERVICE_PROVIDER", "flaky-init-race")
+	if err := service.RegisterProvider("init-race-stub", func(cfg map[string]any) (service.Provider, error) { return stubServiceProvider{}, nil }); err != nil && !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("register service provider: %v", err)
+	}
+	srv := &Server{secret: &memorySecret{store: map[string]string{}}, retryInitial: time.Millisecond}
+	srv.loadCapabilities()
+	defer srv.stopProviderRetries()
+
+	// Dispatch keeps reading the handler while the retry and the runtime config replace it.
+	// Serving whole requests here would hide a race behind the log's lock.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				srv.capabilityProvider("service")
+			}
+		}
+	}()
+	up.Store(true)
+	body, _ := json.Marshal(map[string]any{"provider": "init-race-stub"})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/providers/service", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if _, ok := srv.initializing("service"); ok {
+		t.Fatalf("expected the retry to stop")
+	}
+	if name := srv.capabilityProvider("service").(interface{ observer() providerObserver }).observer().name; name != "init-race-stub" {
+		t.Fatalf("expected the runtime configuration to win, got provider %s", name)
+	}
+}
+
+func TestReadyWaitsForFailedProvider(t *testing.T) {
+	captureLog(t)
+	up := registerFlakyServiceProvider(t, "flaky-init-ready")
+	t.Setenv("OPSORCH_SERVICE_PROVIDER", "flaky-init-ready")
+	srv := &Server{retryInitial: 10 * time.Millisecond}
+	srv.loadCapabilities()
+	defer srv.stopProviderRetries()
+
+	ready := func() (int, readyResponse) {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		var resp readyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return w.Code, resp
+	}
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.Status != "not_ready" || resp.Capabilities["service"] != capabilityInitializing {
+		t.Fatalf("expected not ready while the provider fails to start, got %d %+v", code, resp)
+	}
+	up.Store(true)
+	waitFor(t, 2*time.Second, func() bool {
+		code, _ := ready()
+		return code == http.StatusOK
+	})
+}
@@ -0,0 +1,378 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/opsorch/opsorch-core/alert"
+	"github.com/opsorch/opsorch-core/deployment"
+	"github.com/opsorch/opsorch-core/incident"
+	"github.com/opsorch/opsorch-core/log"
+	"github.com/opsorch/opsorch-core/messaging"
+	"github.com/opsorch/opsorch-core/metric"
+	"github.com/opsorch/opsorch-core/orchestration"
+	"github.com/opsorch/opsorch-core/schema"
+	"github.com/opsorch/opsorch-core/service"
+	"github.com/opsorch/opsorch-core/team"
+	"github.com/opsorch/opsorch-core/ticket"
+)
+
+// providerObserver is embedded in the wrappers below, which record telemetry, a client span, and
+// the health state reported by /health/providers for every call to the provider they wrap.
+// Methods are labeled with the plugin RPC names, e.g. incident.get.
+type providerObserver struct {
+	capability string
+	name       string // registered provider name, or the plugin path
+	inner      any    // the wrapped provider
+	state      *providerState
+}
+
+func newProviderObserver(capability, name string, inner any) providerObserver {
+	return providerObserver{capability: capability, name: name, inner: inner, state: &providerState{}}
+}
+
+// shutdown forwards to plugin-backed providers so Server.stopPlugins still reaches them.
+func (o providerObserver) shutdown(ctx context.Context) {
+	if p, ok := o.inner.(interface{ shutdown(context.Context) }); ok {
+		p.shutdown(ctx)
+	}
+}
+
+func observeCall[T any](ctx context.Context, o providerObserver, method string, call func(context.Context) (T, error)) (T, error) {
+	ctx, span := startSpan(ctx, method, spanKindClient)
+	span.setAttr("opsorch.capability", o.capability)
+	span.setAttr("opsorch.provider", o.name)
+	start := time.Now()
+	out, err := call(ctx)
+	code := "ok"
+	if err != nil {
+		code = "provider_error"
+		if oe := asOpsOrchError(err); oe != nil && oe.Code != "" {
+			code = oe.Code
+		}
+		span.setAttr("opsorch.error_code", code)
+		span.setError(err.Error())
+	}
+	span.finish()
+	o.state.record(err)
+	selfMetrics.providerCalls.add(1, o.capability, o.name, method, code)
+	selfMetrics.providerDuration.observe(time.Since(start).Seconds(), o.capability, o.name, method)
+	return out, err
+}
+
+func observeErr(ctx context.Context, o providerObserver, method string, call func(context.Context) error) error {
+	_, err := observeCall(ctx, o, method, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, call(ctx)
+	})
+	return err
+}
+
+// Incident ---------------------------------------------------------------------
+
+type observedIncidentProvider struct {
+	incident.Provider
+	providerObserver
+}
+
+func observeIncident(name string, p incident.Provider) incident.Provider {
+	return observedIncidentProvider{p, newProviderObserver("incident", name, p)}
+}
+
+func (p observedIncidentProvider) Query(ctx context.Context, query schema.IncidentQuery) ([]schema.Incident, error) {
+	return observeCall(ctx, p.providerObserver, "incident.query", func(ctx context.Context) ([]schema.Incident, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedIncidentProvider) Get(ctx context.Context, id string) (schema.Incident, error) {
+	return observeCall(ctx, p.providerObserver, "incident.get", func(ctx context.Context) (schema.Incident, error) {
+		return p.Provider.Get(ctx, id)
+	})
+}
+
+func (p observedIncidentProvider) Create(ctx context.Context, in schema.CreateIncidentInput) (schema.Incident, error) {
+	return observeCall(ctx, p.providerObserver, "incident.create", func(ctx context.Context) (schema.Incident, error) {
+		return p.Provider.Create(ctx, in)
+	})
+}
+
+func (p observedIncidentProvider) Update(ctx context.Context, id string, in schema.UpdateIncidentInput) (schema.Incident, error) {
+	return observeCall(ctx, p.providerObserver, "incident.update", func(ctx context.Context) (schema.Incident, error) {
+		return p.Provider.Update(ctx, id, in)
+	})
+}
+
+func (p observedIncidentProvider) GetTimeline(ctx context.Context, id string) ([]schema.TimelineEntry, error) {
+	return observeCall(ctx, p.providerObserver, "incident.timeline.get", func(ctx context.Context) ([]schema.TimelineEntry, error) {
+		return p.Provider.GetTimeline(ctx, id)
+	})
+}
+
+func (p observedIncidentProvider) AppendTimeline(ctx context.Context, id string, entry schema.TimelineAppendInput) error {
+	return observeErr(ctx, p.providerObserver, "incident.timeline.append", func(ctx context.Context) error {
+		return p.Provider.AppendTimeline(ctx, id, entry)
+	})
+}
+
+// Alert ------------------------------------------------------------------------
+
+type observedAlertProvider struct {
+	alert.Provider
+	providerObserver
+}
+
+func observeAlert(name string, p alert.Provider) alert.Provider {
+	return observedAlertProvider{p, newProviderObserver("alert", name, p)}
+}
+
+func (p observedAlertProvider) Query(ctx context.Context, query schema.AlertQuery) ([]schema.Alert, error) {
+	return observeCall(ctx, p.providerObserver, "alert.query", func(ctx context.Context) ([]schema.Alert, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedAlertProvider) Get(ctx context.Context, id string) (schema.Alert, error) {
+	return observeCall(ctx, p.providerObserver, "alert.get", func(ctx context.Context) (schema.Alert, error) {
+		return p.Provider.Get(ctx, id)
+	})
+}
+
+// Log --------------------------------------------------------------------------
+
+type observedLogProvider struct {
+	log.Provider
+	providerObserver
+}
+
+// observedStreamingLogProvider keeps the streaming interface visible to the log handler.
+type observedStreamingLogProvider struct {
+	observedLogProvider
+	streamer log.StreamingProvider
+}
+
+func observeLog(name string, p log.Provider) log.Provider {
+	observed := observedLogProvider{p, newProviderObserver("log", name, p)}
+	if streamer, ok := p.(log.StreamingProvider); ok {
+		return observedStreamingLogProvider{observed, streamer}
+	}
+	return observed
+}
+
+func (p observedLogProvider) Query(ctx context.Context, query schema.LogQuery) (schema.LogEntries, error) {
+	return observeCall(ctx, p.providerObserver, "log.query", func(ctx context.Context) (schema.LogEntries, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedStreamingLogProvider) QueryStream(ctx context.Context, query schema.LogQuery, send func(schema.LogEntries) error) error {
+	return observeErr(ctx, p.providerObserver, "log.query", func(ctx context.Context) error {
+		return p.streamer.QueryStream(ctx, query, send)
+	})
+}
+
+// Metric -----------------------------------------------------------------------
+
+type observedMetricProvider struct {
+	metric.Provider
+	providerObserver
+}
+
+// observedStreamingMetricProvider keeps the streaming interface visible to the metric handler.
+type observedStreamingMetricProvider struct {
+	observedMetricProvider
+	streamer metric.StreamingProvider
+}
+
+func observeMetric(name string, p metric.Provider) metric.Provider {
+	observed := observedMetricProvider{p, newProviderObserver("metric", name, p)}
+	if streamer, ok := p.(metric.StreamingProvider); ok {
+		return observedStreamingMetricProvider{observed, streamer}
+	}
+	return observed
+}
+
+func (p observedMetricProvider) Query(ctx context.Context, query schema.MetricQuery) ([]schema.MetricSeries, error) {
+	return observeCall(ctx, p.providerObserver, "metric.query", func(ctx context.Context) ([]schema.MetricSeries, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedMetricProvider) Describe(ctx context.Context, scope schema.QueryScope) ([]schema.MetricDescriptor, error) {
+	return observeCall(ctx, p.providerObserver, "metric.describe", func(ctx context.Context) ([]schema.MetricDescriptor, error) {
+		return p.Provider.Describe(ctx, scope)
+	})
+}
+
+func (p observedStreamingMetricProvider) QueryStream(ctx context.Context, query schema.MetricQuery, send func([]schema.MetricSeries) error) error {
+	return observeErr(ctx, p.providerObserver, "metric.query", func(ctx context.Context) error {
+		return p.streamer.QueryStream(ctx, query, send)
+	})
+}
+
+// Ticket -----------------------------------------------------------------------
+
+type observedTicketProvider struct {
+	ticket.Provider
+	providerObserver
+}
+
+func observeTicket(name string, p ticket.Provider) ticket.Provider {
+	return observedTicketProvider{p, newProviderObserver("ticket", name, p)}
+}
+
+func (p observedTicketProvider) Query(ctx context.Context, query schema.TicketQuery) ([]schema.Ticket, error) {
+	return observeCall(ctx, p.providerObserver, "ticket.query", func(ctx context.Context) ([]schema.Ticket, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedTicketProvider) Get(ctx context.Context, id string) (schema.Ticket, error) {
+	return observeCall(ctx, p.providerObserver, "ticket.get", func(ctx context.Context) (schema.Ticket, error) {
+		return p.Provider.Get(ctx, id)
+	})
+}
+
+func (p observedTicketProvider) Create(ctx context.Context, in schema.CreateTicketInput) (schema.Ticket, error) {
+	return observeCall(ctx, p.providerObserver, "ticket.create", func(ctx context.Context) (schema.Ticket, error) {
+		return p.Provider.Create(ctx, in)
+	})
+}
+
+func (p observedTicketProvider) Update(ctx context.Context, id string, in schema.UpdateTicketInput) (schema.Ticket, error) {
+	return observeCall(ctx, p.providerObserver, "ticket.update", func(ctx context.Context) (schema.Ticket, error) {
+		return p.Provider.Update(ctx, id, in)
+	})
+}
+
+// Messaging --------------------------------------------------------------------
+
+type observedMessagingProvider struct {
+	messaging.Provider
+	providerObserver
+}
+
+func observeMessaging(name string, p messaging.Provider) messaging.Provider {
+	return observedMessagingProvider{p, newProviderObserver("messaging", name, p)}
+}
+
+func (p observedMessagingProvider) Send(ctx context.Context, message schema.Message) (schema.MessageResult, error) {
+	return observeCall(ctx, p.providerObserver, "messaging.send", func(ctx context.Context) (schema.MessageResult, error) {
+		return p.Provider.Send(ctx, message)
+	})
+}
+
+// Service ----------------------------------------------------------------------
+
+type observedServiceProvider struct {
+	service.Provider
+	providerObserver
+}
+
+func observeService(name string, p service.Provider) service.Provider {
+	return observedServiceProvider{p, newProviderObserver("service", name, p)}
+}
+
+func (p observedServiceProvider) Query(ctx context.Context, query schema.ServiceQuery) ([]schema.Service, error) {
+	return observeCall(ctx, p.providerObserver, "service.query", func(ctx context.Context) ([]schema.Service, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+// Deployment -------------------------------------------------------------------
+
+type observedDeploymentProvider struct {
+	deployment.Provider
+	providerObserver
+}
+
+func observeDeployment(name string, p deployment.Provider) deployment.Provider {
+	return observedDeploymentProvider{p, newProviderObserver("deployment", name, p)}
+}
+
+func (p observedDeploymentProvider) Query(ctx context.Context, query schema.DeploymentQuery) ([]schema.Deployment, error) {
+	return observeCall(ctx, p.providerObserver, "deployment.query", func(ctx context.Context) ([]schema.Deployment, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedDeploymentProvider) Get(ctx context.Context, id string) (schema.Deployment, error) {
+	return observeCall(ctx, p.providerObserver, "deployment.get", func(ctx context.Context) (schema.Deployment, error) {
+		return p.Provider.Get(ctx, id)
+	})
+}
+
+// Team -------------------------------------------------------------------------
+
+type observedTeamProvider struct {
+	team.Provider
+	providerObserver
+}
+
+func observeTeam(name string, p team.Provider) team.Provider {
+	return observedTeamProvider{p, newProviderObserver("team", name, p)}
+}
+
+func (p observedTeamProvider) Query(ctx context.Context, query schema.TeamQuery) ([]schema.Team, error) {
+	return observeCall(ctx, p.providerObserver, "team.query", func(ctx context.Context) ([]schema.Team, error) {
+		return p.Provider.Query(ctx, query)
+	})
+}
+
+func (p observedTeamProvider) Get(ctx context.Context, id string) (schema.Team, error) {
+	return observeCall(ctx, p.providerObserver, "team.get", func(ctx context.Context) (schema.Team, error) {
+		return p.Provider.Get(ctx, id)
+	})
+}
+
+func (p observedTeamProvider) Members(ctx context.Context, teamID string) ([]schema.TeamMember, error) {
+	return observeCall(ctx, p.providerObserver, "team.members", func(ctx context.Context) ([]schema.TeamMember, error) {
+		return p.Provider.Members(ctx, teamID)
+	})
+}
+
+// Orchestration ----------------------------------------------------------------
+
+type observedOrchestrationProvider struct {
+	orchestration.Provider
+	providerObserver
+}
+
+func observeOrchestration(name string, p orchestration.Provider) orchestration.Provider {
+	return observedOrchestrationProvider{p, newProviderObserver("orchestration", name, p)}
+}
+
+func (p observedOrchestrationProvider) QueryPlans(ctx context.Context, query schema.OrchestrationPlanQuery) ([]schema.OrchestrationPlan, error) {
+	return observeCall(ctx, p.providerObserver, "orchestration.plans.query", func(ctx context.Context) ([]schema.OrchestrationPlan, error) {
+		return p.Provider.QueryPlans(ctx, query)
+	})
+}
+
+func (p observedOrchestrationProvider) GetPlan(ctx context.Context, planID string) (*schema.OrchestrationPlan, error) {
+	return observeCall(ctx, p.providerObserver, "orchestration.plans.get", func(ctx context.Context) (*schema.OrchestrationPlan, error) {
+		return p.Provider.GetPlan(ctx, planID)
+	})
+}
+
+func (p observedOrchestrationProvider) QueryRuns(ctx context.Context, query schema.OrchestrationRunQuery) ([]schema.OrchestrationRun, error) {
+	return observeCall(ctx, p.providerObserver, "orchestration.runs.query", func(ctx context.Context) ([]schema.OrchestrationRun, error) {
+		return p.Provider.QueryRuns(ctx, query)
+	})
+}
+
+func (p observedOrchestrationProvider) GetRun(ctx context.Context, runID string) (*schema.OrchestrationRun, error) {
+	return observeCall(ctx, p.providerObserver, "orchestration.runs.get", func(ctx context.Context) (*schema.OrchestrationRun, error) {
+		return p.Provider.GetRun(ctx, runID)
+	})
+}
+
+func (p observedOrchestrationProvider) StartRun(ctx context.Context, planID string) (*schema.OrchestrationRun, error) {
+	return observeCall(ctx, p.providerObserver, "orchestration.runs.start", func(ctx context.Context) (*schema.OrchestrationRun, error) {
+		return p.Provider.StartRun(ctx, planID)
+	})
+}
+
+func (p observedOrchestrationProvider) CompleteStep(ctx context.Context, runID string, stepID string, actor string, note string) error {
+	return observeErr(ctx, p.providerObserver, "orchestration.runs.steps.complete", func(ctx context.Context) error {
+		return p.Provider.CompleteStep(ctx, runID, stepID, actor, note)
+	})
+}
@@ -20,15 +20,11 @@ import (
 	"github.com/opsorch/opsorch-core/ticket"
 )
 
-func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/providers/") || r.Method != http.MethodGet {
-		return false
-	}
-	raw := strings.TrimPrefix(r.URL.Path, "/providers/")
-	capability, ok := normalizeCapability(raw)
+func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) {
+	capability, ok := normalizeCapability(r.PathValue("capability"))
 	if !ok {
 		writeError(w, http.StatusNotFound, orcherr.OpsOrchError{Code: "not_found", Message: "unknown capability"})
-		return true
+		return
 	}
 
 	var providers []string
@@ -55,7 +51,6 @@ func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) bool {
 		providers = orchestration.Providers()
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"providers": providers})
-	return true
 }
 
 func decodeConfig(envVar string) (map[string]any, error) {
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// rateLimitIdle is how long a bucket may go unused before it is dropped. Any bucket idle that
+// long has refilled, so dropping it changes no decision for rates above one per hour.
+const rateLimitIdle = time.Hour
+
+// defaultMaxRateBuckets bounds the buckets a limiter keeps, so callers cycling through
+// identities or addresses cannot grow its memory without limit.
+const defaultMaxRateBuckets = 100_000
+
+// rateLimiter applies token-bucket limits to capability routes. Each caller gets a bucket per
+// action, sized by the first per-actor rule that matches the request. The first per-capability
+// rule that matches adds a bucket shared by every caller, and a request needs a token from both.
+type rateLimiter struct {
+	rules      []rateLimitRule
+	now        func() time.Time
+	maxBuckets int // the bucket idle longest is dropped to make room beyond this
+
+	mu      sync.Mutex
+	buckets map[rateBucketKey]*rateBucket
+	recent  *list.List // every bucket, most recently used first, so the idlest is found in O(1)
+}
+
+// rateLimitRule sets the limit for the requests it matches. Empty conditions match anything,
+// and patterns may use path.Match wildcards.
+type rateLimitRule struct {
+	Capabilities []string `json:"capabilities,omitempty"`
+	Actions      []string `json:"actions,omitempty"`
+	ActorTypes   []string `json:"actorTypes,omitempty"`
+	// Per is "actor" (the default) for a bucket per caller and action, or "capability" for one
+	// bucket per capability shared by every request the rule matches.
+	Per string `json:"per,omitempty"`
+	// Rate is the sustained rate, such as "10/s", "30/m", or "100/h".
+	Rate string `json:"rate"`
+	// Burst is the bucket size: how many requests may arrive at once. It defaults to one
+	// second's worth of Rate, and at least 1.
+	Burst int `json:"burst,omitempty"`
+
+	perSecond float64
+}
+
+// rateBucketKey identifies a bucket: an actor's bucket for an action, or the shared bucket of a
+// per-capability rule, which has no actor and is keyed by the rule's index instead.
+type rateBucketKey struct {
+	actorType, actorID, action string
+	capability                 string
+	rule                       int
+}
+
+type rateBucket struct {
+	key    rateBucketKey
+	rule   *rateLimitRule
+	tokens float64
+	last   time.Time
+	elem   *list.Element // the bucket's place in rateLimiter.recent
+}
+
+// newRateLimiterFromEnv reads OPSORCH_RATE_LIMITS, a JSON array of rules evaluated in order. It
+// returns nil when the variable is unset.
+func newRateLimiterFromEnv() (*rateLimiter, error) {
+	raw := strings.TrimSpace(os.Getenv("OPSORCH_RATE_LIMITS"))
+	if raw == "" {
+		return nil, nil
+	}
+	rules, err := parseRateLimitRules([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPSORCH_RATE_LIMITS: %w", err)
+	}
+	return newRateLimiter(rules), nil
+}
+
+func newRateLimiter(rules []rateLimitRule) *rateLimiter {
+	return &rateLimiter{rules: rules, now: time.Now, maxBuckets: defaultMaxRateBuckets, buckets: make(map[rateBucketKey]*rateBucket), recent: list.New()}
+}
+
+func parseRateLimitRules(raw []byte) ([]rateLimitRule, error) {
+	var rules []rateLimitRule
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		rule := &rules[i]
+		perSecond, err := parseRate(rule.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rule.perSecond = perSecond
+		if rule.Burst < 0 {
+			return nil, fmt.Errorf("rule %d: burst must not be negative", i)
+		}
+		if rule.Burst == 0 {
+			rule.Burst = max(1, int(math.Ceil(perSecond)))
+		}
+		switch rule.Per {
+		case "":
+			rule.Per = "actor"
+		case "actor", "capability":
+		default:
+			return nil, fmt.Errorf("rule %d: per must be actor or capability, got %q", i, rule.Per)
+		}
+	}
+	return rules, nil
+}
+
+// parseRate converts "N/s", "N/m", or "N/h" to requests per second.
+func parseRate(rate string) (float64, error) {
+	count, unit, ok := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("rate %q must look like 10/s, 30/m, or 100/h", rate)
+	}
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	default:
+		return 0, fmt.Errorf("rate %q must look like 10/s, 30/m, or 100/h", rate)
+	}
+}
+
+func (rule *rateLimitRule) matches(capability, action, actorType string) bool {
+	return matchAny(rule.Capabilities, capability) && matchAny(rule.Actions, action) && matchAny(rule.ActorTypes, actorType)
+}
+
+// allow takes a token from each bucket that applies to the request: the caller's bucket for
+// action and the capability's shared bucket. When one of them is empty it takes none and
+// returns false and how long until both have a token.
+func (l *rateLimiter) allow(capability, action, actorType, actorID string) (bool, time.Duration) {
+	var actorRule, capabilityRule *rateLimitRule
+	capabilityIndex := 0
+	for i := range l.rules {
+		rule := &l.rules[i]
+		if !rule.matches(capability, action, actorType) {
+			continue
+		}
+		if rule.Per == "capability" && capabilityRule == nil {
+			capabilityRule, capabilityIndex = rule, i
+		} else if rule.Per != "capability" && actorRule == nil {
+			actorRule = rule
+		}
+	}
+	if actorRule == nil && capabilityRule == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for e := l.recent.Back(); e != nil && now.Sub(e.Value.(*rateBucket).last) >= rateLimitIdle; e = l.recent.Back() {
+		l.drop(e.Value.(*rateBucket))
+	}
+
+	var buckets []*rateBucket
+	if actorRule != nil {
+		buckets = append(buckets, l.bucket(rateBucketKey{actorType: actorType, actorID: actorID, action: action}, actorRule, now))
+	}
+	if capabilityRule != nil {
+		buckets = append(buckets, l.bucket(rateBucketKey{capability: capability, rule: capabilityIndex}, capabilityRule, now))
+	}
+	limited, wait := false, time.Duration(0)
+	for _, b := range buckets {
+		if b.tokens < 1 {
+			limited = true
+			wait = max(wait, time.Duration((1-b.tokens)/b.rule.perSecond*float64(time.Second)))
+		}
+	}
+	if limited {
+		return false, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// bucket returns the bucket for key refilled to now, creating a full one when it is missing or
+// was sized by another rule. When the limiter is full, the bucket idle longest is dropped.
+func (l *rateLimiter) bucket(key rateBucketKey, rule *rateLimitRule, now time.Time) *rateBucket {
+	b := l.buckets[key]
+	if b != nil && b.rule == rule {
+		b.refill(now)
+		l.recent.MoveToFront(b.elem)
+		return b
+	}
+	if b != nil {
+		l.drop(b)
+	} else if len(l.buckets) >= l.maxBuckets {
+		l.drop(l.recent.Back().Value.(*rateBucket))
+	}
+	b = &rateBucket{key: key, rule: rule, tokens: float64(rule.Burst), last: now}
+	b.elem = l.recent.PushFront(b)
+	l.buckets[key] = b
+	return b
+}
+
+// drop forgets b. The caller holds l.mu.
+func (l *rateLimiter) drop(b *rateBucket) {
+	l.recent.Remove(b.elem)
+	delete(l.buckets, b.key)
+}
+
+// rateLimitActor returns the actor whose buckets a request draws from: the caller's credential
+// identity or, for a request without one, an anonymous actor keyed by the client's address.
+// Neither can be picked with request headers.
+func rateLimitActor(r *http.Request) (actorType, actorID string) {
+	if caller := principalFrom(r.Context()); caller != nil && caller.actorID != "" {
+		return caller.actorType, caller.actorID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return anonymousActor, host
+}
+
+func (b *rateBucket) refill(now time.Time) {
+	b.tokens = b.tokensAt(now)
+	b.last = now
+}
+
+// tokensAt returns the tokens b will hold at now.
+func (b *rateBucket) tokensAt(now time.Time) float64 {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		return math.Min(float64(b.rule.Burst), b.tokens+elapsed*b.rule.perSecond)
+	}
+	return b.tokens
+}
+
+// rateLimitStatus is the body of GET /admin/rate-limits.
+type rateLimitStatus struct {
+	Rules   []rateLimitRule   `json:"rules"`
+	Buckets []rateBucketState `json:"buckets"`
+}
+
+type rateBucketState struct {
+	ActorType  string  `json:"actorType,omitempty"`
+	ActorID    string  `json:"actorId,omitempty"`
+	Action     string  `json:"action,omitempty"`
+	Capability string  `json:"capability,omitempty"` // set on the shared bucket of a per-capability rule
+	Rate       string  `json:"rate"`
+	Burst      int     `json:"burst"`
+	Tokens     float64 `json:"tokens"` // requests that may be made right now
+}
+
+// status reports the configured rules and every active bucket, refilled to the current time.
+// Reading a bucket does not count as using it, so it keeps its place in the idle order.
+func (l *rateLimiter) status() rateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	out := rateLimitStatus{Rules: l.rules, Buckets: []rateBucketState{}}
+	for key, b := range l.buckets {
+		out.Buckets = append(out.Buckets, rateBucketState{
+			ActorType:  key.actorType,
+			ActorID:    key.actorID,
+			Action:     key.action,
+			Capability: key.capability,
+			Rate:       b.rule.Rate,
+			Burst:      b.rule.Burst,
+			Tokens:     math.Floor(b.tokensAt(now)*100) / 100,
+		})
+	}
+	slices.SortFunc(out.Buckets, func(a, b rateBucketState) int {
+		return strings.Compare(a.Capability+"\x00"+a.ActorType+"\x00"+a.ActorID+"\x00"+a.Action, b.Capability+"\x00"+b.ActorType+"\x00"+b.ActorID+"\x00"+b.Action)
+	})
+	return out
+}
+
+func (s *Server) handleRateLimits(w http.ResponseWriter, r *http.Request) {
+	if s.limiter == nil {
+		writeJSON(w, http.StatusOK, rateLimitStatus{Rules: []rateLimitRule{}, Buckets: []rateBucketState{}})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.limiter.status())
+}
+
+// writeRateLimited answers a request whose bucket is empty.
+func writeRateLimited(w http.ResponseWriter, action string, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, orcherr.OpsOrchError{Code: "rate_limited", Message: fmt.Sprintf("rate limit exceeded for %s; retry in %s", action, wait.Round(time.Millisecond))})
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func rateLimitedServer(t *testing.T, rules string) (*Server, *time.Time) {
+	t.Helper()
+	t.Setenv("OPSORCH_RATE_LIMITS", rules)
+	limiter, err := newRateLimiterFromEnv()
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return &Server{limiter: limiter, log: LogHandler{provider: stubLogProvider{}}, incident: IncidentHandler{provider: stubIncidentProvider{}}}, &now
+}
+
+func queryLogsAs(srv *Server, actorType, actorID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`))
+	req = req.WithContext(withPrincipal(req.Context(), &principal{name: actorID, actorType: actorType, actorID: actorID, scopes: []string{"*"}}))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitPerActorAndActorType(t *testing.T) {
+	srv, now := rateLimitedServer(t, `[
+		{"capabilities":["log"],"actorTypes":["copilot"],"rate":"1/m","burst":2},
+		{"actions":["log.*"],"rate":"10/s"}
+	]`)
+
+	for i := 0; i < 2; i++ {
+		if w := queryLogsAs(srv, "copilot", "loop"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := queryLogsAs(srv, "copilot", "loop")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected 429 with Retry-After 60, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != "rate_limited" {
+		t.Fatalf("expected a rate_limited error, got %s", w.Body.String())
+	}
+
+	if w := queryLogsAs(srv, "copilot", "other"); w.Code != http.StatusOK {
+		t.Fatalf("expected another copilot to have its own bucket, got %d", w.Code)
+	}
+	for i := 0; i < 10; i++ {
+		if w := queryLogsAs(srv, "user", "alice"); w.Code != http.StatusOK {
+			t.Fatalf("expected users to get the higher limit, got %d on request %d", w.Code, i)
+		}
+	}
+	if w := queryLogsAs(srv, "user", "alice"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected the user limit to apply, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	*now = now.Add(30 * time.Second)
+	if w := queryLogsAs(srv, "copilot", "loop"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("expected half a refill to still be limited, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	*now = now.Add(30 * time.Second)
+	if w := queryLogsAs(srv, "copilot", "loop"); w.Code != http.StatusOK {
+		t.Fatalf("expected a refilled token, got %d", w.Code)
+	}
+}
+
+func TestRateLimitPerCapabilityBucketIsShared(t *testing.T) {
+	srv, now := rateLimitedServer(t, `[
+		{"capabilities":["log"],"per":"capability","rate":"3/m","burst":3},
+		{"capabilities":["log"],"rate":"2/m","burst":2}
+	]`)
+	for _, actor := range []string{"alice", "alice", "bob"} {
+		if w := queryLogsAs(srv, "user", actor); w.Code != http.StatusOK {
+			t.Fatalf("expected %s to be allowed, got %d", actor, w.Code)
+		}
+	}
+	// Alice's own bucket is empty; her refused request must not drain the shared one.
+	if w := queryLogsAs(srv, "user", "alice"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected alice's own limit to apply, got %d", w.Code)
+	}
+	if w := queryLogsAs(srv, "user", "carol"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "20" {
+		t.Fatalf("expected the shared log bucket to be empty, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	*now = now.Add(20 * time.Second)
+	if w := queryLogsAs(srv, "user", "carol"); w.Code != http.StatusOK {
+		t.Fatalf("expected a refilled shared token, got %d", w.Code)
+	}
+
+	status := srv.limiter.status()
+	if len(status.Buckets) != 4 || status.Buckets[0].Capability != "" || status.Buckets[3].Capability != "log" || status.Buckets[3].ActorID != "" {
+		t.Fatalf("expected three actor buckets and one shared bucket, got %+v", status.Buckets)
+	}
+}
+
+func TestRateLimitKeysAnonymousCallersByAddress(t *testing.T) {
+	srv, _ := rateLimitedServer(t, `[{"actorTypes":["anonymous"],"rate":"1/m"}]`)
+	query := func(remoteAddr, userID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-User-Id", userID)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := query("192.0.2.1:4000", "a"); code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", code)
+	}
+	// A new X-User-Id does not buy a new bucket; a new address does.
+	if code := query("192.0.2.1:4001", "b"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the same address to share a bucket, got %d", code)
+	}
+	if code := query("192.0.2.2:4000", "a"); code != http.StatusOK {
+		t.Fatalf("expected another address to get its own bucket, got %d", code)
+	}
+}
+
+func TestRateLimitBoundsBuckets(t *testing.T) {
+	srv, now := rateLimitedServer(t, `[{"capabilities":["log"],"rate":"1/m"}]`)
+	srv.limiter.maxBuckets = 2
+	queryLogsAs(srv, "user", "alice")
+	*now = now.Add(time.Second)
+	queryLogsAs(srv, "user", "bob")
+	*now = now.Add(time.Second)
+	queryLogsAs(srv, "user", "carol")
+
+	status := srv.limiter.status()
+	if len(status.Buckets) != 2 || status.Buckets[0].ActorID != "bob" || status.Buckets[1].ActorID != "carol" {
+		t.Fatalf("expected the bucket idle longest to be dropped, got %+v", status.Buckets)
+	}
+}
+
+func TestRateLimitEvictsLeastRecentlyUsed(t *testing.T) {
+	srv, now := rateLimitedServer(t, `[{"capabilities":["log"],"rate":"10/m","burst":5}]`)
+	srv.limiter.maxBuckets = 2
+	// Every request lands at the same instant, so only the order of use picks the bucket to drop.
+	queryLogsAs(srv, "user", "alice")
+	queryLogsAs(srv, "user", "bob")
+	queryLogsAs(srv, "user", "alice")
+	queryLogsAs(srv, "user", "carol")
+
+	status := srv.limiter.status()
+	if len(status.Buckets) != 2 || status.Buckets[0].ActorID != "alice" || status.Buckets[0].Tokens != 3 || status.Buckets[1].ActorID != "carol" {
+		t.Fatalf("expected bob's bucket to be dropped, got %+v", status.Buckets)
+	}
+
+	*now = now.Add(rateLimitIdle)
+	queryLogsAs(srv, "user", "dave")
+	if status := srv.limiter.status(); len(status.Buckets) != 1 || status.Buckets[0].ActorID != "dave" {
+		t.Fatalf("expected idle buckets to be swept, got %+v", status.Buckets)
+	}
+}
+
+// countingIncidents counts the incident lookups that reach the provider.
+type countingIncidents struct {
+	servicedIncidents
+	gets *int
+}
+
+func (p countingIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	*p.gets++
+	return p.servicedIncidents.Get(ctx, id)
+}
+
+func TestRateLimitRunsBeforePolicyLookups(t *testing.T) {
+	srv, _ := rateLimitedServer(t, `[{"capabilities":["incident"],"rate":"1/m"}]`)
+	srv.policy = policyServer(t, testPolicies).policy
+	var gets int
+	srv.incident = IncidentHandler{provider: countingIncidents{servicedIncidents{services: map[string]string{"INC-1": "checkout"}}, &gets}}
+	caller := &principal{name: "alice", actorID: "alice", actorType: "user", scopes: []string{"*"}}
+	if w := servePolicy(srv, http.MethodGet, "/incidents/INC-1", "", caller); w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", w.Code)
+	}
+	before := gets
+	if w := servePolicy(srv, http.MethodGet, "/incidents/INC-1", "", caller); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if gets != before {
+		t.Fatalf("expected a throttled request not to reach the provider, got %d more lookups", gets-before)
+	}
+}
+
+func TestRateLimitSkipsUnmatchedRoutes(t *testing.T) {
+	srv, _ := rateLimitedServer(t, `[{"capabilities":["log"],"rate":"1/h"}]`)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/incidents/INC-1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected incidents to be unlimited, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitAdminEndpoint(t *testing.T) {
+	srv, _ := rateLimitedServer(t, `[{"capabilities":["log"],"rate":"30/m","burst":5}]`)
+	queryLogsAs(srv, "copilot", "loop")
+	queryLogsAs(srv, "copilot", "loop")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/rate-limits", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var status rateLimitStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := rateBucketState{ActorType: "copilot", ActorID: "loop", Action: "log.query", Rate: "30/m", Burst: 5, Tokens: 3}
+	if len(status.Rules) != 1 || len(status.Buckets) != 1 || status.Buckets[0] != want {
+		t.Fatalf("unexpected limiter state %+v", status)
+	}
+
+	srv = &Server{tokens: testTokenStore(t)}
+	if w := serveWithToken(srv, http.MethodGet, "/admin/rate-limits", "dash-secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected admin:read to be required, got %d", w.Code)
+	}
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := parseRateLimitRules([]byte(`[{"rate":"90/m"},{"rate":"2.5/s"}]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if rules[0].perSecond != 1.5 || rules[0].Burst != 2 || rules[1].Burst != 3 {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	for _, raw := range []string{`[{"rate":"10"}]`, `[{"rate":"10/d"}]`, `[{"rate":"-1/s"}]`, `[{"rate":"1/s","burst":-1}]`, `[{"rate":"1/s","capability":"log"}]`, `[{"rate":"1/s","per":"team"}]`} {
+		if _, err := parseRateLimitRules([]byte(raw)); err == nil {
+			t.Fatalf("%s: expected an error", raw)
+		}
+	}
+}
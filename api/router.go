@@ -0,0 +1,267 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// Route describes one endpoint of the HTTP API.
+type Route struct {
+	Method string
+	// Pattern is the path with {name} placeholders for path parameters, e.g. /incidents/{id}/timeline.
+	// Each placeholder matches exactly one segment; a "/" inside a parameter must be sent as %2F.
+	Pattern string
+	// Capability is the capability key the route belongs to, or "" for server endpoints.
+	Capability string
+	// Action is the audit action recorded when the request succeeds, or "" when none is.
+	Action string
+	// Scope is the token scope the caller needs, such as incident:read, or "" when any
+	// authenticated caller may use the route.
+	Scope string
+}
+
+// route binds a Route to the method that serves it. request and response are zero values of
+// the JSON bodies and describe the route in the OpenAPI document; request is nil when the
+// route takes no body, and on GET its fields are read from the query string instead.
+type route struct {
+	Route
+	handle   func(*Server, http.ResponseWriter, *http.Request)
+	request  any
+	response any
+	status   int // status written on success
+}
+
+// apiRoutes is the route table of the HTTP API. Handlers read path parameters with r.PathValue.
+func apiRoutes() []route {
+	return []route{
+		{Route{http.MethodGet, "/", "", "", ""}, (*Server).handleRoot, nil, statusResponse{}, http.StatusOK},
+		{Route{http.MethodGet, "/health", "", "", ""}, (*Server).handleHealth, nil, statusResponse{}, http.StatusOK},
+		{Route{http.MethodGet, "/ready", "", "", ""}, (*Server).handleReady, nil, readyResponse{}, http.StatusOK},
+		{Route{http.MethodGet, "/health/providers", "", "", "providers:read"}, (*Server).handleProviderHealth, nil, providerHealthReport{}, http.StatusOK},
+		{Route{http.MethodGet, "/openapi.json", "", "", ""}, (*Server).handleOpenAPI, nil, map[string]any{}, http.StatusOK},
+		{Route{http.MethodGet, "/providers/{capability}", "", "", "providers:read"}, (*Server).handleProviders, nil, providerListResponse{}, http.StatusOK},
+		{Route{http.MethodPost, "/providers/{capability}", "", "provider.configured", "providers:admin"}, (*Server).handleProviderConfig, providerConfigRequest{}, statusResponse{}, http.StatusOK},
+		{Route{http.MethodGet, "/admin/rate-limits", "", "", "admin:read"}, (*Server).handleRateLimits, nil, rateLimitStatus{}, http.StatusOK},
+		{Route{http.MethodGet, "/admin/metrics", "", "", "admin:read"}, (*Server).handleSelfMetrics, nil, prometheusText(""), http.StatusOK},
+		{Route{http.MethodGet, "/admin/log-level", "", "", "admin:read"}, (*Server).handleLogLevel, nil, logLevelResponse{}, http.StatusOK},
+		{Route{http.MethodPost, "/admin/log-level", "", "admin.log_level_changed", "admin:write"}, (*Server).handleSetLogLevel, logLevelRequest{}, logLevelResponse{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/incidents/query", "incident", "incident.query", "incident:read"}, (*Server).queryIncidents, schema.IncidentQuery{}, []schema.Incident{}, http.StatusOK},
+		{Route{http.MethodPost, "/incidents", "incident", "incident.created", "incident:write"}, (*Server).createIncident, schema.CreateIncidentInput{}, schema.Incident{}, http.StatusCreated},
+		{Route{http.MethodGet, "/incidents/{id}", "incident", "incident.get", "incident:read"}, (*Server).getIncident, nil, schema.Incident{}, http.StatusOK},
+		{Route{http.MethodPatch, "/incidents/{id}", "incident", "incident.updated", "incident:write"}, (*Server).updateIncident, schema.UpdateIncidentInput{}, schema.Incident{}, http.StatusOK},
+		{Route{http.MethodGet, "/incidents/{id}/timeline", "incident", "incident.timeline.get", "incident:read"}, (*Server).getIncidentTimeline, nil, []schema.TimelineEntry{}, http.StatusOK},
+		{Route{http.MethodPost, "/incidents/{id}/timeline", "incident", "incident.timeline.appended", "incident:write"}, (*Server).appendIncidentTimeline, schema.TimelineAppendInput{}, statusResponse{}, http.StatusCreated},
+
+		{Route{http.MethodPost, "/alerts/query", "alert", "alert.query", "alert:read"}, (*Server).queryAlerts, schema.AlertQuery{}, []schema.Alert{}, http.StatusOK},
+		{Route{http.MethodGet, "/alerts/{id}", "alert", "alert.get", "alert:read"}, (*Server).getAlert, nil, schema.Alert{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/logs/query", "log", "log.query", "log:read"}, (*Server).queryLogs, schema.LogQuery{}, schema.LogEntries{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/metrics/query", "metric", "metric.query", "metric:read"}, (*Server).queryMetrics, schema.MetricQuery{}, []schema.MetricSeries{}, http.StatusOK},
+		{Route{http.MethodGet, "/metrics/describe", "metric", "metric.describe", "metric:read"}, (*Server).describeMetrics, schema.QueryScope{}, metricDescribeResponse{}, http.StatusOK},
+		{Route{http.MethodPost, "/metrics/describe", "metric", "metric.describe", "metric:read"}, (*Server).describeMetrics, schema.QueryScope{}, metricDescribeResponse{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/tickets/query", "ticket", "ticket.query", "ticket:read"}, (*Server).queryTickets, schema.TicketQuery{}, []schema.Ticket{}, http.StatusOK},
+		{Route{http.MethodPost, "/tickets", "ticket", "ticket.created", "ticket:write"}, (*Server).createTicket, schema.CreateTicketInput{}, schema.Ticket{}, http.StatusCreated},
+		{Route{http.MethodGet, "/tickets/{id}", "ticket", "ticket.get", "ticket:read"}, (*Server).getTicket, nil, schema.Ticket{}, http.StatusOK},
+		{Route{http.MethodPatch, "/tickets/{id}", "ticket", "ticket.updated", "ticket:write"}, (*Server).updateTicket, schema.UpdateTicketInput{}, schema.Ticket{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/messages/send", "messaging", "message.sent", "messaging:write"}, (*Server).sendMessage, schema.Message{}, schema.MessageResult{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/services/query", "service", "service.query", "service:read"}, (*Server).queryServices, schema.ServiceQuery{}, []schema.Service{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/deployments/query", "deployment", "deployment.query", "deployment:read"}, (*Server).queryDeployments, schema.DeploymentQuery{}, []schema.Deployment{}, http.StatusOK},
+		{Route{http.MethodGet, "/deployments/{id}", "deployment", "deployment.get", "deployment:read"}, (*Server).getDeployment, nil, schema.Deployment{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/teams/query", "team", "team.query", "team:read"}, (*Server).queryTeams, schema.TeamQuery{}, []schema.Team{}, http.StatusOK},
+		{Route{http.MethodGet, "/teams/{id}", "team", "team.get", "team:read"}, (*Server).getTeam, nil, schema.Team{}, http.StatusOK},
+		{Route{http.MethodGet, "/teams/{id}/members", "team", "team.members", "team:read"}, (*Server).getTeamMembers, nil, []schema.TeamMember{}, http.StatusOK},
+
+		{Route{http.MethodPost, "/orchestration/plans/query", "orchestration", "orchestration.plans.query", "orchestration:read"}, (*Server).queryOrchestrationPlans, schema.OrchestrationPlanQuery{}, []schema.OrchestrationPlan{}, http.StatusOK},
+		{Route{http.MethodGet, "/orchestration/plans/{planId}", "orchestration", "orchestration.plans.get", "orchestration:read"}, (*Server).getOrchestrationPlan, nil, schema.OrchestrationPlan{}, http.StatusOK},
+		{Route{http.MethodPost, "/orchestration/runs/query", "orchestration", "orchestration.runs.query", "orchestration:read"}, (*Server).queryOrchestrationRuns, schema.OrchestrationRunQuery{}, []schema.OrchestrationRun{}, http.StatusOK},
+		{Route{http.MethodPost, "/orchestration/runs", "orchestration", "orchestration.runs.start", "orchestration:write"}, (*Server).startOrchestrationRun, startRunRequest{}, schema.OrchestrationRun{}, http.StatusCreated},
+		{Route{http.MethodGet, "/orchestration/runs/{runId}", "orchestration", "orchestration.runs.get", "orchestration:read"}, (*Server).getOrchestrationRun, nil, schema.OrchestrationRun{}, http.StatusOK},
+		{Route{http.MethodPost, "/orchestration/runs/{runId}/steps/{stepId}/complete", "orchestration", "orchestration.runs.steps.complete", "orchestration:write"}, (*Server).completeOrchestrationStep, completeStepRequest{}, statusResponse{}, http.StatusOK},
+	}
+}
+
+// Routes lists every endpoint of the HTTP API in route table order.
+func Routes() []Route {
+	table := apiRoutes()
+	out := make([]Route, len(table))
+	for i, rt := range table {
+		out[i] = rt.Route
+	}
+	return out
+}
+
+// router matches requests against the route table. A literal segment wins over a parameter
+// in the same position, so /incidents/query is never read as an incident ID.
+type router struct {
+	routes []compiledRoute
+	// prefixes maps the first path segment of capability routes to the capability, so every
+	// path under an unconfigured capability answers 501 whether or not a route matches.
+	prefixes map[string]string
+}
+
+type compiledRoute struct {
+	route
+	segments []string // literal segments, or "{name}" for parameters
+}
+
+func newRouter(table []route) *router {
+	rt := &router{routes: make([]compiledRoute, len(table)), prefixes: make(map[string]string)}
+	for i, r := range table {
+		c := compiledRoute{route: r, segments: splitPath(r.Pattern)}
+		if r.Capability != "" {
+			rt.prefixes[c.segments[0]] = r.Capability
+		}
+		rt.routes[i] = c
+	}
+	return rt
+}
+
+// splitPath splits a path into its segments, ignoring one trailing slash.
+func splitPath(path string) []string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match matches the escaped path segments against c and returns the decoded parameters. ok is
+// false when the path does not fit c's pattern.
+func (c compiledRoute) match(segments []string) (params map[string]string, ok bool) {
+	if len(segments) != len(c.segments) {
+		return nil, false
+	}
+	for i, want := range c.segments {
+		if name, isParam := strings.CutPrefix(want, "{"); isParam {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[strings.TrimSuffix(name, "}")] = value
+			continue
+		}
+		if segments[i] != want {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// specificity ranks how many leading segments of c are literals, so literal routes sort first.
+func (c compiledRoute) specificity() int {
+	n := 0
+	for _, seg := range c.segments {
+		if strings.HasPrefix(seg, "{") {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// lookup finds the route for r. When the path matches but no route accepts the method, it
+// returns the allowed methods instead.
+func (rt *router) lookup(r *http.Request) (match *compiledRoute, params map[string]string, allowed []string) {
+	segments := splitPath(r.URL.EscapedPath())
+	for i := range rt.routes {
+		c := &rt.routes[i]
+		p, ok := c.match(segments)
+		if !ok {
+			continue
+		}
+		if c.Method != r.Method {
+			allowed = append(allowed, c.Method)
+			continue
+		}
+		if match == nil || c.specificity() > match.specificity() {
+			match, params = c, p
+		}
+	}
+	if match != nil {
+		return match, params, nil
+	}
+	slices.Sort(allowed)
+	return nil, nil, slices.Compact(allowed)
+}
+
+// dispatch serves r from the route table. It returns false when no route matches the path.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) bool {
+	s.routerOnce.Do(func() { s.router = newRouter(apiRoutes()) })
+	if segments := splitPath(r.URL.EscapedPath()); len(segments) > 0 {
+		if capability := s.router.prefixes[segments[0]]; capability != "" && s.capabilityProvider(capability) == nil {
+			if info := requestInfoFrom(r.Context()); info != nil {
+				info.capability = capability
+			}
+			if state, ok := s.initializing(capability); ok {
+				writeProviderInitializing(w, capability, state)
+				return true
+			}
+			writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: capability + "_provider_missing", Message: capability + " provider not configured"})
+			return true
+		}
+	}
+	match, params, allowed := s.router.lookup(r)
+	if match == nil {
+		if len(allowed) == 0 {
+			return false
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, orcherr.OpsOrchError{Code: "method_not_allowed", Message: r.Method + " not allowed on " + r.URL.Path})
+		return true
+	}
+	if info := requestInfoFrom(r.Context()); info != nil {
+		info.capability, info.action, info.route = match.Capability, match.Action, match.Pattern
+	}
+	if caller := principalFrom(r.Context()); !caller.allows(match.Scope) {
+		writeError(w, http.StatusForbidden, orcherr.OpsOrchError{Code: "forbidden", Message: fmt.Sprintf("credential %s lacks scope %s", caller.name, match.Scope)})
+		return true
+	}
+	// Throttled callers are turned away before the policy looks up the resource through the
+	// provider.
+	if s.limiter != nil && match.Capability != "" {
+		actorType, actorID := rateLimitActor(r)
+		if ok, wait := s.limiter.allow(match.Capability, match.Action, actorType, actorID); !ok {
+			writeRateLimited(w, match.Action, wait)
+			return true
+		}
+	}
+	if s.policy != nil && match.Action != "" {
+		in, err := s.policyInputFor(r, match, params)
+		if err != nil {
+			writeProviderError(w, err)
+			return true
+		}
+		if ok, rule := s.policy.decide(in); !ok {
+			details := map[string]string{"action": in.action, "rule": rule}
+			for key, value := range map[string]string{"service": in.scope.Service, "team": in.scope.Team, "environment": in.scope.Environment, "resource": in.resource} {
+				if value != "" {
+					details[key] = value
+				}
+			}
+			logAuditDetails(r, "policy.denied", details)
+			writeError(w, http.StatusForbidden, orcherr.OpsOrchError{Code: "forbidden", Message: fmt.Sprintf("%s denied by policy rule %s", in.action, rule)})
+			return true
+		}
+	}
+	for name, value := range params {
+		r.SetPathValue(name, value)
+	}
+	match.handle(s, w, r)
+	return true
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestRouterReturns405WithAllow(t *testing.T) {
+	srv := &Server{incident: IncidentHandler{provider: stubIncidentProvider{}}}
+	cases := []struct {
+		method, path, allow string
+	}{
+		{http.MethodPut, "/incidents/1", "GET, PATCH"},
+		{http.MethodDelete, "/incidents/1/timeline", "GET, POST"},
+		{http.MethodGet, "/incidents", "POST"},
+		{http.MethodPut, "/incidents/query", "GET, PATCH, POST"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s %s: expected 405, got %d", tc.method, tc.path, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != tc.allow {
+			t.Fatalf("%s %s: expected Allow %q, got %q", tc.method, tc.path, tc.allow, got)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["code"] != "method_not_allowed" {
+			t.Fatalf("%s %s: unexpected body %s", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
+
+func TestRouterDecodesEscapedPathParams(t *testing.T) {
+	srv := &Server{incident: IncidentHandler{provider: stubIncidentProvider{}}}
+	cases := map[string]string{
+		"/incidents/PROJ%2F123":          "PROJ/123",
+		"/incidents/with%20space":        "with space",
+		"/incidents/PROJ%2F123/timeline": "PROJ/123",
+		"/incidents/plain/":              "plain",
+	}
+	for path, want := range cases {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var got string
+		if strings.HasSuffix(path, "/timeline") {
+			var timeline []schema.TimelineEntry
+			_ = json.Unmarshal(w.Body.Bytes(), &timeline)
+			if len(timeline) == 1 {
+				got = timeline[0].IncidentID
+			}
+		} else {
+			var inc schema.Incident
+			_ = json.Unmarshal(w.Body.Bytes(), &inc)
+			got = inc.ID
+		}
+		if got != want {
+			t.Fatalf("GET %s: expected id %q, got %q", path, want, got)
+		}
+	}
+
+	// An unescaped slash adds a segment, so it no longer fits /incidents/{id}.
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/incidents/PROJ/123", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unescaped slash, got %d", w.Code)
+	}
+}
+
+func TestRouterPrefersLiteralSegments(t *testing.T) {
+	srv := &Server{incident: IncidentHandler{provider: stubIncidentProvider{}}}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/incidents/query", strings.NewReader(`{}`)))
+	var incidents []schema.Incident
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &incidents) != nil {
+		t.Fatalf("expected POST /incidents/query to reach the query route, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRoutesListsEveryEndpoint(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, rt := range Routes() {
+		key := rt.Method + " " + rt.Pattern
+		if seen[key] {
+			t.Fatalf("duplicate route %s", key)
+		}
+		seen[key] = true
+		if rt.Capability != "" {
+			if _, ok := normalizeCapability(rt.Capability); !ok {
+				t.Fatalf("route %s has unknown capability %q", key, rt.Capability)
+			}
+			if rt.Action == "" {
+				t.Fatalf("capability route %s has no action", key)
+			}
+		}
+	}
+	for _, key := range []string{"GET /health", "POST /incidents/query", "GET /metrics/describe", "POST /orchestration/runs/{runId}/steps/{stepId}/complete"} {
+		if !seen[key] {
+			t.Fatalf("expected route %s", key)
+		}
+	}
+}
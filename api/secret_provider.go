@@ -22,7 +22,8 @@ func newSecretProviderFromEnv() (SecretProvider, error) {
 		return nil, err
 	}
 	if pluginPath != "" {
-		return newSecretPluginProvider(pluginPath, cfg), nil
+		// The secret plugin starts before the server it would call back into, so it has no host.
+		return newSecretPluginProvider(pluginPath, cfg, nil), nil
 	}
 	if name == "" {
 		return nil, nil
@@ -1,20 +1,37 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
 )
 
 // Server routes requests to capability handlers.
 type Server struct {
 	corsOrigin    string
 	bearerToken   string
+	tokens        *tokenStore               // scoped API tokens; nil when OPSORCH_TOKENS_* is unset
+	oidc          *oidcVerifier             // JWT authentication; nil when OPSORCH_OIDC_* is unset
+	policy        *policyEngine             // authorization rules; nil when OPSORCH_POLICY_FILE is unset
+	limiter       *rateLimiter              // per-caller rate limits; nil when OPSORCH_RATE_LIMITS is unset
+	retries       map[string]*providerRetry // capabilities whose provider failed to construct and is being retried
+	retryInitial  time.Duration             // first provider retry delay; optional override for tests
+	tracer        *tracer                   // request and provider spans; nil when OPSORCH_TRACES_EXPORTER is unset
 	tlsCertFile   string
 	tlsKeyFile    string
+	tls           *tlsReloader                             // reloads the certificate and client CA bundle; nil without TLS
+	clientCerts   *clientCertAuth                          // maps client certificates to principals; nil without a client CA
 	serve         func(*http.Server) error                 // optional override for tests
 	serveTLS      func(*http.Server, string, string) error // optional override for tests
 	incident      IncidentHandler
@@ -28,10 +45,36 @@ type Server struct {
 	team          TeamHandler
 	orchestration OrchestrationHandler
 	secret        SecretProvider
+
+	// handlersMu guards the capability handlers above, which provider retries and
+	// POST /providers replace while requests are being served.
+	handlersMu sync.RWMutex
+
+	routerOnce sync.Once
+	router     *router
+
+	shutdownTimeout time.Duration // how long Run lets in-flight requests drain
+
+	lifecycleMu sync.Mutex
+	httpServer  *http.Server // the server started by ListenAndServe, if any
+	draining    atomic.Bool  // set once Shutdown starts; readiness fails from then on
 }
 
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	// pluginShutdownTimeout bounds how long plugins get to exit after requests have drained.
+	pluginShutdownTimeout = 5 * time.Second
+)
+
+// unauthenticatedPaths are served without a credential so load balancers and orchestrators
+// can probe the server. The readiness probe only says whether it is ready for traffic.
+var unauthenticatedPaths = map[string]bool{"/ready": true}
+
 // NewServerFromEnv constructs a Server with providers loaded from environment variables.
 func NewServerFromEnv(ctx context.Context) (*Server, error) {
+	if err := configureLoggingFromEnv(); err != nil {
+		return nil, err
+	}
 	corsOrigin := os.Getenv("OPSORCH_CORS_ORIGIN")
 	if corsOrigin == "" {
 		corsOrigin = "*"
@@ -44,81 +87,105 @@ func NewServerFromEnv(ctx context.Context) (*Server, error) {
 		return nil, fmt.Errorf("both OPSORCH_TLS_CERT_FILE and OPSORCH_TLS_KEY_FILE must be set together")
 	}
 
-	sec, err := newSecretProviderFromEnv()
+	tlsReloader, clientCerts, err := newTLSFromEnv(tlsCertFile, tlsKeyFile)
 	if err != nil {
 		return nil, err
 	}
 
-	inc, err := newIncidentHandlerFromEnv(sec)
-	if err != nil {
-		return nil, err
-	}
-	al, err := newAlertHandlerFromEnv(sec)
+	sec, err := newSecretProviderFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	lg, err := newLogHandlerFromEnv(sec)
+	tokens, err := newTokenStoreFromEnv(sec)
 	if err != nil {
 		return nil, err
 	}
-	mt, err := newMetricHandlerFromEnv(sec)
+	oidc, err := newOIDCVerifierFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	tk, err := newTicketHandlerFromEnv(sec)
+	policy, err := newPolicyEngineFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	msg, err := newMessagingHandlerFromEnv(sec)
+	limiter, err := newRateLimiterFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	svc, err := newServiceHandlerFromEnv(sec)
+	tracer, err := newTracerFromEnv()
 	if err != nil {
 		return nil, err
 	}
-	dep, err := newDeploymentHandlerFromEnv(sec)
-	if err != nil {
-		// Log the error but continue startup with deployment capability disabled
-		log.Printf("Failed to initialize deployment provider: %v", err)
-		dep = DeploymentHandler{} // Empty handler with nil provider
-	}
-	tm, err := newTeamHandlerFromEnv(sec)
-	if err != nil {
-		// Log the error but continue startup with team capability disabled
-		log.Printf("Failed to initialize team provider: %v", err)
-		tm = TeamHandler{} // Empty handler with nil provider
+
+	srv := &Server{
+		shutdownTimeout: envDuration("OPSORCH_SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		corsOrigin:      corsOrigin,
+		bearerToken:     bearer,
+		tokens:          tokens,
+		oidc:            oidc,
+		policy:          policy,
+		limiter:         limiter,
+		tracer:          tracer,
+		tlsCertFile:     tlsCertFile,
+		tlsKeyFile:      tlsKeyFile,
+		tls:             tlsReloader,
+		clientCerts:     clientCerts,
+		secret:          sec,
 	}
-	orch, err := newOrchestrationHandlerFromEnv(sec)
-	if err != nil {
-		// Log the error but continue startup with orchestration capability disabled
-		log.Printf("Failed to initialize orchestration provider: %v", err)
-		orch = OrchestrationHandler{} // Empty handler with nil provider
-	}
-
-	_ = ctx // reserved for future use
-
-	return &Server{
-		corsOrigin:    corsOrigin,
-		bearerToken:   bearer,
-		tlsCertFile:   tlsCertFile,
-		tlsKeyFile:    tlsKeyFile,
-		incident:      inc,
-		alert:         al,
-		log:           lg,
-		metric:        mt,
-		ticket:        tk,
-		messaging:     msg,
-		service:       svc,
-		deployment:    dep,
-		team:          tm,
-		orchestration: orch,
-		secret:        sec,
-	}, nil
+	// A capability whose provider fails to construct starts degraded and is retried in the
+	// background rather than failing startup.
+	srv.loadCapabilities()
+	return srv, nil
 }
 
-// ServeHTTP implements http.Handler and dispatches to capability handlers.
+// requestInfo describes a request and the route serving it. ServeHTTP and dispatch fill it in
+// so telemetry recorded after the handler returns can be labeled by capability and action, and
+// so log lines can carry the request ID, actor, and capability.
+type requestInfo struct {
+	capability string
+	action     string
+	route      string // the matched route pattern, such as /incidents/{id}
+	requestID  string
+	actorType  string
+	actorID    string
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// ServeHTTP implements http.Handler and dispatches through the route table.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	info := &requestInfo{}
+	ctx, span := s.tracer.startServerSpan(context.WithValue(r.Context(), requestInfoKey{}, info), r)
+	r = r.WithContext(ctx)
+	rec := &statusRecorder{ResponseWriter: w, ctx: ctx}
+	w = rec
+	defer func() {
+		code := cmp.Or(rec.status, http.StatusOK)
+		status := strconv.Itoa(code)
+		selfMetrics.requests.add(1, info.capability, info.action, status)
+		selfMetrics.requestDuration.observe(time.Since(start).Seconds(), info.capability, info.action, status)
+
+		if info.route != "" {
+			span.setName(r.Method + " " + info.route)
+		}
+		span.setAttr("http.request.method", r.Method)
+		span.setAttr("url.path", r.URL.Path)
+		span.setAttr("http.route", info.route)
+		span.setAttr("http.response.status_code", status)
+		span.setAttr("opsorch.capability", info.capability)
+		span.setAttr("opsorch.action", info.action)
+		if code >= http.StatusInternalServerError {
+			span.setError(http.StatusText(code))
+		}
+		span.finish()
+	}()
+
 	// CORS headers for frontend consumption.
 	w.Header().Set("Access-Control-Allow-Origin", s.corsOrigin)
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -129,58 +196,76 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.authorize(r) {
-		w.Header().Set("WWW-Authenticate", "Bearer")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	requestID := requestIDFromRequest(r)
+	info.requestID = requestIDFromRequest(r)
+	info.actorType, info.actorID = requestActor(r)
+	span.setAttr("opsorch.request_id", info.requestID)
 
 	// Set headers for downstream
-	w.Header().Set("X-Request-ID", requestID)
-
-	switch {
-	case r.URL.Path == "/" && r.Method == http.MethodGet:
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	case r.URL.Path == "/health" && r.Method == http.MethodGet:
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	case s.handleProviders(w, r):
-	case s.handleProviderConfig(w, r):
-	case s.handleIncident(w, r):
-	case s.handleAlert(w, r):
-	case s.handleLog(w, r):
-	case s.handleMetric(w, r):
-	case s.handleTicket(w, r):
-	case s.handleMessaging(w, r):
-	case s.handleService(w, r):
-	case s.handleDeployment(w, r):
-	case s.handleTeam(w, r):
-	case s.handleOrchestration(w, r):
-	default:
+	w.Header().Set("X-Request-ID", info.requestID)
+
+	if !unauthenticatedPaths[r.URL.Path] {
+		caller, err := s.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, orcherr.OpsOrchError{Code: "unauthorized", Message: err.Error()})
+			return
+		}
+		if caller != nil {
+			r = r.WithContext(withPrincipal(r.Context(), caller))
+			// The credential's identity overrides the actor headers.
+			info.actorType, info.actorID = requestActor(r)
+		}
+	}
+
+	if !s.dispatch(w, r) {
 		http.NotFound(w, r)
 	}
 }
 
-func (s *Server) authorize(r *http.Request) bool {
-	if s.bearerToken == "" {
-		return true
-	}
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
 
-	const prefix = "Bearer "
-	authz := r.Header.Get("Authorization")
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
 
-	if !strings.HasPrefix(authz, prefix) {
-		return false
+// handleReady reports whether the server should receive traffic. Unlike /health, it fails as
+// soon as the server starts draining so load balancers stop routing to it. It lists the status
+// of each configured capability but does not run health checks. A backend outage or a provider
+// still initializing affects every replica alike, so it is reported rather than taking replicas
+// out of rotation; the other capabilities keep serving.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "draining"})
+		return
 	}
-
-	token := strings.TrimSpace(authz[len(prefix):])
-	return token == s.bearerToken
+	// Every configured provider must be installed and pass its health check. Checks are
+	// cached for providerPingTTL, so frequent probes do not load the backends.
+	resp, status := readyResponse{Status: "ready", Capabilities: map[string]string{}}, http.StatusOK
+	for _, capability := range capabilities {
+		c := s.capabilityHealth(r.Context(), capability, true)
+		if !c.Configured {
+			continue
+		}
+		resp.Capabilities[capability] = c.Status
+		if c.Status != capabilityOK {
+			resp.Status, status = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, resp)
 }
 
-// ListenAndServe starts the HTTP server.
+// ListenAndServe starts the HTTP server. After Shutdown it returns http.ErrServerClosed.
 func (s *Server) ListenAndServe(addr string) error {
 	srv := &http.Server{Addr: addr, Handler: s}
+	s.lifecycleMu.Lock()
+	if s.draining.Load() {
+		s.lifecycleMu.Unlock()
+		return http.ErrServerClosed
+	}
+	s.httpServer = srv
+	s.lifecycleMu.Unlock()
 
 	serve := s.serve
 	if serve == nil {
@@ -196,8 +281,95 @@ func (s *Server) ListenAndServe(addr string) error {
 		if s.tlsCertFile == "" || s.tlsKeyFile == "" {
 			return fmt.Errorf("TLS requires both cert and key to be configured")
 		}
+		if s.tls != nil {
+			srv.TLSConfig = s.tls.serverConfig()
+		}
 		return serveTLS(srv, s.tlsCertFile, s.tlsKeyFile)
 	}
 
 	return serve(srv)
 }
+
+// Run serves on addr until ctx is done, then shuts the server down, giving in-flight requests
+// up to OPSORCH_SHUTDOWN_TIMEOUT (default 30s) to finish.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	errc := make(chan error, 1)
+	go func() { errc <- s.ListenAndServe(addr) }()
+
+	select {
+	case err := <-errc:
+		s.stopPlugins()
+		s.flushTraces()
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := s.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	slog.Info("shutting down: draining in-flight requests", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	if serveErr := <-errc; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+	return err
+}
+
+// Shutdown drains the server. Readiness fails at once, the listener stops accepting
+// connections, and in-flight requests run until they finish or ctx is done. Plugin processes
+// are then sent the shutdown frame and given pluginShutdownTimeout to exit.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lifecycleMu.Lock()
+	s.draining.Store(true)
+	srv := s.httpServer
+	s.lifecycleMu.Unlock()
+
+	var err error
+	if srv != nil {
+		if err = srv.Shutdown(ctx); err != nil {
+			slog.Warn("shutdown: requests still running after drain timeout", "err", err)
+		}
+	}
+	s.stopPlugins()
+	s.flushTraces()
+	return err
+}
+
+// pluginBacked is a provider that runs plugins, which must be shut down when it goes away.
+type pluginBacked interface{ shutdown(context.Context) }
+
+// stopPlugins shuts down every plugin-backed provider concurrently. Provider retries stop
+// first so no plugin is started behind it.
+func (s *Server) stopPlugins() {
+	s.stopProviderRetries()
+	ctx, cancel := context.WithTimeout(context.Background(), pluginShutdownTimeout)
+	defer cancel()
+
+	providers := []any{s.secret}
+	for _, capability := range capabilities {
+		providers = append(providers, s.capabilityProvider(capability))
+	}
+	var wg sync.WaitGroup
+	for _, provider := range providers {
+		if p, ok := provider.(pluginBacked); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.shutdown(ctx)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// flushTraces exports the spans still buffered, waiting up to traceFlushTimeout.
+func (s *Server) flushTraces() {
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if err := s.tracer.shutdown(ctx); err != nil {
+		slog.Error("shutdown: exporting traces", "err", err)
+	}
+}
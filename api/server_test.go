@@ -212,6 +212,22 @@ func TestBearerAuthSuccess(t *testing.T) {
 	}
 }
 
+func TestReadyNeedsNoCredential(t *testing.T) {
+	srv := &Server{bearerToken: "secret"}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected /ready to answer without a token, got %d", w.Code)
+	}
+	for _, path := range []string{"/health", "/health/providers"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected %s to require a token, got %d", path, w.Code)
+		}
+	}
+}
+
 func TestListenAndServeRequiresTLSPair(t *testing.T) {
 	srv := &Server{tlsCertFile: "/tmp/cert"}
 
@@ -312,7 +328,7 @@ func TestIncidentQueryViaPlugin(t *testing.T) {
 		t.Fatalf("build plugin: %v output=%s", err, string(out))
 	}
 
-	srv := &Server{incident: IncidentHandler{provider: newIncidentPluginProvider(pluginPath, nil)}, corsOrigin: "*"}
+	srv := &Server{incident: IncidentHandler{provider: newIncidentPluginProvider(pluginPath, nil, nil)}, corsOrigin: "*"}
 	body, _ := json.Marshal(schema.IncidentQuery{Limit: 1})
 	req := httptest.NewRequest(http.MethodPost, "/incidents/query", bytes.NewReader(body))
 	w := httptest.NewRecorder()
@@ -340,7 +356,7 @@ func TestLogQueryViaPlugin(t *testing.T) {
 		t.Fatalf("build log plugin: %v output=%s", err, string(out))
 	}
 
-	srv := &Server{log: LogHandler{provider: newLogPluginProvider(pluginPath, nil)}, corsOrigin: "*"}
+	srv := &Server{log: LogHandler{provider: newLogPluginProvider(pluginPath, nil, nil)}, corsOrigin: "*"}
 	body, _ := json.Marshal(schema.LogQuery{Expression: &schema.LogExpression{Search: "test"}, Start: time.Now(), End: time.Now()})
 	req := httptest.NewRequest(http.MethodPost, "/logs/query", bytes.NewReader(body))
 	w := httptest.NewRecorder()
@@ -359,6 +375,24 @@ func TestLogQueryViaPlugin(t *testing.T) {
 	}
 }
 
+func TestLogQueryViaSDKPluginPassesExpression(t *testing.T) {
+	srv := &Server{log: LogHandler{provider: newLogPluginProvider(buildMockPlugin(t, "logmock"), nil, nil)}, corsOrigin: "*"}
+	body, _ := json.Marshal(schema.LogQuery{Expression: &schema.LogExpression{Search: "test"}, Start: time.Now(), End: time.Now()})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/logs/query", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var out schema.LogEntries
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// The SDK decodes the query for the plugin, so the search expression arrives intact.
+	if len(out.Entries) == 0 || out.Entries[0].Message != "plugin log: test" {
+		t.Fatalf("unexpected log plugin response: %+v", out)
+	}
+}
+
 func TestProvidersUnknownCapability(t *testing.T) {
 	srv := &Server{corsOrigin: "*"}
 	req := httptest.NewRequest(http.MethodGet, "/providers/unknown", nil)
@@ -380,7 +414,7 @@ func TestIncidentHandlerFromEnvInvalidJSON(t *testing.T) {
 	t.Setenv("OPSORCH_INCIDENT_PROVIDER", name)
 	t.Setenv("OPSORCH_INCIDENT_CONFIG", "not-json")
 
-	if _, err := newIncidentHandlerFromEnv(nil); err == nil {
+	if _, err := newIncidentHandlerFromEnv(nil, nil); err == nil {
 		t.Fatalf("expected error for invalid JSON config")
 	}
 }
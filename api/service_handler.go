@@ -14,13 +14,13 @@ type ServiceHandler struct {
 	provider service.Provider
 }
 
-func newServiceHandlerFromEnv(sec SecretProvider) (ServiceHandler, error) {
+func newServiceHandlerFromEnv(sec SecretProvider, host pluginHost) (ServiceHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "service", "OPSORCH_SERVICE_PROVIDER", "OPSORCH_SERVICE_CONFIG", "OPSORCH_SERVICE_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return ServiceHandler{}, err
 	}
 	if pluginPath != "" {
-		return ServiceHandler{provider: newServicePluginProvider(pluginPath, cfg)}, nil
+		return ServiceHandler{provider: observeService(pluginPath, newServicePluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := service.LookupProvider(name)
 	if !ok {
@@ -30,34 +30,27 @@ func newServiceHandlerFromEnv(sec SecretProvider) (ServiceHandler, error) {
 	if err != nil {
 		return ServiceHandler{}, err
 	}
-	return ServiceHandler{provider: provider}, nil
+	return ServiceHandler{provider: observeService(name, provider)}, nil
 }
 
-func (s *Server) handleService(w http.ResponseWriter, r *http.Request) bool {
-	if r.URL.Path != "/services" && r.URL.Path != "/services/query" {
-		return false
-	}
-	if s.service.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "service_provider_missing", Message: "service provider not configured"})
-		return true
-	}
+// serviceProvider returns the service provider currently installed.
+func (s *Server) serviceProvider() service.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.service.provider
+}
 
-	switch {
-	case r.URL.Path == "/services/query" && r.Method == http.MethodPost:
-		var query schema.ServiceQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		services, err := s.service.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "service.query")
-		writeJSON(w, http.StatusOK, services)
-		return true
-	default:
-		return false
+func (s *Server) queryServices(w http.ResponseWriter, r *http.Request) {
+	var query schema.ServiceQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	services, err := s.serviceProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "service.query")
+	writeJSON(w, http.StatusOK, services)
 }
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// blockingIncidents holds incident queries until release is closed.
+type blockingIncidents struct {
+	stubIncidentProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingIncidents) Query(ctx context.Context, q schema.IncidentQuery) ([]schema.Incident, error) {
+	close(b.started)
+	<-b.release
+	return b.stubIncidentProvider.Query(ctx, q)
+}
+
+func TestServerRunDrainsInFlightRequests(t *testing.T) {
+	captureLog(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	provider := blockingIncidents{started: make(chan struct{}), release: make(chan struct{})}
+	srv := &Server{
+		corsOrigin:      "*",
+		incident:        IncidentHandler{provider: provider},
+		shutdownTimeout: 5 * time.Second,
+		serve:           func(hs *http.Server) error { return hs.Serve(l) },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(ctx, l.Addr().String()) }()
+
+	ready := httptest.NewRecorder()
+	srv.ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if ready.Code != http.StatusOK {
+		t.Fatalf("expected ready before shutdown, got %d", ready.Code)
+	}
+
+	type result struct {
+		status int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		body, _ := json.Marshal(schema.IncidentQuery{})
+		resp, err := http.Post("http://"+l.Addr().String()+"/incidents/query", "application/json", bytes.NewReader(body))
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		resp.Body.Close()
+		done <- result{status: resp.StatusCode}
+	}()
+	<-provider.started
+	cancel()
+
+	waitFor(t, 2*time.Second, func() bool {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return w.Code == http.StatusServiceUnavailable && strings.Contains(w.Body.String(), "draining")
+	})
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(provider.release)
+	if r := <-done; r.err != nil || r.status != http.StatusOK {
+		t.Fatalf("in-flight request was cut off: %+v", r)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestServerShutdownTimesOutStuckRequests(t *testing.T) {
+	captureLog(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	provider := blockingIncidents{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(provider.release)
+	srv := &Server{corsOrigin: "*", incident: IncidentHandler{provider: provider}, serve: func(hs *http.Server) error { return hs.Serve(l) }}
+	go srv.ListenAndServe(l.Addr().String())
+	go http.Post("http://"+l.Addr().String()+"/incidents/query", "application/json", strings.NewReader("{}"))
+	<-provider.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected drain timeout, got %v", err)
+	}
+}
+
+func TestServerShutdownBeforeListen(t *testing.T) {
+	srv := &Server{}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := srv.ListenAndServe("127.0.0.1:0"); !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("expected ErrServerClosed after shutdown, got %v", err)
+	}
+}
+
+func TestPluginShutdownStopsSDKPlugin(t *testing.T) {
+	logs := captureLog(t)
+	provider := newIncidentPluginProvider(buildMockPlugin(t, "incidentmock"), nil, nil)
+	runner := provider.runner.(*pluginRunner)
+	if _, err := provider.Query(context.Background(), schema.IncidentQuery{}); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	proc := runner.proc
+
+	start := time.Now()
+	provider.shutdown(context.Background())
+	if proc.alive() {
+		t.Fatalf("plugin still running after shutdown")
+	}
+	if proc.exitErr != nil {
+		t.Fatalf("expected a clean plugin exit, got %v", proc.exitErr)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown took %s", elapsed)
+	}
+	out := logs.String()
+	if strings.Contains(out, "did not acknowledge") || strings.Contains(out, "restarting") || !strings.Contains(out, `"msg":"plugin shut down","capability":"incident"`) {
+		t.Fatalf("unexpected shutdown log: %q", out)
+	}
+	if _, err := provider.Query(context.Background(), schema.IncidentQuery{}); err == nil {
+		t.Fatalf("expected calls to fail after shutdown")
+	}
+}
+
+func TestPluginShutdownLegacyPluginExitsOnEOF(t *testing.T) {
+	captureLog(t)
+	script := writePluginScript(t, `while read -r line; do echo '{"error":"unknown method"}'; done
+exit 0
+`)
+	runner := newPluginRunner("service", script, nil, pluginOptions{})
+	_ = runner.call(context.Background(), "service.query", nil, nil)
+	proc := runner.proc
+
+	runner.shutdown(context.Background())
+	if proc.alive() || proc.exitErr != nil {
+		t.Fatalf("expected the plugin to exit on its own, exitErr=%v", proc.exitErr)
+	}
+}
+
+func TestPluginShutdownKillsPluginThatIgnoresIt(t *testing.T) {
+	logs := captureLog(t)
+	script := writePluginScript(t, `read -r handshake
+echo '{"error":"unknown method"}'
+while read -r line; do echo '{"result":"ok"}'; done
+exec sleep 60
+`)
+	runner := newPluginRunner("service", script, nil, pluginOptions{})
+	if err := runner.call(context.Background(), "service.query", nil, nil); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	proc := runner.proc
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	runner.shutdown(ctx)
+	if proc.alive() {
+		t.Fatalf("plugin still running after shutdown")
+	}
+	if !strings.Contains(logs.String(), "did not exit in time; killing it") {
+		t.Fatalf("expected kill after timeout, got %q", logs.String())
+	}
+}
+
+func TestPluginShutdownKillsPluginBusyWithACall(t *testing.T) {
+	logs := captureLog(t)
+	script := writePluginScript(t, `read -r handshake
+echo '{"error":"unknown method"}'
+read -r line
+exec sleep 60
+`)
+	// With no call timeout the wedged call would otherwise hold the stream lock forever.
+	runner := newPluginRunner("service", script, nil, pluginOptions{})
+	callErr := make(chan error, 1)
+	go func() { callErr <- runner.call(context.Background(), "service.query", nil, nil) }()
+	waitFor(t, 2*time.Second, func() bool { return runner.inFlight.Load() == 1 && runner.live.Load() != nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		runner.shutdown(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("shutdown waited on the wedged call")
+	}
+	if err := <-callErr; err == nil {
+		t.Fatalf("expected the wedged call to fail")
+	}
+	if !strings.Contains(logs.String(), "plugin busy at shutdown; killing it") {
+		t.Fatalf("expected the busy plugin to be killed, got %q", logs.String())
+	}
+}
+
+func TestProviderConfigShutsDownReplacedPlugin(t *testing.T) {
+	logs := captureLog(t)
+	plugin := buildMockPlugin(t, "incidentmock")
+	srv := &Server{secret: &memorySecret{store: map[string]string{}}}
+	configure := func() *pluginProcess {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"provider": "incidentmock", "plugin": plugin})
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/providers/incident", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("configure: %d %s", w.Code, w.Body.String())
+		}
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/incidents/query", strings.NewReader("{}")))
+		if w.Code != http.StatusOK {
+			t.Fatalf("query: %d %s", w.Code, w.Body.String())
+		}
+		inner := srv.incidentProvider().(observedIncidentProvider).inner
+		return inner.(incidentPluginProvider).runner.(*pluginRunner).proc
+	}
+	old := configure()
+	current := configure()
+	defer srv.stopPlugins()
+
+	if old.alive() {
+		t.Fatalf("replaced plugin is still running")
+	}
+	if !current.alive() {
+		t.Fatalf("expected the new plugin to keep running")
+	}
+	if !strings.Contains(logs.String(), `"msg":"plugin shut down","capability":"incident"`) {
+		t.Fatalf("expected the replaced plugin to be shut down cleanly, got %q", logs.String())
+	}
+}
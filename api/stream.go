@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// jsonArrayStream writes a JSON document whose large part is one array, element by element,
+// flushing as batches arrive. The status line is held back until the first element so that a
+// provider that fails before returning anything still gets a normal error response.
+type jsonArrayStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	prefix  string // document text up to and including the array's opening bracket
+	started bool
+	count   int
+}
+
+func newJSONArrayStream(w http.ResponseWriter, prefix string) *jsonArrayStream {
+	return &jsonArrayStream{w: w, rc: http.NewResponseController(w), prefix: prefix}
+}
+
+func (s *jsonArrayStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+	_, err := s.w.Write([]byte(s.prefix))
+	return err
+}
+
+// add appends one array element.
+func (s *jsonArrayStream) add(item any) error {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := s.start(); err != nil {
+		return err
+	}
+	if s.count > 0 {
+		encoded = append([]byte{','}, encoded...)
+	}
+	s.count++
+	_, err = s.w.Write(encoded)
+	return err
+}
+
+// flush pushes the elements written so far to the client.
+func (s *jsonArrayStream) flush() error {
+	if !s.started {
+		return nil
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// close ends the document with suffix, the text after the array's closing bracket.
+func (s *jsonArrayStream) close(suffix string) {
+	if err := s.start(); err != nil {
+		return
+	}
+	_, _ = s.w.Write([]byte("]" + suffix + "\n"))
+}
+
+// fail reports a provider error. Once the status line has gone out, the only honest signal
+// left is to abort the response, so the client sees a truncated body rather than a partial
+// result that looks complete.
+func (s *jsonArrayStream) fail(err error) {
+	if !s.started {
+		writeProviderError(s.w, err)
+		return
+	}
+	slog.ErrorContext(requestContext(s.w), "stream aborted", "elements", s.count, "err", err)
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// batchedLogs streams one entry per batch. When gate is set, it waits for a value on gate
+// before sending each batch after the first, and fails with failAfter once that many batches
+// have been sent.
+type batchedLogs struct {
+	messages  []string
+	gate      chan struct{}
+	failAfter int
+}
+
+func (b batchedLogs) Query(ctx context.Context, q schema.LogQuery) (schema.LogEntries, error) {
+	return schema.LogEntries{}, errors.New("Query must not be used when streaming is available")
+}
+
+func (b batchedLogs) QueryStream(ctx context.Context, q schema.LogQuery, send func(schema.LogEntries) error) error {
+	for i, msg := range b.messages {
+		if b.failAfter > 0 && i == b.failAfter {
+			return orcherr.New("upstream_error", "backend went away", nil)
+		}
+		if i > 0 && b.gate != nil {
+			<-b.gate
+		}
+		batch := schema.LogEntries{Entries: []schema.LogEntry{{Message: msg}}}
+		if i == len(b.messages)-1 {
+			batch.URL = "https://logs.example.com/q"
+		}
+		if err := send(batch); err != nil {
+			return err
+		}
+	}
+	if b.failAfter > 0 && b.failAfter >= len(b.messages) {
+		return orcherr.New("upstream_error", "backend went away", nil)
+	}
+	return nil
+}
+
+func postLogQuery(t *testing.T, url string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(schema.LogQuery{Start: time.Now(), End: time.Now()})
+	resp, err := http.Post(url+"/logs/query", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	return resp
+}
+
+func TestLogQueryStreamsBatchesIncrementally(t *testing.T) {
+	captureLog(t)
+	gate := make(chan struct{})
+	srv := httptest.NewServer(&Server{log: LogHandler{provider: batchedLogs{messages: []string{"one", "two"}, gate: gate}}, corsOrigin: "*"})
+	defer srv.Close()
+
+	resp := postLogQuery(t, srv.URL)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	// The first entry reaches the client while the provider is still blocked on the second.
+	reader := bufio.NewReader(resp.Body)
+	first := make([]byte, len(`{"entries":[{"timestamp":"0001-01-01T00:00:00Z","message":"one"}`))
+	if _, err := io.ReadFull(reader, first); err != nil {
+		t.Fatalf("read first batch: %v", err)
+	}
+	if !strings.Contains(string(first), `"message":"one"`) {
+		t.Fatalf("unexpected first batch %s", first)
+	}
+	close(gate)
+
+	rest, _ := io.ReadAll(reader)
+	var out schema.LogEntries
+	if err := json.Unmarshal(append(first, rest...), &out); err != nil {
+		t.Fatalf("decode streamed body: %v body=%s%s", err, first, rest)
+	}
+	if len(out.Entries) != 2 || out.Entries[1].Message != "two" || out.URL != "https://logs.example.com/q" {
+		t.Fatalf("unexpected streamed result: %+v", out)
+	}
+}
+
+func TestLogQueryStreamErrorBeforeData(t *testing.T) {
+	captureLog(t)
+	srv := httptest.NewServer(&Server{log: LogHandler{provider: batchedLogs{failAfter: 1}}, corsOrigin: "*"})
+	defer srv.Close()
+
+	resp := postLogQuery(t, srv.URL)
+	defer resp.Body.Close()
+	var body map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusBadGateway || body["code"] != "upstream_error" {
+		t.Fatalf("expected a normal 502 error response, got %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestLogQueryStreamErrorAfterDataAbortsResponse(t *testing.T) {
+	captureLog(t)
+	srv := httptest.NewServer(&Server{log: LogHandler{provider: batchedLogs{messages: []string{"one", "two"}, failAfter: 2}}, corsOrigin: "*"})
+	defer srv.Close()
+
+	resp := postLogQuery(t, srv.URL)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && json.Valid(body) {
+		t.Fatalf("expected a truncated response after a mid-stream failure, got complete body %s", body)
+	}
+}
+
+func TestMetricQueryStreamsPluginChunks(t *testing.T) {
+	captureLog(t)
+	script := writePluginScript(t, shellIDHelpers+`read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\",\"capability\":\"metric\"}}"
+while read -r line; do
+  id=$(id_of "$line")
+  echo "{\"id\":$id,\"chunk\":[{\"name\":\"cpu\",\"service\":\"a\"}]}"
+  echo "{\"id\":$id,\"chunk\":[{\"name\":\"cpu\",\"service\":\"b\"}]}"
+  echo "{\"id\":$id,\"result\":[{\"name\":\"cpu\",\"service\":\"c\"}]}"
+done
+`)
+	provider := newMetricPluginProvider(script, nil, nil)
+	defer provider.runner.close()
+	srv := &Server{metric: MetricHandler{provider: provider}, corsOrigin: "*"}
+	body, _ := json.Marshal(schema.MetricQuery{})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics/query", bytes.NewReader(body)))
+
+	var series []schema.MetricSeries
+	if err := json.Unmarshal(w.Body.Bytes(), &series); err != nil {
+		t.Fatalf("decode: %v body=%s", err, w.Body.String())
+	}
+	if w.Code != http.StatusOK || len(series) != 3 || series[0].Service != "a" || series[2].Service != "c" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPluginStreamFallsBackToSingleResult(t *testing.T) {
+	captureLog(t)
+	// A lock-step plugin that knows nothing about streaming.
+	runner := newPluginRunner("log", writePluginScript(t, `read -r handshake
+echo '{"error":"unknown method"}'
+while read -r line; do
+  echo '{"result":{"entries":[{"message":"whole"}]}}'
+done
+`), nil, pluginOptions{})
+	defer runner.close()
+
+	var chunks []string
+	err := runner.stream(context.Background(), "log.query", schema.LogQuery{}, func(chunk json.RawMessage) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	if err != nil || len(chunks) != 1 || !strings.Contains(chunks[0], "whole") {
+		t.Fatalf("expected the single result as one chunk, got %v err=%v", chunks, err)
+	}
+}
+
+func TestPluginStreamOverHTTP(t *testing.T) {
+	captureLog(t)
+	handler, err := pluginsdk.NewHTTPHandler(batchedLogs{messages: []string{"one", "two", "three"}})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	provider := newLogPluginProvider(srv.URL, nil, nil)
+	var got []string
+	err = provider.QueryStream(context.Background(), schema.LogQuery{}, func(batch schema.LogEntries) error {
+		for _, e := range batch.Entries {
+			got = append(got, e.Message)
+		}
+		return nil
+	})
+	if err != nil || strings.Join(got, ",") != "one,two,three" {
+		t.Fatalf("unexpected streamed entries %v err=%v", got, err)
+	}
+}
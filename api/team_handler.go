@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/opsorch/opsorch-core/orcherr"
 	"github.com/opsorch/opsorch-core/schema"
@@ -15,13 +14,13 @@ type TeamHandler struct {
 	provider team.Provider
 }
 
-func newTeamHandlerFromEnv(sec SecretProvider) (TeamHandler, error) {
+func newTeamHandlerFromEnv(sec SecretProvider, host pluginHost) (TeamHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "team", "OPSORCH_TEAM_PROVIDER", "OPSORCH_TEAM_CONFIG", "OPSORCH_TEAM_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return TeamHandler{}, err
 	}
 	if pluginPath != "" {
-		return TeamHandler{provider: newTeamPluginProvider(pluginPath, cfg)}, nil
+		return TeamHandler{provider: observeTeam(pluginPath, newTeamPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := team.LookupProvider(name)
 	if !ok {
@@ -31,57 +30,47 @@ func newTeamHandlerFromEnv(sec SecretProvider) (TeamHandler, error) {
 	if err != nil {
 		return TeamHandler{}, err
 	}
-	return TeamHandler{provider: provider}, nil
+	return TeamHandler{provider: observeTeam(name, provider)}, nil
 }
 
-func (s *Server) handleTeam(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/teams") {
-		return false
+// teamProvider returns the team provider currently installed.
+func (s *Server) teamProvider() team.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.team.provider
+}
+
+func (s *Server) queryTeams(w http.ResponseWriter, r *http.Request) {
+	var query schema.TeamQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if s.team.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "team_provider_missing", Message: "team provider not configured"})
-		return true
+	teams, err := s.teamProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "team.query")
+	writeJSON(w, http.StatusOK, teams)
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
+func (s *Server) getTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := s.teamProvider().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "team.get")
+	writeJSON(w, http.StatusOK, team)
+}
 
-	switch {
-	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
-		var query schema.TeamQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		teams, err := s.team.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "team.query")
-		writeJSON(w, http.StatusOK, teams)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodGet:
-		id := segments[1]
-		team, err := s.team.provider.Get(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "team.get")
-		writeJSON(w, http.StatusOK, team)
-		return true
-	case len(segments) == 3 && segments[2] == "members" && r.Method == http.MethodGet:
-		teamID := segments[1]
-		members, err := s.team.provider.Members(r.Context(), teamID)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "team.members")
-		writeJSON(w, http.StatusOK, members)
-		return true
-	default:
-		return false
+func (s *Server) getTeamMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := s.teamProvider().Members(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "team.members")
+	writeJSON(w, http.StatusOK, members)
 }
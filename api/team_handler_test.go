@@ -162,7 +162,7 @@ func TestTeamProperty_EnvironmentConfigurationProcessing(t *testing.T) {
 			}()
 
 			mockSec := &mockSecretProvider{}
-			handler, err := newTeamHandlerFromEnv(mockSec)
+			handler, err := newTeamHandlerFromEnv(mockSec, nil)
 
 			if tc.expectError && err == nil {
 				t.Errorf("expected error but got none")
@@ -868,9 +868,9 @@ func TestTeamProperty_EndpointRouting(t *testing.T) {
 			expectHandled:  true,
 		},
 		{
-			name:           "non-team path returns false",
+			name:           "unrouted path returns false",
 			method:         "GET",
-			path:           "/incidents/123",
+			path:           "/unknown/123",
 			expectedStatus: 0,
 			expectHandled:  false,
 		},
@@ -887,7 +887,7 @@ func TestTeamProperty_EndpointRouting(t *testing.T) {
 			}
 			recorder := httptest.NewRecorder()
 
-			handled := server.handleTeam(recorder, req)
+			handled := server.dispatch(recorder, req)
 
 			if handled != tc.expectHandled {
 				t.Errorf("expected handled=%v, got %v", tc.expectHandled, handled)
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// latencyBuckets are the histogram bounds, in seconds, for request and provider latency.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// selfMetrics is core's own telemetry, served in the Prometheus text format at /admin/metrics.
+// It is package-level because plugin runners record into it without a reference to the Server.
+var selfMetrics = struct {
+	requests         *metricFamily
+	requestDuration  *metricFamily
+	providerCalls    *metricFamily
+	providerDuration *metricFamily
+	pluginRestarts   *metricFamily
+	pluginQueue      *metricFamily
+}{
+	requests: newMetricFamily("opsorch_http_requests_total", "HTTP requests served, by route capability, audit action, and status code.",
+		"counter", "capability", "action", "status"),
+	requestDuration: newMetricFamily("opsorch_http_request_duration_seconds", "Time to serve HTTP requests.",
+		"histogram", "capability", "action", "status"),
+	providerCalls: newMetricFamily("opsorch_provider_calls_total", "Provider calls, by result code (ok or the error code).",
+		"counter", "capability", "provider", "method", "code"),
+	providerDuration: newMetricFamily("opsorch_provider_call_duration_seconds", "Time spent in provider calls.",
+		"histogram", "capability", "provider", "method"),
+	pluginRestarts: newMetricFamily("opsorch_plugin_restarts_total", "Plugin processes restarted by the supervisor after exiting unexpectedly.",
+		"counter", "capability", "plugin"),
+	pluginQueue: newMetricFamily("opsorch_plugin_queue_depth", "Plugin calls queued or running.",
+		"gauge", "capability", "plugin"),
+}
+
+// metricFamily is one Prometheus metric with a fixed set of label names.
+type metricFamily struct {
+	name, help, kind string // kind is counter, gauge, or histogram
+	labels           []string
+
+	mu     sync.Mutex
+	series map[string]*metricSeries // keyed by the joined label values
+}
+
+type metricSeries struct {
+	values []string
+	value  float64  // counter or gauge
+	counts []uint64 // histogram: observations per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newMetricFamily(name, help, kind string, labels ...string) *metricFamily {
+	return &metricFamily{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*metricSeries)}
+}
+
+func (f *metricFamily) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s := f.series[key]
+	if s == nil {
+		s = &metricSeries{values: slices.Clone(values)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(latencyBuckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// add adds delta to a counter or gauge.
+func (f *metricFamily) add(delta float64, values ...string) {
+	f.mu.Lock()
+	f.get(values).value += delta
+	f.mu.Unlock()
+}
+
+// observe records one histogram observation.
+func (f *metricFamily) observe(v float64, values ...string) {
+	f.mu.Lock()
+	s := f.get(values)
+	if i, _ := slices.BinarySearch(latencyBuckets, v); i < len(latencyBuckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+	f.mu.Unlock()
+}
+
+func (f *metricFamily) write(w io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		labels := f.labelPairs(s.values)
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%s{%s} %s\n", f.name, labels, formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labels, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", f.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", f.name, labels, s.count)
+	}
+}
+
+func (f *metricFamily) labelPairs(values []string) string {
+	pairs := make([]string, len(f.labels))
+	for i, name := range f.labels {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// prometheusText marks a route whose response is the Prometheus text exposition format.
+type prometheusText string
+
+func (s *Server) handleSelfMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, f := range []*metricFamily{
+		selfMetrics.requests, selfMetrics.requestDuration,
+		selfMetrics.providerCalls, selfMetrics.providerDuration,
+		selfMetrics.pluginRestarts, selfMetrics.pluginQueue,
+	} {
+		f.write(w)
+	}
+}
+
+// statusRecorder remembers the status code a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	ctx    context.Context // the request's context, for logging from helpers that only hold w
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streamed responses.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
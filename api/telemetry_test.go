@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/log"
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+type missingIncidentProvider struct{ stubIncidentProvider }
+
+func (missingIncidentProvider) Get(ctx context.Context, id string) (schema.Incident, error) {
+	return schema.Incident{}, orcherr.OpsOrchError{Code: "not_found", Message: id + " not found"}
+}
+
+func scrapeSelfMetrics(t *testing.T, srv *Server) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("expected Prometheus text, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	return w.Body.String()
+}
+
+func TestSelfMetricsCountRequestsAndProviderCalls(t *testing.T) {
+	srv := &Server{incident: IncidentHandler{provider: observeIncident("telemetry-test", missingIncidentProvider{})}}
+	for _, path := range []string{"/incidents/INC-1", "/incidents/INC-2", "/alerts/A-1"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrapeSelfMetrics(t, srv)
+	for _, want := range []string{
+		"# TYPE opsorch_http_requests_total counter\n",
+		`opsorch_http_requests_total{capability="incident",action="incident.get",status="404"} `,
+		`opsorch_http_requests_total{capability="alert",action="",status="501"} `,
+		`opsorch_provider_calls_total{capability="incident",provider="telemetry-test",method="incident.get",code="not_found"} 2` + "\n",
+		`opsorch_provider_call_duration_seconds_bucket{capability="incident",provider="telemetry-test",method="incident.get",le="+Inf"} 2` + "\n",
+		`opsorch_provider_call_duration_seconds_count{capability="incident",provider="telemetry-test",method="incident.get"} 2` + "\n",
+		"# TYPE opsorch_plugin_queue_depth gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestSelfMetricsRequireAdminScope(t *testing.T) {
+	srv := &Server{tokens: testTokenStore(t)}
+	if w := serveWithToken(srv, http.MethodGet, "/admin/metrics", "dash-secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected admin:read to be required, got %d", w.Code)
+	}
+}
+
+func TestMetricFamilyHistogramIsCumulative(t *testing.T) {
+	f := newMetricFamily("test_seconds", "Test.", "histogram", "name")
+	for _, v := range []float64{0.001, 0.005, 0.2, 60} {
+		f.observe(v, `a"b`)
+	}
+	var out strings.Builder
+	f.write(&out)
+	for _, want := range []string{
+		`test_seconds_bucket{name="a\"b",le="0.005"} 2` + "\n",
+		`test_seconds_bucket{name="a\"b",le="0.1"} 2` + "\n",
+		`test_seconds_bucket{name="a\"b",le="0.25"} 3` + "\n",
+		`test_seconds_bucket{name="a\"b",le="30"} 3` + "\n",
+		`test_seconds_bucket{name="a\"b",le="+Inf"} 4` + "\n",
+		`test_seconds_sum{name="a\"b"} 60.206` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in\n%s", want, out.String())
+		}
+	}
+}
+
+func TestObservedProvidersKeepStreaming(t *testing.T) {
+	if _, ok := observeLog("x", stubLogProvider{}).(log.StreamingProvider); ok {
+		t.Fatalf("expected a non-streaming provider to stay non-streaming")
+	}
+	plugin := observeLog("x", newLogPluginProvider("/bin/false", nil, nil))
+	if _, ok := plugin.(log.StreamingProvider); !ok {
+		t.Fatalf("expected a streaming provider to keep QueryStream")
+	}
+	if _, ok := plugin.(interface{ shutdown(context.Context) }); !ok {
+		t.Fatalf("expected plugin shutdown to be forwarded")
+	}
+}
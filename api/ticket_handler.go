@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/opsorch/opsorch-core/orcherr"
 	"github.com/opsorch/opsorch-core/schema"
@@ -15,13 +14,13 @@ type TicketHandler struct {
 	provider ticket.Provider
 }
 
-func newTicketHandlerFromEnv(sec SecretProvider) (TicketHandler, error) {
+func newTicketHandlerFromEnv(sec SecretProvider, host pluginHost) (TicketHandler, error) {
 	name, cfg, pluginPath, err := loadProviderConfig(sec, "ticket", "OPSORCH_TICKET_PROVIDER", "OPSORCH_TICKET_CONFIG", "OPSORCH_TICKET_PLUGIN")
 	if err != nil || (name == "" && pluginPath == "") {
 		return TicketHandler{}, err
 	}
 	if pluginPath != "" {
-		return TicketHandler{provider: newTicketPluginProvider(pluginPath, cfg)}, nil
+		return TicketHandler{provider: observeTicket(pluginPath, newTicketPluginProvider(pluginPath, cfg, host))}, nil
 	}
 	constructor, ok := ticket.LookupProvider(name)
 	if !ok {
@@ -31,76 +30,67 @@ func newTicketHandlerFromEnv(sec SecretProvider) (TicketHandler, error) {
 	if err != nil {
 		return TicketHandler{}, err
 	}
-	return TicketHandler{provider: provider}, nil
+	return TicketHandler{provider: observeTicket(name, provider)}, nil
 }
 
-func (s *Server) handleTicket(w http.ResponseWriter, r *http.Request) bool {
-	if !strings.HasPrefix(r.URL.Path, "/tickets") {
-		return false
+// ticketProvider returns the ticket provider currently installed.
+func (s *Server) ticketProvider() ticket.Provider {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.ticket.provider
+}
+
+func (s *Server) queryTickets(w http.ResponseWriter, r *http.Request) {
+	var query schema.TicketQuery
+	if err := decodeJSON(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	tickets, err := s.ticketProvider().Query(r.Context(), query)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "ticket.query")
+	writeJSON(w, http.StatusOK, tickets)
+}
+
+func (s *Server) createTicket(w http.ResponseWriter, r *http.Request) {
+	var input schema.CreateTicketInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
 	}
-	if s.ticket.provider == nil {
-		writeError(w, http.StatusNotImplemented, orcherr.OpsOrchError{Code: "ticket_provider_missing", Message: "ticket provider not configured"})
-		return true
+	t, err := s.ticketProvider().Create(r.Context(), input)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "ticket.created")
+	writeJSON(w, http.StatusCreated, t)
+}
 
-	path := strings.TrimSuffix(r.URL.Path, "/")
-	segments := strings.Split(strings.Trim(path, "/"), "/")
+func (s *Server) getTicket(w http.ResponseWriter, r *http.Request) {
+	t, err := s.ticketProvider().Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	logAudit(r, "ticket.get")
+	writeJSON(w, http.StatusOK, t)
+}
 
-	switch {
-	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
-		var query schema.TicketQuery
-		if err := decodeJSON(r, &query); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		tickets, err := s.ticket.provider.Query(r.Context(), query)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "ticket.query")
-		writeJSON(w, http.StatusOK, tickets)
-		return true
-	case len(segments) == 1 && r.Method == http.MethodPost:
-		var input schema.CreateTicketInput
-		if err := decodeJSON(r, &input); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		t, err := s.ticket.provider.Create(r.Context(), input)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "ticket.created")
-		writeJSON(w, http.StatusCreated, t)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodGet:
-		id := segments[1]
-		t, err := s.ticket.provider.Get(r.Context(), id)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "ticket.get")
-		writeJSON(w, http.StatusOK, t)
-		return true
-	case len(segments) == 2 && r.Method == http.MethodPatch:
-		id := segments[1]
-		var in schema.UpdateTicketInput
-		if err := decodeJSON(r, &in); err != nil {
-			writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
-			return true
-		}
-		t, err := s.ticket.provider.Update(r.Context(), id, in)
-		if err != nil {
-			writeProviderError(w, err)
-			return true
-		}
-		logAudit(r, "ticket.updated")
-		writeJSON(w, http.StatusOK, t)
-		return true
-	default:
-		return false
+func (s *Server) updateTicket(w http.ResponseWriter, r *http.Request) {
+	var in schema.UpdateTicketInput
+	if err := decodeJSON(r, &in); err != nil {
+		writeError(w, http.StatusBadRequest, orcherr.OpsOrchError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	t, err := s.ticketProvider().Update(r.Context(), r.PathValue("id"), in)
+	if err != nil {
+		writeProviderError(w, err)
+		return
 	}
+	logAudit(r, "ticket.updated")
+	writeJSON(w, http.StatusOK, t)
 }
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTLSReloadInterval = 10 * time.Second
+
+// tlsReloader serves the certificate in certFile/keyFile and, when caFile is set, verifies
+// client certificates against that bundle. The files are re-read when their size or
+// modification time changes, checked at most once per interval, so rotated certificates are
+// picked up without a restart. A rotation that fails to load keeps the previous files in use.
+type tlsReloader struct {
+	certFile, keyFile string
+	caFile            string // empty when client certificates are not requested
+	clientAuth        tls.ClientAuthType
+	interval          time.Duration // zero disables reloading
+
+	mu      sync.Mutex
+	config  *tls.Config
+	stamp   string // size and mtime of every file, as of the last load
+	checked time.Time
+}
+
+func newTLSReloader(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType, interval time.Duration) (*tlsReloader, error) {
+	t := &tlsReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, clientAuth: clientAuth, interval: interval}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// serverConfig returns the config for http.Server.TLSConfig. Every handshake is served from
+// the most recently loaded files.
+func (t *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return t.current(), nil
+		},
+	}
+}
+
+func (t *tlsReloader) current() *tls.Config {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interval > 0 && time.Since(t.checked) >= t.interval {
+		t.checked = time.Now()
+		if stamp := fileStamp(t.certFile, t.keyFile, t.caFile); stamp != t.stamp {
+			if err := t.load(); err != nil {
+				slog.Error("tls_reload_error", "err", err)
+			} else {
+				slog.Info("tls_reloaded", "cert", t.certFile, "client_ca", t.caFile)
+			}
+		}
+	}
+	return t.config
+}
+
+// load reads the files and replaces the served config. The stamp is recorded even when loading
+// fails so a broken rotation is retried only once the files change again.
+func (t *tlsReloader) load() error {
+	t.stamp = fileStamp(t.certFile, t.keyFile, t.caFile)
+	t.checked = time.Now()
+	cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+	if t.caFile != "" {
+		pem, err := os.ReadFile(t.caFile)
+		if err != nil {
+			return fmt.Errorf("read OPSORCH_TLS_CLIENT_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("OPSORCH_TLS_CLIENT_CA_FILE %s has no PEM certificates", t.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = t.clientAuth
+	}
+	t.config = config
+	return nil
+}
+
+// fileStamp summarizes the size and modification time of each file, so a changed stamp means
+// one of them was rewritten or replaced.
+func fileStamp(paths ...string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// clientCertAuth maps verified client certificates onto principals.
+type clientCertAuth struct {
+	// actorFrom lists the certificate names tried, in order, for the actor ID:
+	// "uri", "dns", and "email" SANs, and the subject "cn".
+	actorFrom []string
+	// scopes grants scopes by actor ID, by subject organizational unit, or to every
+	// certificate under "*".
+	scopes map[string][]string
+}
+
+var clientActorSources = map[string]bool{"uri": true, "dns": true, "email": true, "cn": true}
+
+// newTLSFromEnv configures TLS from OPSORCH_TLS_* variables. It returns nil values when TLS is
+// disabled, and a nil clientCertAuth when client certificates are not requested.
+func newTLSFromEnv(certFile, keyFile string) (*tlsReloader, *clientCertAuth, error) {
+	caFile := strings.TrimSpace(os.Getenv("OPSORCH_TLS_CLIENT_CA_FILE"))
+	if certFile == "" {
+		if caFile != "" {
+			return nil, nil, fmt.Errorf("OPSORCH_TLS_CLIENT_CA_FILE requires OPSORCH_TLS_CERT_FILE and OPSORCH_TLS_KEY_FILE")
+		}
+		return nil, nil, nil
+	}
+
+	clientAuth := tls.RequireAndVerifyClientCert
+	switch mode := strings.TrimSpace(os.Getenv("OPSORCH_TLS_CLIENT_AUTH")); mode {
+	case "", "require":
+	case "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, nil, fmt.Errorf("invalid OPSORCH_TLS_CLIENT_AUTH %q: use require or optional", mode)
+	}
+	reloader, err := newTLSReloader(certFile, keyFile, caFile, clientAuth, envDuration("OPSORCH_TLS_RELOAD_INTERVAL", defaultTLSReloadInterval))
+	if err != nil || caFile == "" {
+		return reloader, nil, err
+	}
+
+	auth := &clientCertAuth{actorFrom: []string{"uri", "dns", "email", "cn"}}
+	if raw := strings.TrimSpace(os.Getenv("OPSORCH_TLS_CLIENT_ACTOR")); raw != "" {
+		auth.actorFrom = nil
+		for _, source := range strings.Split(raw, ",") {
+			source = strings.ToLower(strings.TrimSpace(source))
+			if !clientActorSources[source] {
+				return nil, nil, fmt.Errorf("invalid OPSORCH_TLS_CLIENT_ACTOR source %q: use uri, dns, email, or cn", source)
+			}
+			auth.actorFrom = append(auth.actorFrom, source)
+		}
+	}
+	// Like API tokens, certificate callers get no scopes by default, so a mapping that grants
+	// none would leave every certificate forbidden from every scoped route.
+	raw := strings.TrimSpace(os.Getenv("OPSORCH_TLS_CLIENT_SCOPES"))
+	if raw == "" {
+		return nil, nil, fmt.Errorf("OPSORCH_TLS_CLIENT_CA_FILE requires OPSORCH_TLS_CLIENT_SCOPES to grant certificate callers their scopes")
+	}
+	if err := json.Unmarshal([]byte(raw), &auth.scopes); err != nil {
+		return nil, nil, fmt.Errorf("invalid OPSORCH_TLS_CLIENT_SCOPES: %w", err)
+	}
+	if len(auth.scopes) == 0 {
+		return nil, nil, fmt.Errorf("OPSORCH_TLS_CLIENT_SCOPES must grant at least one scope")
+	}
+	for name, scopes := range auth.scopes {
+		if len(scopes) == 0 {
+			return nil, nil, fmt.Errorf("OPSORCH_TLS_CLIENT_SCOPES: %s: at least one scope is required", name)
+		}
+		for _, scope := range scopes {
+			if !validScope(scope) {
+				return nil, nil, fmt.Errorf("OPSORCH_TLS_CLIENT_SCOPES: %s: invalid scope %q", name, scope)
+			}
+		}
+	}
+	return reloader, auth, nil
+}
+
+// principal returns the caller identified by the connection's verified client certificate, or
+// nil when the client did not present one.
+func (a *clientCertAuth) principal(state *tls.ConnectionState) *principal {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := state.VerifiedChains[0][0]
+	p := &principal{name: "mtls", actorType: "service", groups: cert.Subject.OrganizationalUnit}
+	for _, source := range a.actorFrom {
+		if p.actorID = certName(cert, source); p.actorID != "" {
+			break
+		}
+	}
+	if p.actorID == "" {
+		return nil
+	}
+	p.scopes = append(p.scopes, a.scopes["*"]...)
+	p.scopes = append(p.scopes, a.scopes[p.actorID]...)
+	for _, group := range p.groups {
+		p.scopes = append(p.scopes, a.scopes[group]...)
+	}
+	return p
+}
+
+func certName(cert *x509.Certificate, source string) string {
+	switch source {
+	case "uri":
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case "dns":
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case "email":
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case "cn":
+		return cert.Subject.CommonName
+	}
+	return ""
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate and returns its PEM certificate and key.
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) serverFiles(t *testing.T, dir string) {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, &x509.Certificate{
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	for name, data := range map[string][]byte{"server.crt": certPEM, "server.key": keyPEM, "ca.pem": ca.pem} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (ca *testCA) client(t *testing.T, tmpl *x509.Certificate) *http.Client {
+	t.Helper()
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	certPEM, keyPEM := ca.issue(t, tmpl)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}},
+	}}
+}
+
+func startMTLSServer(t *testing.T, dir string) (*Server, *httptest.Server) {
+	t.Helper()
+	t.Setenv("OPSORCH_TLS_CLIENT_CA_FILE", filepath.Join(dir, "ca.pem"))
+	t.Setenv("OPSORCH_TLS_CLIENT_SCOPES", `{"sre":["incident:write"],"ci.example.com":["ticket:read"]}`)
+	t.Setenv("OPSORCH_TLS_RELOAD_INTERVAL", "1ns")
+	reloader, auth, err := newTLSFromEnv(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatalf("configure TLS: %v", err)
+	}
+	srv := &Server{tls: reloader, clientCerts: auth, incident: IncidentHandler{provider: stubIncidentProvider{}}, ticket: TicketHandler{provider: stubTicketProvider{}}}
+	ts := httptest.NewUnstartedServer(srv)
+	ts.TLS = reloader.serverConfig()
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func TestClientCertificatesIdentifyCallers(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca-1")
+	ca.serverFiles(t, dir)
+	_, ts := startMTLSServer(t, dir)
+
+	logs := captureLog(t)
+	sre := ca.client(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"sre"}},
+		URIs:    []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/alice"}},
+	})
+	resp, err := sre.Post(ts.URL+"/incidents", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if out := logs.String(); !strings.Contains(out, `"actor_type":"service","actor_id":"spiffe://example.com/alice"`) || !strings.Contains(out, `"credential":"mtls"`) {
+		t.Fatalf("expected the certificate identity in the audit log, got %q", out)
+	}
+
+	ci := ca.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ci"}, DNSNames: []string{"ci.example.com"}})
+	for path, want := range map[string]int{"/tickets/1": http.StatusOK, "/incidents/1": http.StatusForbidden} {
+		resp, err := ci.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if _, err := anonymous.Get(ts.URL + "/health"); err == nil {
+		t.Fatalf("expected the handshake to fail without a client certificate")
+	}
+	other := newTestCA(t, "other")
+	if _, err := other.client(t, &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}).Get(ts.URL + "/health"); err == nil {
+		t.Fatalf("expected a certificate from another CA to be rejected")
+	}
+}
+
+func TestTLSFilesReloadWithoutRestart(t *testing.T) {
+	dir := t.TempDir()
+	oldCA := newTestCA(t, "ca-1")
+	oldCA.serverFiles(t, dir)
+	_, ts := startMTLSServer(t, dir)
+
+	alice := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	if resp, err := oldCA.client(t, alice).Get(ts.URL + "/health"); err != nil {
+		t.Fatalf("request before rotation: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	newCA := newTestCA(t, "ca-2")
+	newCA.serverFiles(t, dir)
+	if resp, err := newCA.client(t, alice).Get(ts.URL + "/health"); err != nil {
+		t.Fatalf("request after rotation: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if _, err := oldCA.client(t, alice).Get(ts.URL + "/health"); err == nil {
+		t.Fatalf("expected the rotated-out CA to be rejected")
+	}
+
+	// A broken rotation keeps serving the last good files.
+	if err := os.WriteFile(filepath.Join(dir, "server.key"), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := newCA.client(t, alice).Get(ts.URL + "/health"); err != nil {
+		t.Fatalf("request after a broken rotation: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	t.Setenv("OPSORCH_TLS_CLIENT_CA_FILE", "/tmp/ca.pem")
+	if _, _, err := newTLSFromEnv("", ""); err == nil || !strings.Contains(err.Error(), "requires OPSORCH_TLS_CERT_FILE") {
+		t.Fatalf("expected a missing server certificate error, got %v", err)
+	}
+	t.Setenv("OPSORCH_TLS_CLIENT_AUTH", "sometimes")
+	if _, _, err := newTLSFromEnv("cert.pem", "key.pem"); err == nil || !strings.Contains(err.Error(), "OPSORCH_TLS_CLIENT_AUTH") {
+		t.Fatalf("expected an invalid client auth error, got %v", err)
+	}
+	t.Setenv("OPSORCH_TLS_CLIENT_AUTH", "")
+	if _, _, err := newTLSFromEnv("cert.pem", "key.pem"); err == nil || !strings.Contains(err.Error(), "load TLS certificate") {
+		t.Fatalf("expected a load error, got %v", err)
+	}
+}
+
+func TestTLSClientScopesAreRequired(t *testing.T) {
+	dir := t.TempDir()
+	newTestCA(t, "root").serverFiles(t, dir)
+	t.Setenv("OPSORCH_TLS_CLIENT_CA_FILE", filepath.Join(dir, "ca.pem"))
+	for raw, want := range map[string]string{
+		"":                           "requires OPSORCH_TLS_CLIENT_SCOPES",
+		`{}`:                         "at least one scope",
+		`{"*":[],"sre":["*"]}`:       "*: at least one scope is required",
+		`{"sre":["incident:maybe"]}`: "invalid scope",
+	} {
+		t.Setenv("OPSORCH_TLS_CLIENT_SCOPES", raw)
+		if _, _, err := newTLSFromEnv(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", raw, want, err)
+		}
+	}
+}
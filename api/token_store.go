@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// tokenStore holds the API tokens accepted by the server. Only SHA-256 digests are kept.
+type tokenStore struct {
+	tokens []storedToken
+}
+
+type storedToken struct {
+	principal
+	digest    [sha256.Size]byte
+	expiresAt time.Time // zero when the token does not expire
+}
+
+// tokenFile is the JSON document read from OPSORCH_TOKENS_FILE or the OPSORCH_TOKENS_SECRET key.
+type tokenFile struct {
+	Tokens []tokenSpec `json:"tokens"`
+}
+
+// tokenSpec describes one token. Exactly one of Token (plaintext) or SHA256 (hex digest of the
+// plaintext) is set.
+type tokenSpec struct {
+	Name      string     `json:"name"`
+	Token     string     `json:"token,omitempty"`
+	SHA256    string     `json:"sha256,omitempty"`
+	ActorID   string     `json:"actorId"`
+	ActorType string     `json:"actorType,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Scopes    []string   `json:"scopes"`
+}
+
+// newTokenStoreFromEnv loads tokens from OPSORCH_TOKENS_FILE, or from the secret provider key
+// named by OPSORCH_TOKENS_SECRET. It returns nil when neither is set.
+func newTokenStoreFromEnv(sec SecretProvider) (*tokenStore, error) {
+	path := strings.TrimSpace(os.Getenv("OPSORCH_TOKENS_FILE"))
+	key := strings.TrimSpace(os.Getenv("OPSORCH_TOKENS_SECRET"))
+	switch {
+	case path != "" && key != "":
+		return nil, fmt.Errorf("set only one of OPSORCH_TOKENS_FILE and OPSORCH_TOKENS_SECRET")
+	case path != "":
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read OPSORCH_TOKENS_FILE: %w", err)
+		}
+		return parseTokenStore(raw)
+	case key != "":
+		if sec == nil {
+			return nil, fmt.Errorf("OPSORCH_TOKENS_SECRET requires a secret provider")
+		}
+		raw, err := sec.Get(rctx(), key)
+		if err != nil {
+			return nil, fmt.Errorf("read tokens from secret %s: %w", key, err)
+		}
+		return parseTokenStore([]byte(raw))
+	default:
+		return nil, nil
+	}
+}
+
+func parseTokenStore(raw []byte) (*tokenStore, error) {
+	var file tokenFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid token file: %w", err)
+	}
+	store := &tokenStore{}
+	names := make(map[string]bool)
+	for i, spec := range file.Tokens {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("token %d: name is required", i)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("token %s: duplicate name", spec.Name)
+		}
+		names[spec.Name] = true
+		if spec.ActorID == "" {
+			return nil, fmt.Errorf("token %s: actorId is required", spec.Name)
+		}
+		if len(spec.Scopes) == 0 {
+			return nil, fmt.Errorf("token %s: at least one scope is required", spec.Name)
+		}
+		for _, scope := range spec.Scopes {
+			if !validScope(scope) {
+				return nil, fmt.Errorf("token %s: invalid scope %q", spec.Name, scope)
+			}
+		}
+
+		t := storedToken{principal: principal{name: spec.Name, actorID: spec.ActorID, actorType: spec.ActorType, scopes: spec.Scopes}}
+		if t.actorType == "" {
+			t.actorType = "service"
+		}
+		switch {
+		case spec.Token != "" && spec.SHA256 != "":
+			return nil, fmt.Errorf("token %s: set only one of token and sha256", spec.Name)
+		case spec.Token != "":
+			t.digest = sha256.Sum256([]byte(spec.Token))
+		case spec.SHA256 != "":
+			digest, err := hex.DecodeString(spec.SHA256)
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("token %s: sha256 must be %d hex characters", spec.Name, 2*sha256.Size)
+			}
+			copy(t.digest[:], digest)
+		default:
+			return nil, fmt.Errorf("token %s: token or sha256 is required", spec.Name)
+		}
+		if spec.ExpiresAt != nil {
+			t.expiresAt = *spec.ExpiresAt
+		}
+		store.tokens = append(store.tokens, t)
+	}
+	return store, nil
+}
+
+// lookup returns the principal for token. Every stored digest is compared, in constant time,
+// so the time taken does not reveal which token, if any, matched.
+func (s *tokenStore) lookup(token string) (*principal, error) {
+	digest := sha256.Sum256([]byte(token))
+	var match *storedToken
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(digest[:], s.tokens[i].digest[:]) == 1 {
+			match = &s.tokens[i]
+		}
+	}
+	if match == nil {
+		return nil, errUnauthorized
+	}
+	if !match.expiresAt.IsZero() && !time.Now().Before(match.expiresAt) {
+		return nil, fmt.Errorf("token %s expired at %s", match.name, match.expiresAt.Format(time.RFC3339))
+	}
+	p := match.principal
+	return &p, nil
+}
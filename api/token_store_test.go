@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTokens = `{"tokens":[
+	{"name":"dashboard","token":"dash-secret","actorId":"grafana","scopes":["incident:read","alert:read"]},
+	{"name":"ci","sha256":"%s","actorId":"ci-bot","actorType":"bot","scopes":["ticket:write"]},
+	{"name":"admin","token":"admin-secret","actorId":"ops-admin","actorType":"user","scopes":["*"]},
+	{"name":"old","token":"old-secret","actorId":"retired","expiresAt":"2020-01-01T00:00:00Z","scopes":["*"]}
+]}`
+
+func testTokenStore(t *testing.T) *tokenStore {
+	t.Helper()
+	digest := sha256.Sum256([]byte("ci-secret"))
+	store, err := parseTokenStore([]byte(strings.Replace(testTokens, "%s", hex.EncodeToString(digest[:]), 1)))
+	if err != nil {
+		t.Fatalf("parse tokens: %v", err)
+	}
+	return store
+}
+
+func serveWithToken(srv *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("X-User-Id", "spoofed")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestTokenScopesGateRoutes(t *testing.T) {
+	srv := &Server{
+		tokens:   testTokenStore(t),
+		incident: IncidentHandler{provider: stubIncidentProvider{}},
+		ticket:   TicketHandler{provider: stubTicketProvider{}},
+	}
+	cases := []struct {
+		token, method, path string
+		want                int
+	}{
+		{"dash-secret", http.MethodGet, "/incidents/1", http.StatusOK},
+		{"dash-secret", http.MethodPost, "/incidents/query", http.StatusOK},
+		{"dash-secret", http.MethodPost, "/incidents", http.StatusForbidden},
+		{"dash-secret", http.MethodPost, "/providers/incident", http.StatusForbidden},
+		{"dash-secret", http.MethodGet, "/health", http.StatusOK},
+		{"ci-secret", http.MethodGet, "/tickets/1", http.StatusOK}, // write implies read
+		{"ci-secret", http.MethodGet, "/incidents/1", http.StatusForbidden},
+		{"admin-secret", http.MethodPost, "/incidents", http.StatusCreated},
+		{"old-secret", http.MethodGet, "/incidents/1", http.StatusUnauthorized},
+		{"wrong", http.MethodGet, "/incidents/1", http.StatusUnauthorized},
+		{"", http.MethodGet, "/health", http.StatusUnauthorized},
+		{"", http.MethodGet, "/ready", http.StatusOK}, // the readiness probe needs no credential
+	}
+	for _, tc := range cases {
+		if w := serveWithToken(srv, tc.method, tc.path, tc.token); w.Code != tc.want {
+			t.Fatalf("%s %s with %q: expected %d, got %d: %s", tc.method, tc.path, tc.token, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTokenIdentityFeedsAuditLog(t *testing.T) {
+	logs := captureLog(t)
+	srv := &Server{tokens: testTokenStore(t), incident: IncidentHandler{provider: stubIncidentProvider{}}}
+	if w := serveWithToken(srv, http.MethodGet, "/incidents/1", "dash-secret"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	out := logs.String()
+	if !strings.Contains(out, `"actor_type":"service","actor_id":"grafana"`) || !strings.Contains(out, `"credential":"dashboard"`) {
+		t.Fatalf("expected the token identity in the audit log, got %q", out)
+	}
+	if strings.Contains(out, "spoofed") {
+		t.Fatalf("audit log used the X-User-Id header: %q", out)
+	}
+}
+
+func TestLegacyBearerTokenKeepsFullAccess(t *testing.T) {
+	srv := &Server{bearerToken: "legacy", tokens: testTokenStore(t), incident: IncidentHandler{provider: stubIncidentProvider{}}}
+	if w := serveWithToken(srv, http.MethodPost, "/incidents", "legacy"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if w := serveWithToken(srv, http.MethodPost, "/incidents", "dash-secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a read-only token, got %d", w.Code)
+	}
+}
+
+func TestParseTokenStoreRejectsInvalidTokens(t *testing.T) {
+	cases := map[string]string{
+		`{"tokens":[{"token":"x","actorId":"a","scopes":["*"]}]}`:                                                                  "name is required",
+		`{"tokens":[{"name":"a","token":"x","scopes":["*"]}]}`:                                                                     "actorId is required",
+		`{"tokens":[{"name":"a","actorId":"a","scopes":["*"]}]}`:                                                                   "token or sha256 is required",
+		`{"tokens":[{"name":"a","token":"x","sha256":"00","actorId":"a","scopes":["*"]}]}`:                                         "only one of token and sha256",
+		`{"tokens":[{"name":"a","sha256":"abc","actorId":"a","scopes":["*"]}]}`:                                                    "hex characters",
+		`{"tokens":[{"name":"a","token":"x","actorId":"a","scopes":["incident:delete"]}]}`:                                         "invalid scope",
+		`{"tokens":[{"name":"a","token":"x","actorId":"a","scopes":["widgets:read"]}]}`:                                            "invalid scope",
+		`{"tokens":[{"name":"a","token":"x","actorId":"a"}]}`:                                                                      "at least one scope",
+		`{"tokens":[{"name":"a","token":"x","actorId":"a","scopes":["*"]},{"name":"a","token":"y","actorId":"b","scopes":["*"]}]}`: "duplicate name",
+	}
+	for raw, want := range cases {
+		if _, err := parseTokenStore([]byte(raw)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", raw, want, err)
+		}
+	}
+}
+
+func TestTokenStoreFromFileAndSecret(t *testing.T) {
+	raw := `{"tokens":[{"name":"a","token":"file-secret","actorId":"a","scopes":["*"],"expiresAt":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}]}`
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPSORCH_TOKENS_FILE", path)
+	store, err := newTokenStoreFromEnv(nil)
+	if err != nil {
+		t.Fatalf("load from file: %v", err)
+	}
+	if p, err := store.lookup("file-secret"); err != nil || p.actorID != "a" {
+		t.Fatalf("lookup: %+v %v", p, err)
+	}
+
+	t.Setenv("OPSORCH_TOKENS_FILE", "")
+	t.Setenv("OPSORCH_TOKENS_SECRET", "auth/tokens")
+	sec := &memorySecret{store: map[string]string{"auth/tokens": raw}}
+	if store, err = newTokenStoreFromEnv(sec); err != nil || len(store.tokens) != 1 {
+		t.Fatalf("load from secret: %v", err)
+	}
+	if _, err := newTokenStoreFromEnv(nil); err == nil {
+		t.Fatalf("expected an error without a secret provider")
+	}
+}
@@ -0,0 +1,496 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOTLPTracesEndpoint = "http://localhost:4318/v1/traces"
+	// otlpBatchSize and otlpFlushInterval bound how long a finished span waits before export.
+	otlpBatchSize     = 512
+	otlpFlushInterval = 5 * time.Second
+	// otlpMaxQueue bounds the spans waiting for export while the collector is slow or down;
+	// spans finished beyond it are dropped.
+	otlpMaxQueue      = 4 * otlpBatchSize
+	otlpExportTimeout = 10 * time.Second
+	// traceFlushTimeout bounds how long Shutdown waits for the last spans to be exported.
+	traceFlushTimeout = 5 * time.Second
+)
+
+const (
+	spanKindServer = "server"
+	spanKindClient = "client"
+)
+
+// tracer records a server span for every request and a client span for every provider call,
+// and hands finished spans to its exporter. Trace context follows the W3C traceparent format,
+// both on incoming requests and on the RPC frames sent to plugins.
+type tracer struct {
+	service  string
+	exporter spanExporter
+}
+
+// spanExporter receives every sampled span once it has finished.
+type spanExporter interface {
+	export(s *span)
+	shutdown(ctx context.Context) error
+}
+
+// newTracerFromEnv reads OPSORCH_TRACES_EXPORTER (otlp or stdout). It returns nil when the
+// variable is unset or none, which turns tracing off.
+func newTracerFromEnv() (*tracer, error) {
+	service := envOr("OPSORCH_SERVICE_NAME", "opsorch-core")
+	switch exporter := strings.TrimSpace(os.Getenv("OPSORCH_TRACES_EXPORTER")); exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return &tracer{service: service, exporter: &stdoutSpanExporter{w: os.Stdout}}, nil
+	case "otlp":
+		headers, err := parseOTLPHeaders(os.Getenv("OPSORCH_OTLP_HEADERS"))
+		if err != nil {
+			return nil, err
+		}
+		endpoint := envOr("OPSORCH_OTLP_TRACES_ENDPOINT", defaultOTLPTracesEndpoint)
+		return &tracer{service: service, exporter: newOTLPSpanExporter(endpoint, service, headers)}, nil
+	default:
+		return nil, fmt.Errorf("OPSORCH_TRACES_EXPORTER must be otlp, stdout, or none, got %q", exporter)
+	}
+}
+
+// parseOTLPHeaders reads "key=value,key=value", the format OTEL_EXPORTER_OTLP_HEADERS uses.
+func parseOTLPHeaders(raw string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("OPSORCH_OTLP_HEADERS entry %q must be key=value", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// shutdown exports any spans still buffered.
+func (t *tracer) shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.shutdown(ctx)
+}
+
+// Trace context ----------------------------------------------------------------
+
+// traceContext identifies a span within a trace.
+type traceContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// parseTraceparent reads a W3C traceparent header: version-traceid-spanid-flags.
+func parseTraceparent(header string) (traceContext, bool) {
+	var tc traceContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return tc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || err != nil || len(flags) != 1 {
+		return tc, false
+	}
+	if _, err := hex.Decode(tc.traceID[:], []byte(parts[1])); err != nil {
+		return tc, false
+	}
+	if _, err := hex.Decode(tc.spanID[:], []byte(parts[2])); err != nil {
+		return tc, false
+	}
+	if tc.traceID == ([16]byte{}) || tc.spanID == ([8]byte{}) {
+		return tc, false
+	}
+	tc.sampled = flags[0]&1 == 1
+	return tc, true
+}
+
+func (tc traceContext) traceparent() string {
+	flags := "00"
+	if tc.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(tc.traceID[:]) + "-" + hex.EncodeToString(tc.spanID[:]) + "-" + flags
+}
+
+// Spans ------------------------------------------------------------------------
+
+// span is one timed operation. Its methods are safe on a nil span, which is what callers
+// get when tracing is off.
+type span struct {
+	tracer *tracer
+	name   string
+	kind   string
+	ctx    traceContext
+	parent [8]byte // zero for a root span
+	start  time.Time
+
+	mu            sync.Mutex
+	end           time.Time
+	attrs         map[string]string
+	failed        bool
+	statusMessage string
+}
+
+type spanKey struct{}
+
+func spanFrom(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+// traceparentFrom returns the traceparent header for the span running in ctx, or "" when
+// the request is not traced.
+func traceparentFrom(ctx context.Context) string {
+	if s := spanFrom(ctx); s != nil {
+		return s.ctx.traceparent()
+	}
+	return ""
+}
+
+// startServerSpan starts the span for an incoming request. It continues the caller's trace
+// when the request carries a valid traceparent header.
+func (t *tracer) startServerSpan(ctx context.Context, r *http.Request) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &span{tracer: t, name: r.Method, kind: spanKindServer, start: time.Now(), attrs: map[string]string{}}
+	if parent, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		s.ctx.traceID, s.parent, s.ctx.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else {
+		_, _ = rand.Read(s.ctx.traceID[:])
+		s.ctx.sampled = true
+	}
+	_, _ = rand.Read(s.ctx.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// startSpan starts a child of the span in ctx. It returns a nil span when ctx has none.
+func startSpan(ctx context.Context, name, kind string) (context.Context, *span) {
+	parent := spanFrom(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := &span{tracer: parent.tracer, name: name, kind: kind, parent: parent.ctx.spanID, start: time.Now(), attrs: map[string]string{}}
+	s.ctx.traceID, s.ctx.sampled = parent.ctx.traceID, parent.ctx.sampled
+	_, _ = rand.Read(s.ctx.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) setName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *span) setAttr(key, value string) {
+	if s == nil || value == "" {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// setError marks the span as failed.
+func (s *span) setError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed, s.statusMessage = true, message
+	s.mu.Unlock()
+}
+
+// finish ends the span and exports it when its trace is sampled.
+func (s *span) finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.ctx.sampled {
+		s.tracer.exporter.export(s)
+	}
+}
+
+// spanRecord is the stdout form of a finished span.
+type spanRecord struct {
+	TraceID       string            `json:"traceId"`
+	SpanID        string            `json:"spanId"`
+	ParentSpanID  string            `json:"parentSpanId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Status        string            `json:"status"` // ok or error
+	StatusMessage string            `json:"statusMessage,omitempty"`
+}
+
+func (s *span) record() spanRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := spanRecord{
+		TraceID:       hex.EncodeToString(s.ctx.traceID[:]),
+		SpanID:        hex.EncodeToString(s.ctx.spanID[:]),
+		Name:          s.name,
+		Kind:          s.kind,
+		Start:         s.start,
+		End:           s.end,
+		Attributes:    maps.Clone(s.attrs),
+		Status:        "ok",
+		StatusMessage: s.statusMessage,
+	}
+	if s.parent != ([8]byte{}) {
+		rec.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	if s.failed {
+		rec.Status = "error"
+	}
+	return rec
+}
+
+// Exporters --------------------------------------------------------------------
+
+// stdoutSpanExporter writes each span as one JSON line.
+type stdoutSpanExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *stdoutSpanExporter) export(s *span) {
+	line, err := json.Marshal(s.record())
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+func (e *stdoutSpanExporter) shutdown(context.Context) error { return nil }
+
+// memorySpanExporter keeps finished spans for tests.
+type memorySpanExporter struct {
+	mu    sync.Mutex
+	spans []spanRecord
+}
+
+func (e *memorySpanExporter) export(s *span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s.record())
+	e.mu.Unlock()
+}
+
+func (e *memorySpanExporter) shutdown(context.Context) error { return nil }
+
+func (e *memorySpanExporter) finished() []spanRecord {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.spans)
+}
+
+// otlpSpanExporter posts batches of spans to an OTLP/HTTP collector, JSON encoded. A batch is
+// sent when it fills or otlpFlushInterval passes; a failed export is logged and dropped. At most
+// otlpMaxQueue spans wait, and the number dropped beyond that is logged with the next flush.
+type otlpSpanExporter struct {
+	endpoint string
+	service  string
+	headers  map[string]string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []*span
+	dropped int           // spans dropped since the last flush because the queue was full
+	full    chan struct{} // wakes the export loop when a batch fills
+	flushMu sync.Mutex    // serializes posts so batches arrive in order
+	stop    chan struct{}
+	done    chan struct{}
+
+	stopOnce sync.Once // shutdown runs from both Shutdown and Run's final flush
+}
+
+func newOTLPSpanExporter(endpoint, service string, headers map[string]string) *otlpSpanExporter {
+	e := &otlpSpanExporter{
+		endpoint: endpoint,
+		service:  service,
+		headers:  headers,
+		client:   &http.Client{Timeout: otlpExportTimeout},
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *otlpSpanExporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush(context.Background())
+		case <-e.full:
+			e.flush(context.Background())
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+func (e *otlpSpanExporter) export(s *span) {
+	e.mu.Lock()
+	if len(e.pending) < otlpMaxQueue {
+		e.pending = append(e.pending, s)
+	} else {
+		e.dropped++
+	}
+	full := len(e.pending) >= otlpBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default: // a flush is already due
+		}
+	}
+}
+
+func (e *otlpSpanExporter) shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	<-e.done
+	return e.flush(ctx)
+}
+
+func (e *otlpSpanExporter) flush(ctx context.Context) error {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	e.mu.Lock()
+	batch, dropped := e.pending, e.dropped
+	e.pending, e.dropped = nil, 0
+	e.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("trace_export_queue_full", "dropped_spans", dropped)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := e.post(ctx, batch); err != nil {
+		slog.Error("trace_export_error", "dropped_spans", len(batch), "err", err)
+		return err
+	}
+	return nil
+}
+
+func (e *otlpSpanExporter) post(ctx context.Context, batch []*span) error {
+	body, err := json.Marshal(otlpTraceRequest(e.service, batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpTraceRequest builds an ExportTraceServiceRequest in the OTLP/JSON encoding.
+func otlpTraceRequest(service string, batch []*span) map[string]any {
+	spans := make([]map[string]any, 0, len(batch))
+	for _, s := range batch {
+		rec := s.record()
+		out := map[string]any{
+			"traceId":           rec.TraceID,
+			"spanId":            rec.SpanID,
+			"name":              rec.Name,
+			"kind":              otlpSpanKind(rec.Kind),
+			"startTimeUnixNano": strconv.FormatInt(rec.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(rec.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(rec.Attributes),
+			"status":            map[string]any{"code": 1},
+		}
+		if rec.ParentSpanID != "" {
+			out["parentSpanId"] = rec.ParentSpanID
+		}
+		if rec.Status == "error" {
+			out["status"] = map[string]any{"code": 2, "message": rec.StatusMessage}
+		}
+		spans = append(spans, out)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes(map[string]string{"service.name": service})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/opsorch/opsorch-core/api"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func otlpSpanKind(kind string) int {
+	switch kind {
+	case spanKindServer:
+		return 2
+	case spanKindClient:
+		return 3
+	default:
+		return 1 // internal
+	}
+}
+
+func otlpAttributes(attrs map[string]string) []any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	out := make([]any, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, map[string]any{"key": k, "value": map[string]any{"stringValue": attrs[k]}})
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func tracedServer() (*Server, *memorySpanExporter) {
+	spans := &memorySpanExporter{}
+	return &Server{
+		tracer:   &tracer{service: "opsorch-core", exporter: spans},
+		incident: IncidentHandler{provider: observeIncident("stub", missingIncidentProvider{})},
+		log:      LogHandler{provider: observeLog("stub", stubLogProvider{})},
+	}, spans
+}
+
+func TestTracingRecordsServerAndProviderSpans(t *testing.T) {
+	srv, spans := tracedServer()
+	req := httptest.NewRequest(http.MethodPost, "/logs/query", strings.NewReader(`{}`))
+	req.Header.Set("traceparent", testTraceparent)
+	req.Header.Set("X-Request-ID", "req-7")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans.finished()
+	if len(got) != 2 {
+		t.Fatalf("expected a provider span and a server span, got %+v", got)
+	}
+	provider, server := got[0], got[1]
+	if server.Name != "POST /logs/query" || server.Kind != spanKindServer || server.ParentSpanID != "00f067aa0ba902b7" || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected server span %+v", server)
+	}
+	for key, want := range map[string]string{"http.route": "/logs/query", "http.response.status_code": "200", "opsorch.action": "log.query", "opsorch.request_id": "req-7"} {
+		if server.Attributes[key] != want {
+			t.Fatalf("expected server attribute %s=%s, got %+v", key, want, server.Attributes)
+		}
+	}
+	if provider.Name != "log.query" || provider.Kind != spanKindClient || provider.ParentSpanID != server.SpanID || provider.TraceID != server.TraceID || provider.Attributes["opsorch.provider"] != "stub" {
+		t.Fatalf("unexpected provider span %+v", provider)
+	}
+}
+
+func TestTracingMarksFailedProviderCalls(t *testing.T) {
+	srv, spans := tracedServer()
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/incidents/INC-9", nil))
+
+	got := spans.finished()
+	if len(got) != 2 {
+		t.Fatalf("expected two spans, got %+v", got)
+	}
+	if got[0].Status != "error" || got[0].Attributes["opsorch.error_code"] != "not_found" || !strings.Contains(got[0].StatusMessage, "INC-9 not found") {
+		t.Fatalf("expected a failed provider span, got %+v", got[0])
+	}
+	// A 404 is the caller's problem, not the server's.
+	if got[1].Status != "ok" || got[1].ParentSpanID != "" || got[1].Name != "GET /incidents/{id}" {
+		t.Fatalf("expected a successful root server span, got %+v", got[1])
+	}
+}
+
+func TestTracingHonorsUnsampledParent(t *testing.T) {
+	srv, spans := tracedServer()
+	req := httptest.NewRequest(http.MethodGet, "/incidents/INC-1", nil)
+	req.Header.Set("traceparent", strings.TrimSuffix(testTraceparent, "01")+"00")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	if got := spans.finished(); len(got) != 0 {
+		t.Fatalf("expected no exported spans, got %+v", got)
+	}
+}
+
+func TestTracingForwardsTraceparentToPlugins(t *testing.T) {
+	captureLog(t)
+	// Answers every call with the traceparent of its request frame.
+	script := writePluginScript(t, shellIDHelpers+`read -r handshake
+echo "{\"id\":$(id_of "$handshake"),\"result\":{\"protocolVersion\":\"1.0\",\"capability\":\"incident\"}}"
+while read -r line; do
+  echo "{\"id\":$(id_of "$line"),\"result\":\"$(echo "$line" | sed -n 's/.*"traceparent":"\([^"]*\)".*/\1/p')\"}"
+done
+`)
+	runner := newPluginRunner("incident", script, nil, pluginOptions{})
+	defer runner.close()
+
+	spans := &memorySpanExporter{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, server := (&tracer{exporter: spans}).startServerSpan(context.Background(), req)
+	ctx, call := startSpan(ctx, "incident.get", spanKindClient)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var got string
+	if err := runner.call(ctx, "incident.get", nil, &got); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got != call.ctx.traceparent() || call.ctx.traceID != server.ctx.traceID {
+		t.Fatalf("expected the provider span's traceparent %s, got %q", call.ctx.traceparent(), got)
+	}
+	if err := runner.call(context.Background(), "incident.get", nil, &got); err != nil || got != "" {
+		t.Fatalf("expected untraced calls to omit traceparent, got %q (err=%v)", got, err)
+	}
+}
+
+func TestOTLPExporterPostsBatches(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("unexpected export request %s %v", r.URL.Path, r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	exporter := newOTLPSpanExporter(collector.URL+"/v1/traces", "core-test", map[string]string{"Authorization": "Bearer k"})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	_, s := (&tracer{exporter: exporter}).startServerSpan(context.Background(), req)
+	s.setAttr("http.route", "/incidents/{id}")
+	s.setError("boom")
+	s.finish()
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	var payload struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []map[string]any `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	rs := payload.ResourceSpans[0]
+	if rs.Resource.Attributes[0]["key"] != "service.name" {
+		t.Fatalf("expected service.name on the resource, got %+v", rs.Resource)
+	}
+	got := rs.ScopeSpans[0].Spans[0]
+	if got["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || got["parentSpanId"] != "00f067aa0ba902b7" || got["kind"] != float64(2) {
+		t.Fatalf("unexpected span %+v", got)
+	}
+	if status := got["status"].(map[string]any); status["code"] != float64(2) || status["message"] != "boom" {
+		t.Fatalf("expected an error status, got %+v", status)
+	}
+}
+
+func TestOTLPExporterBoundsItsQueue(t *testing.T) {
+	logs := captureLog(t)
+	posted := make(chan struct{}, 8)
+	release := make(chan struct{})
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- struct{}{}
+		<-release
+	}))
+	defer collector.Close()
+
+	exporter := newOTLPSpanExporter(collector.URL, "core-test", nil)
+	tr := &tracer{exporter: exporter}
+	finishSpans := func(n int) {
+		for i := 0; i < n; i++ {
+			_, s := tr.startServerSpan(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+			s.finish()
+		}
+	}
+	// A full batch is sent without waiting for the flush interval.
+	finishSpans(otlpBatchSize)
+	select {
+	case <-posted:
+	case <-time.After(otlpFlushInterval / 2):
+		t.Fatalf("expected a full batch to be flushed right away")
+	}
+
+	// While the collector is stuck, spans queue up to the limit and the rest are dropped.
+	finishSpans(otlpMaxQueue + 10)
+	exporter.mu.Lock()
+	queued, dropped := len(exporter.pending), exporter.dropped
+	exporter.mu.Unlock()
+	if queued != otlpMaxQueue || dropped != 10 {
+		t.Fatalf("expected %d queued and 10 dropped spans, got %d and %d", otlpMaxQueue, queued, dropped)
+	}
+
+	close(release)
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if !strings.Contains(logs.String(), `"msg":"trace_export_queue_full","dropped_spans":10`) {
+		t.Fatalf("expected the dropped spans to be logged, got %q", logs.String())
+	}
+}
+
+func TestStdoutExporterWritesJSONLines(t *testing.T) {
+	var out bytes.Buffer
+	tr := &tracer{exporter: &stdoutSpanExporter{w: &out}}
+	_, s := tr.startServerSpan(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+	s.finish()
+	var rec spanRecord
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil || rec.Kind != spanKindServer || len(rec.TraceID) != 32 || !strings.HasSuffix(out.String(), "\n") {
+		t.Fatalf("unexpected stdout span %q (err=%v)", out.String(), err)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tc, ok := parseTraceparent(testTraceparent)
+	if !ok || !tc.sampled || tc.traceparent() != testTraceparent {
+		t.Fatalf("expected %s to round-trip, got %+v", testTraceparent, tc)
+	}
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-xyz92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := parseTraceparent(header); ok {
+			t.Fatalf("expected %q to be rejected", header)
+		}
+	}
+}
+
+func TestNewTracerFromEnv(t *testing.T) {
+	t.Setenv("OPSORCH_TRACES_EXPORTER", "")
+	if tr, err := newTracerFromEnv(); tr != nil || err != nil {
+		t.Fatalf("expected tracing to be off by default, got %v %v", tr, err)
+	}
+	t.Setenv("OPSORCH_TRACES_EXPORTER", "jaeger")
+	if _, err := newTracerFromEnv(); err == nil {
+		t.Fatalf("expected an unknown exporter to be rejected")
+	}
+	t.Setenv("OPSORCH_TRACES_EXPORTER", "otlp")
+	t.Setenv("OPSORCH_OTLP_HEADERS", "nokey")
+	if _, err := newTracerFromEnv(); err == nil {
+		t.Fatalf("expected malformed headers to be rejected")
+	}
+}
+
+func TestOTLPExporterShutdownTwice(t *testing.T) {
+	exporter := newOTLPSpanExporter("http://127.0.0.1:0/v1/traces", "opsorch", nil)
+	// Shutdown and Run's deferred flush can both reach the exporter, possibly at once.
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- exporter.shutdown(context.Background()) }()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	}
+	if err := exporter.shutdown(context.Background()); err != nil {
+		t.Fatalf("third shutdown: %v", err)
+	}
+}
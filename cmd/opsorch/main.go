@@ -2,18 +2,36 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/opsorch/opsorch-core/api"
 )
 
 func main() {
-	ctx := context.Background()
+	if len(os.Args) > 1 && os.Args[1] == "plugin" {
+		// Plugin commands log plugin handshakes and stderr; keep them out of the report. The
+		// default slog handler writes through the log package.
+		log.SetOutput(io.Discard)
+		os.Exit(runPlugin(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore default signal handling so a second signal ends a stuck drain at once.
+		<-ctx.Done()
+		stop()
+	}()
 
 	srv, err := api.NewServerFromEnv(ctx)
 	if err != nil {
-		log.Fatalf("failed to init server: %v", err)
+		slog.Error("failed to init server", "err", err)
+		os.Exit(1)
 	}
 
 	addr := os.Getenv("OPSORCH_ADDR")
@@ -21,8 +39,10 @@ func main() {
 		addr = ":8080"
 	}
 
-	log.Printf("opsorch core api listening on %s", addr)
-	if err := srv.ListenAndServe(addr); err != nil {
-		log.Fatalf("server exited: %v", err)
+	slog.Info("opsorch core api listening", "addr", addr)
+	if err := srv.Run(ctx, addr); err != nil {
+		slog.Error("server exited", "err", err)
+		os.Exit(1)
 	}
+	slog.Info("opsorch core api stopped")
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/api"
+)
+
+// runPlugin implements "opsorch plugin <subcommand>" and returns the process exit code.
+func runPlugin(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(stderr, "usage: opsorch plugin verify --capability <cap> --plugin <path|unix://...|http://...> [flags]")
+		return 2
+	}
+	return runPluginVerify(args[1:], stdout, stderr)
+}
+
+// runPluginVerify runs the conformance suite for one plugin and prints the report. It exits 1
+// when any check fails, so it can gate an adapter's CI.
+func runPluginVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("opsorch plugin verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	capability := fs.String("capability", "", "capability the plugin serves, e.g. incident")
+	plugin := fs.String("plugin", "", "plugin binary path, unix:// socket, or http(s):// endpoint")
+	config := fs.String("config", "", "JSON config sent to the plugin (default: $OPSORCH_<CAP>_CONFIG)")
+	readOnly := fs.Bool("read-only", false, "skip checks that create or change data")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for each plugin call")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *capability == "" || *plugin == "" {
+		fmt.Fprintln(stderr, "opsorch plugin verify: --capability and --plugin are required")
+		fs.Usage()
+		return 2
+	}
+
+	raw := *config
+	if raw == "" {
+		raw = os.Getenv(fmt.Sprintf("OPSORCH_%s_CONFIG", strings.ToUpper(*capability)))
+	}
+	cfg := map[string]any{}
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			fmt.Fprintf(stderr, "opsorch plugin verify: invalid config: %v\n", err)
+			return 2
+		}
+	}
+
+	report, err := api.VerifyPlugin(context.Background(), *capability, *plugin, api.PluginVerifyOptions{Config: cfg, ReadOnly: *readOnly, Timeout: *timeout})
+	if err != nil {
+		fmt.Fprintf(stderr, "opsorch plugin verify: %v\n", err)
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		_ = report.WriteText(stdout)
+	}
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
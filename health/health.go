@@ -0,0 +1,15 @@
+// Package health defines the optional check a capability provider implements so OpsOrch can
+// report whether its backend is reachable.
+package health
+
+import "context"
+
+// Pinger is implemented by providers that can cheaply check their backend, for example by
+// calling an authenticated "whoami" endpoint. OpsOrch calls Ping for GET /health/providers
+// with a short deadline and caches the result, so Ping should not page through data.
+//
+// Plugins built with pluginsdk get the check for free: when the provider handed to
+// pluginsdk.Serve implements Pinger, the plugin declares plugin.ping and core calls it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
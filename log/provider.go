@@ -12,6 +12,15 @@ type Provider interface {
 	Query(ctx context.Context, query schema.LogQuery) (schema.LogEntries, error)
 }
 
+// StreamingProvider is implemented by log adapters that can deliver large results in batches.
+// QueryStream calls send once per batch, in order; the entries of all batches together form
+// the result, and the last non-empty URL wins. OpsOrch forwards each batch to the client as it
+// arrives instead of buffering the whole result.
+type StreamingProvider interface {
+	Provider
+	QueryStream(ctx context.Context, query schema.LogQuery, send func(schema.LogEntries) error) error
+}
+
 // ProviderConstructor builds a log provider from decrypted configuration.
 type ProviderConstructor func(config map[string]any) (Provider, error)
 
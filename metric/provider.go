@@ -13,6 +13,14 @@ type Provider interface {
 	Describe(ctx context.Context, scope schema.QueryScope) ([]schema.MetricDescriptor, error)
 }
 
+// StreamingProvider is implemented by metric adapters that can deliver large results in
+// batches. QueryStream calls send once per batch of series, in order; OpsOrch forwards each
+// batch to the client as it arrives instead of buffering the whole result.
+type StreamingProvider interface {
+	Provider
+	QueryStream(ctx context.Context, query schema.MetricQuery, send func([]schema.MetricSeries) error) error
+}
+
 // ProviderConstructor builds a metric provider from decrypted configuration.
 type ProviderConstructor func(config map[string]any) (Provider, error)
 
@@ -1,184 +1,93 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"log"
+	"sync"
 	"time"
-)
 
-type rpcRequest struct {
-	Method  string          `json:"method"`
-	Config  map[string]any  `json:"config"`
-	Payload json.RawMessage `json:"payload"`
-}
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
+	"github.com/opsorch/opsorch-core/schema"
+)
 
-type rpcResponse struct {
-	Result any    `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+// provider is an in-memory incident.Provider used to exercise the plugin path.
+type provider struct {
+	mu        sync.Mutex
+	incidents []schema.Incident
 }
 
-type Incident struct {
-	ID        string         `json:"id"`
-	Title     string         `json:"title"`
-	Status    string         `json:"status"`
-	Severity  string         `json:"severity"`
-	Service   string         `json:"service,omitempty"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	Fields    map[string]any `json:"fields,omitempty"`
-	Metadata  map[string]any `json:"metadata,omitempty"`
+func (p *provider) Query(ctx context.Context, query schema.IncidentQuery) ([]schema.Incident, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := append([]schema.Incident(nil), p.incidents...)
+	if query.Limit > 0 && query.Limit < len(res) {
+		res = res[:query.Limit]
+	}
+	return res, nil
 }
 
-type CreateIncidentInput struct {
-	Title    string         `json:"title"`
-	Status   string         `json:"status"`
-	Severity string         `json:"severity"`
-	Service  string         `json:"service,omitempty"`
-	Fields   map[string]any `json:"fields,omitempty"`
-	Metadata map[string]any `json:"metadata,omitempty"`
+func (p *provider) Get(ctx context.Context, id string) (schema.Incident, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, inc := range p.incidents {
+		if inc.ID == id {
+			return inc, nil
+		}
+	}
+	return schema.Incident{}, orcherr.New("not_found", fmt.Sprintf("incident %s not found", id), nil)
 }
 
-type UpdateIncidentInput struct {
-	Title    *string        `json:"title,omitempty"`
-	Status   *string        `json:"status,omitempty"`
-	Severity *string        `json:"severity,omitempty"`
-	Service  *string        `json:"service,omitempty"`
-	Fields   map[string]any `json:"fields,omitempty"`
-	Metadata map[string]any `json:"metadata,omitempty"`
+func (p *provider) Create(ctx context.Context, in schema.CreateIncidentInput) (schema.Incident, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	inc := schema.Incident{ID: fmt.Sprintf("p%d", len(p.incidents)+1), Title: in.Title, Status: in.Status, Severity: in.Severity, Service: in.Service, CreatedAt: now, UpdatedAt: now}
+	p.incidents = append(p.incidents, inc)
+	return inc, nil
 }
 
-type TimelineEntry struct {
-	ID         string         `json:"id"`
-	IncidentID string         `json:"incidentId"`
-	At         time.Time      `json:"at"`
-	Kind       string         `json:"kind"`
-	Body       string         `json:"body"`
-	Actor      map[string]any `json:"actor,omitempty"`
-	Metadata   map[string]any `json:"metadata,omitempty"`
+func (p *provider) Update(ctx context.Context, id string, in schema.UpdateIncidentInput) (schema.Incident, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, inc := range p.incidents {
+		if inc.ID != id {
+			continue
+		}
+		if in.Title != nil {
+			inc.Title = *in.Title
+		}
+		if in.Status != nil {
+			inc.Status = *in.Status
+		}
+		if in.Severity != nil {
+			inc.Severity = *in.Severity
+		}
+		if in.Service != nil {
+			inc.Service = *in.Service
+		}
+		inc.UpdatedAt = time.Now()
+		p.incidents[i] = inc
+		return inc, nil
+	}
+	return schema.Incident{}, orcherr.New("not_found", fmt.Sprintf("incident %s not found", id), nil)
 }
 
-type TimelineAppendInput struct {
-	At       time.Time      `json:"at"`
-	Kind     string         `json:"kind"`
-	Body     string         `json:"body"`
-	Actor    map[string]any `json:"actor,omitempty"`
-	Metadata map[string]any `json:"metadata,omitempty"`
+func (p *provider) GetTimeline(ctx context.Context, id string) ([]schema.TimelineEntry, error) {
+	return []schema.TimelineEntry{{ID: "t1", IncidentID: id, At: time.Now(), Kind: "note", Body: "from plugin"}}, nil
 }
 
-type IncidentQuery struct {
-	Query      string         `json:"query,omitempty"`
-	Statuses   []string       `json:"statuses,omitempty"`
-	Severities []string       `json:"severities,omitempty"`
-	Scope      map[string]any `json:"scope,omitempty"`
-	Limit      int            `json:"limit,omitempty"`
-	Metadata   map[string]any `json:"metadata,omitempty"`
+func (p *provider) AppendTimeline(ctx context.Context, id string, entry schema.TimelineAppendInput) error {
+	return nil
 }
 
-var incidents = []Incident{{ID: "p1", Title: "plugin incident", Status: "open", Severity: "sev2", Service: "svc-plugin", CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+func (p *provider) PluginInfo() (string, string) { return "incidentmock", "dev" }
 
 func main() {
-	dec := json.NewDecoder(os.Stdin)
-	for {
-		var req rpcRequest
-		if err := dec.Decode(&req); err != nil {
-			if err.Error() == "EOF" {
-				return
-			}
-			writeErr(err)
-			return
-		}
-
-		switch req.Method {
-		case "incident.query":
-			var query IncidentQuery
-			if err := json.Unmarshal(req.Payload, &query); err != nil {
-				writeErr(err)
-				continue
-			}
-			res := incidents
-			if query.Limit > 0 && query.Limit < len(res) {
-				res = res[:query.Limit]
-			}
-			writeOK(res)
-		case "incident.get":
-			var payload struct {
-				ID string `json:"id"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				writeErr(err)
-				continue
-			}
-			found := false
-			for _, inc := range incidents {
-				if inc.ID == payload.ID {
-					writeOK(inc)
-					found = true
-					break
-				}
-			}
-			if !found {
-				writeErr(fmt.Errorf("not found"))
-			}
-		case "incident.create":
-			var in CreateIncidentInput
-			if err := json.Unmarshal(req.Payload, &in); err != nil {
-				writeErr(err)
-				continue
-			}
-			newInc := Incident{ID: fmt.Sprintf("p%d", len(incidents)+1), Title: in.Title, Status: in.Status, Severity: in.Severity, Service: in.Service, CreatedAt: time.Now(), UpdatedAt: time.Now()}
-			incidents = append(incidents, newInc)
-			writeOK(newInc)
-		case "incident.update":
-			var payload struct {
-				ID    string              `json:"id"`
-				Input UpdateIncidentInput `json:"input"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				writeErr(err)
-				continue
-			}
-			updated := false
-			for i, inc := range incidents {
-				if inc.ID == payload.ID {
-					if payload.Input.Title != nil {
-						inc.Title = *payload.Input.Title
-					}
-					if payload.Input.Status != nil {
-						inc.Status = *payload.Input.Status
-					}
-					if payload.Input.Severity != nil {
-						inc.Severity = *payload.Input.Severity
-					}
-					if payload.Input.Service != nil {
-						inc.Service = *payload.Input.Service
-					}
-					inc.UpdatedAt = time.Now()
-					incidents[i] = inc
-					writeOK(inc)
-					updated = true
-					break
-				}
-			}
-			if !updated {
-				writeErr(fmt.Errorf("not found"))
-			}
-		case "incident.timeline.get":
-			writeOK([]TimelineEntry{{ID: "t1", IncidentID: "p1", At: time.Now(), Kind: "note", Body: "from plugin"}})
-		case "incident.timeline.append":
-			writeOK(map[string]string{"status": "ok"})
-		default:
-			writeErr(fmt.Errorf("unknown method %s", req.Method))
-		}
+	now := time.Now()
+	p := &provider{incidents: []schema.Incident{{ID: "p1", Title: "plugin incident", Status: "open", Severity: "sev2", Service: "svc-plugin", CreatedAt: now, UpdatedAt: now}}}
+	if err := pluginsdk.Serve(p); err != nil {
+		log.Fatal(err)
 	}
 }
-
-func writeOK(result any) {
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(rpcResponse{Result: result})
-}
-
-func writeErr(err error) {
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(rpcResponse{Error: err.Error()})
-}
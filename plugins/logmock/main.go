@@ -1,93 +1,51 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"log"
+	"net/url"
 	"time"
-)
 
-type rpcRequest struct {
-	Method  string          `json:"method"`
-	Config  map[string]any  `json:"config"`
-	Payload json.RawMessage `json:"payload"`
-}
+	"github.com/opsorch/opsorch-core/pluginsdk"
+	"github.com/opsorch/opsorch-core/schema"
+)
 
-type rpcResponse struct {
-	Result any    `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
-}
+// provider is a log.StreamingProvider that echoes the search term back as a single entry.
+type provider struct{}
 
-type LogEntries struct {
-	Entries []LogEntry `json:"entries"`
-	URL     string     `json:"url,omitempty"`
-}
-type LogEntry struct {
-	Timestamp time.Time      `json:"timestamp"`
-	Message   string         `json:"message"`
-	Severity  string         `json:"severity,omitempty"`
-	Service   string         `json:"service,omitempty"`
-	Labels    map[string]any `json:"labels,omitempty"`
-	Metadata  map[string]any `json:"metadata,omitempty"`
+func (p provider) Query(ctx context.Context, q schema.LogQuery) (schema.LogEntries, error) {
+	var res schema.LogEntries
+	err := p.QueryStream(ctx, q, func(batch schema.LogEntries) error {
+		res.Entries = append(res.Entries, batch.Entries...)
+		if batch.URL != "" {
+			res.URL = batch.URL
+		}
+		return nil
+	})
+	return res, err
 }
 
-type QueryScope struct {
-	Service     string `json:"service,omitempty"`
-	Team        string `json:"team,omitempty"`
-	Environment string `json:"environment,omitempty"`
+func (provider) QueryStream(ctx context.Context, q schema.LogQuery, send func(schema.LogEntries) error) error {
+	var search string
+	if q.Expression != nil {
+		search = q.Expression.Search
+	}
+	return send(schema.LogEntries{
+		Entries: []schema.LogEntry{{
+			Timestamp: time.Now(),
+			Message:   fmt.Sprintf("plugin log: %s", search),
+			Severity:  "info",
+			Service:   q.Scope.Service,
+		}},
+		URL: "https://logs.example.com/query?q=" + url.QueryEscape(search),
+	})
 }
 
-type LogQuery struct {
-	Query     string         `json:"query"`
-	Start     time.Time      `json:"start"`
-	End       time.Time      `json:"end"`
-	Scope     QueryScope     `json:"scope,omitempty"`
-	Limit     int            `json:"limit,omitempty"`
-	Metadata  map[string]any `json:"metadata,omitempty"`
-	Providers []string       `json:"providers,omitempty"`
-}
+func (provider) PluginInfo() (string, string) { return "logmock", "dev" }
 
 func main() {
-	dec := json.NewDecoder(os.Stdin)
-	for {
-		var req rpcRequest
-		if err := dec.Decode(&req); err != nil {
-			if err.Error() == "EOF" {
-				return
-			}
-			writeErr(err)
-			return
-		}
-
-		switch req.Method {
-		case "log.query":
-			var q LogQuery
-			if err := json.Unmarshal(req.Payload, &q); err != nil {
-				writeErr(err)
-				continue
-			}
-			res := LogEntries{
-				Entries: []LogEntry{{
-					Timestamp: time.Now(),
-					Message:   fmt.Sprintf("plugin log: %s", q.Query),
-					Severity:  "info",
-					Service:   q.Scope.Service,
-				}},
-				URL: "https://logs.example.com/query?q=" + q.Query,
-			}
-			writeOK(res)
-		default:
-			writeErr(fmt.Errorf("unknown method %s", req.Method))
-		}
+	if err := pluginsdk.Serve(provider{}); err != nil {
+		log.Fatal(err)
 	}
 }
-
-func writeOK(result any) {
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(rpcResponse{Result: result})
-}
-
-func writeErr(err error) {
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(rpcResponse{Error: err.Error()})
-}
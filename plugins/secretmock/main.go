@@ -1,66 +1,42 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
+	"log"
+	"sync"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/pluginsdk"
 )
 
-type rpcRequest struct {
-	Method  string          `json:"method"`
-	Config  map[string]any  `json:"config"`
-	Payload json.RawMessage `json:"payload"`
+// provider is an in-memory secret.Provider.
+type provider struct {
+	mu    sync.Mutex
+	store map[string]string
 }
 
-type rpcResponse struct {
-	Result any    `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+func (p *provider) Get(ctx context.Context, key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	val, ok := p.store[key]
+	if !ok {
+		return "", orcherr.New("not_found", fmt.Sprintf("%s not found", key), nil)
+	}
+	return val, nil
 }
 
-var store = map[string]string{}
-
-func main() {
-	dec := json.NewDecoder(os.Stdin)
-	enc := json.NewEncoder(os.Stdout)
+func (p *provider) Put(ctx context.Context, key, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store[key] = value
+	return nil
+}
 
-	for {
-		var req rpcRequest
-		if err := dec.Decode(&req); err != nil {
-			if err.Error() == "EOF" {
-				return
-			}
-			_ = enc.Encode(rpcResponse{Error: err.Error()})
-			return
-		}
+func (p *provider) PluginInfo() (string, string) { return "secretmock", "dev" }
 
-		switch req.Method {
-		case "secret.put":
-			var payload struct {
-				Key   string `json:"key"`
-				Value string `json:"value"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = enc.Encode(rpcResponse{Error: err.Error()})
-				continue
-			}
-			store[payload.Key] = payload.Value
-			_ = enc.Encode(rpcResponse{Result: map[string]string{"status": "ok"}})
-		case "secret.get":
-			var payload struct {
-				Key string `json:"key"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				_ = enc.Encode(rpcResponse{Error: err.Error()})
-				continue
-			}
-			val, ok := store[payload.Key]
-			if !ok {
-				_ = enc.Encode(rpcResponse{Error: fmt.Sprintf("%s not found", payload.Key)})
-				continue
-			}
-			_ = enc.Encode(rpcResponse{Result: val})
-		default:
-			_ = enc.Encode(rpcResponse{Error: fmt.Sprintf("unknown method %s", req.Method)})
-		}
+func main() {
+	if err := pluginsdk.Serve(&provider{store: map[string]string{}}); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,245 @@
+package pluginsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/opsorch/opsorch-core/alert"
+	"github.com/opsorch/opsorch-core/deployment"
+	"github.com/opsorch/opsorch-core/incident"
+	"github.com/opsorch/opsorch-core/log"
+	"github.com/opsorch/opsorch-core/messaging"
+	"github.com/opsorch/opsorch-core/metric"
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/orchestration"
+	"github.com/opsorch/opsorch-core/schema"
+	"github.com/opsorch/opsorch-core/secret"
+	"github.com/opsorch/opsorch-core/service"
+	"github.com/opsorch/opsorch-core/team"
+	"github.com/opsorch/opsorch-core/ticket"
+)
+
+// handler decodes a method's payload, calls the provider, and returns the result to encode.
+type handler func(ctx context.Context, payload json.RawMessage) (any, error)
+
+// streamHandler is a handler that emits its result in chunks.
+type streamHandler func(ctx context.Context, payload json.RawMessage, emit func(chunk any) error) error
+
+// Payload shapes for methods whose arguments are not a single schema type. They mirror the
+// maps built by core's plugin providers.
+type (
+	idPayload struct {
+		ID string `json:"id"`
+	}
+	incidentUpdatePayload struct {
+		ID    string                     `json:"id"`
+		Input schema.UpdateIncidentInput `json:"input"`
+	}
+	timelineAppendPayload struct {
+		ID    string                     `json:"id"`
+		Entry schema.TimelineAppendInput `json:"entry"`
+	}
+	ticketUpdatePayload struct {
+		ID    string                   `json:"id"`
+		Input schema.UpdateTicketInput `json:"input"`
+	}
+	secretGetPayload struct {
+		Key string `json:"key"`
+	}
+	secretPutPayload struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	teamMembersPayload struct {
+		TeamID string `json:"teamID"`
+	}
+	planPayload struct {
+		PlanID string `json:"planId"`
+	}
+	runPayload struct {
+		RunID string `json:"runId"`
+	}
+	completeStepPayload struct {
+		RunID  string `json:"runId"`
+		StepID string `json:"stepId"`
+		Actor  string `json:"actor"`
+		Note   string `json:"note"`
+	}
+)
+
+// ok is the result of methods that only report success.
+var ok = map[string]string{"status": "ok"}
+
+// method adapts a typed provider method to a handler.
+func method[P, R any](fn func(context.Context, P) (R, error)) handler {
+	return func(ctx context.Context, raw json.RawMessage) (any, error) {
+		var payload P
+		if err := decodePayload(raw, &payload); err != nil {
+			return nil, err
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// streamMethod adapts a provider's streaming method to a streamHandler.
+func streamMethod[P, C any](fn func(context.Context, P, func(C) error) error) streamHandler {
+	return func(ctx context.Context, raw json.RawMessage, emit func(any) error) error {
+		var payload P
+		if err := decodePayload(raw, &payload); err != nil {
+			return err
+		}
+		return fn(ctx, payload, func(chunk C) error { return emit(chunk) })
+	}
+}
+
+func decodePayload(raw json.RawMessage, payload any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, payload); err != nil {
+		return orcherr.New("bad_request", fmt.Sprintf("invalid payload: %v", err), err)
+	}
+	return nil
+}
+
+// dispatchTable maps the capability implemented by provider to its method handlers.
+func dispatchTable(provider any) (string, map[string]handler, error) {
+	switch p := provider.(type) {
+	case incident.Provider:
+		return "incident", map[string]handler{
+			"incident.query": method(p.Query),
+			"incident.get": method(func(ctx context.Context, in idPayload) (schema.Incident, error) {
+				return p.Get(ctx, in.ID)
+			}),
+			"incident.create": method(p.Create),
+			"incident.update": method(func(ctx context.Context, in incidentUpdatePayload) (schema.Incident, error) {
+				return p.Update(ctx, in.ID, in.Input)
+			}),
+			"incident.timeline.get": method(func(ctx context.Context, in idPayload) ([]schema.TimelineEntry, error) {
+				return p.GetTimeline(ctx, in.ID)
+			}),
+			"incident.timeline.append": method(func(ctx context.Context, in timelineAppendPayload) (any, error) {
+				return ok, p.AppendTimeline(ctx, in.ID, in.Entry)
+			}),
+		}, nil
+	case alert.Provider:
+		return "alert", map[string]handler{
+			"alert.query": method(p.Query),
+			"alert.get": method(func(ctx context.Context, in idPayload) (schema.Alert, error) {
+				return p.Get(ctx, in.ID)
+			}),
+		}, nil
+	case log.Provider:
+		return "log", map[string]handler{
+			"log.query": method(p.Query),
+		}, nil
+	case metric.Provider:
+		return "metric", map[string]handler{
+			"metric.query":    method(p.Query),
+			"metric.describe": method(p.Describe),
+		}, nil
+	case ticket.Provider:
+		return "ticket", map[string]handler{
+			"ticket.query": method(p.Query),
+			"ticket.get": method(func(ctx context.Context, in idPayload) (schema.Ticket, error) {
+				return p.Get(ctx, in.ID)
+			}),
+			"ticket.create": method(p.Create),
+			"ticket.update": method(func(ctx context.Context, in ticketUpdatePayload) (schema.Ticket, error) {
+				return p.Update(ctx, in.ID, in.Input)
+			}),
+		}, nil
+	case messaging.Provider:
+		return "messaging", map[string]handler{
+			"messaging.send": method(p.Send),
+		}, nil
+	case service.Provider:
+		return "service", map[string]handler{
+			"service.query": method(p.Query),
+		}, nil
+	case secret.Provider:
+		return "secret", map[string]handler{
+			"secret.get": method(func(ctx context.Context, in secretGetPayload) (string, error) {
+				return p.Get(ctx, in.Key)
+			}),
+			"secret.put": method(func(ctx context.Context, in secretPutPayload) (any, error) {
+				return ok, p.Put(ctx, in.Key, in.Value)
+			}),
+		}, nil
+	case deployment.Provider:
+		return "deployment", map[string]handler{
+			"deployment.query": method(p.Query),
+			"deployment.get": method(func(ctx context.Context, in idPayload) (schema.Deployment, error) {
+				return p.Get(ctx, in.ID)
+			}),
+		}, nil
+	case team.Provider:
+		return "team", map[string]handler{
+			"team.query": method(p.Query),
+			"team.get": method(func(ctx context.Context, in idPayload) (schema.Team, error) {
+				return p.Get(ctx, in.ID)
+			}),
+			"team.members": method(func(ctx context.Context, in teamMembersPayload) ([]schema.TeamMember, error) {
+				return p.Members(ctx, in.TeamID)
+			}),
+		}, nil
+	case orchestration.Provider:
+		return "orchestration", map[string]handler{
+			"orchestration.plans.query": method(p.QueryPlans),
+			"orchestration.plans.get": method(func(ctx context.Context, in planPayload) (*schema.OrchestrationPlan, error) {
+				return p.GetPlan(ctx, in.PlanID)
+			}),
+			"orchestration.runs.query": method(p.QueryRuns),
+			"orchestration.runs.get": method(func(ctx context.Context, in runPayload) (*schema.OrchestrationRun, error) {
+				return p.GetRun(ctx, in.RunID)
+			}),
+			"orchestration.runs.start": method(func(ctx context.Context, in planPayload) (*schema.OrchestrationRun, error) {
+				return p.StartRun(ctx, in.PlanID)
+			}),
+			"orchestration.runs.steps.complete": method(func(ctx context.Context, in completeStepPayload) (any, error) {
+				return ok, p.CompleteStep(ctx, in.RunID, in.StepID, in.Actor, in.Note)
+			}),
+		}, nil
+	default:
+		return "", nil, fmt.Errorf("pluginsdk: %T does not implement any OpsOrch capability provider interface", provider)
+	}
+}
+
+// streamTable maps the methods provider can answer in chunks to their handlers.
+func streamTable(provider any) map[string]streamHandler {
+	switch p := provider.(type) {
+	case log.StreamingProvider:
+		return map[string]streamHandler{"log.query": streamMethod(p.QueryStream)}
+	case metric.StreamingProvider:
+		return map[string]streamHandler{"metric.query": streamMethod(p.QueryStream)}
+	default:
+		return nil
+	}
+}
+
+// methodNames returns the sorted method names of a dispatch table.
+func methodNames(table map[string]handler) []string {
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call invokes method on provider with a JSON payload, exactly as a plugin serving provider
+// would answer the request frame. Core uses it to answer host capability calls with the same
+// payload and result shapes as plugin requests.
+func Call(ctx context.Context, provider any, method string, payload json.RawMessage) (any, error) {
+	capability, table, err := dispatchTable(provider)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := table[method]
+	if !ok {
+		return nil, orcherr.New("not_implemented", fmt.Sprintf("%s provider does not implement %s", capability, method), nil)
+	}
+	return h(ctx, payload)
+}
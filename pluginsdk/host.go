@@ -0,0 +1,159 @@
+package pluginsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// Host methods a plugin may call on core. Capability calls use HostCapabilityPrefix followed
+// by the capability method, e.g. "host.capability.team.get".
+const (
+	HostSecretGet        = "host.secret.get"
+	HostAuditLog         = "host.audit.log"
+	HostCapabilityPrefix = "host.capability."
+)
+
+// ErrNoHost is returned by Host methods when the provider is not served over a stream core can
+// answer on, such as NewHTTPHandler.
+var ErrNoHost = errors.New("pluginsdk: host calls are not available on this transport")
+
+// hostCall is a frame the plugin sends to core. It shares the stream with response frames and
+// is told apart by its method.
+type hostCall struct {
+	ID      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Payload any    `json:"payload"`
+}
+
+// hostReply is core's answer to a host call, read from the same stream as requests.
+type hostReply struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Host lets a provider call back into core while serving a request: read secrets through
+// core's secret provider, write audit entries, and call other capabilities. Core answers
+// under the plugin's permission policy and refuses everything the policy does not allow.
+// Obtain it with HostFrom; a Host is safe for concurrent use.
+type Host struct {
+	write  func(hostCall) error
+	nextID atomic.Uint64
+
+	mu      sync.Mutex
+	pending map[uint64]chan hostReply
+	err     error // set once the stream is closed
+}
+
+// AuditEntry is an audit record written through Host.Audit. Core stamps the time and records
+// the plugin as the actor.
+type AuditEntry struct {
+	Action    string            `json:"action"`
+	RequestID string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type hostKey struct{}
+
+// HostFrom returns the Host for the stream serving ctx's request, or nil when the transport
+// cannot carry host calls. Host methods on a nil Host return ErrNoHost.
+func HostFrom(ctx context.Context) *Host {
+	h, _ := ctx.Value(hostKey{}).(*Host)
+	return h
+}
+
+func newHost(write func(hostCall) error) *Host {
+	return &Host{write: write, pending: map[uint64]chan hostReply{}}
+}
+
+// Secret reads key through core's configured secret provider.
+func (h *Host) Secret(ctx context.Context, key string) (string, error) {
+	var value string
+	err := h.call(ctx, HostSecretGet, map[string]string{"key": key}, &value)
+	return value, err
+}
+
+// Audit writes entry to core's audit log.
+func (h *Host) Audit(ctx context.Context, entry AuditEntry) error {
+	return h.call(ctx, HostAuditLog, entry, nil)
+}
+
+// Call invokes a capability method on core, such as "team.get", with the same payload and
+// result shapes core uses for plugin requests.
+func (h *Host) Call(ctx context.Context, method string, payload, out any) error {
+	return h.call(ctx, HostCapabilityPrefix+method, payload, out)
+}
+
+func (h *Host) call(ctx context.Context, method string, payload, out any) error {
+	if h == nil {
+		return ErrNoHost
+	}
+	id := h.nextID.Add(1)
+	replies := make(chan hostReply, 1)
+	h.mu.Lock()
+	if h.err != nil {
+		err := h.err
+		h.mu.Unlock()
+		return err
+	}
+	h.pending[id] = replies
+	h.mu.Unlock()
+	defer h.forget(id)
+
+	if err := h.write(hostCall{ID: id, Method: method, Payload: payload}); err != nil {
+		return fmt.Errorf("pluginsdk: send %s: %w", method, err)
+	}
+	select {
+	case reply, ok := <-replies:
+		if !ok {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			return h.err
+		}
+		if reply.Error != nil {
+			if reply.Error.Code != "" {
+				return orcherr.New(reply.Error.Code, reply.Error.Message, nil)
+			}
+			return errors.New(reply.Error.Message)
+		}
+		if out != nil && len(reply.Result) > 0 {
+			return json.Unmarshal(reply.Result, out)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Host) forget(id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.pending, id)
+}
+
+// deliver routes a reply from core to the waiting call. Replies to abandoned calls are dropped.
+func (h *Host) deliver(reply hostReply) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if replies, ok := h.pending[reply.ID]; ok {
+		delete(h.pending, reply.ID)
+		replies <- reply
+	}
+}
+
+// close fails pending and future calls once the stream to core is gone.
+func (h *Host) close(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+	for id, replies := range h.pending {
+		close(replies)
+		delete(h.pending, id)
+	}
+}
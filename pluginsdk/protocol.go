@@ -0,0 +1,66 @@
+// Package pluginsdk lets adapter authors ship a capability provider as an OpsOrch plugin
+// binary. Implement one of the capability interfaces (incident.Provider, log.Provider, ...)
+// and hand it to Serve from main:
+//
+//	func main() {
+//		if err := pluginsdk.Serve(&myIncidentProvider{}); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// The SDK speaks OpsOrch's stdio RPC protocol: it answers the startup handshake with the
+// methods of the provider's capability, decodes payloads into the real schema types,
+// forwards config changes, and reports orcherr.OpsOrchError codes back to core. Providers
+// can call back into core through HostFrom.
+package pluginsdk
+
+import "encoding/json"
+
+const (
+	// ProtocolVersion is the plugin RPC protocol version. Core refuses plugins whose major
+	// version differs from its own.
+	ProtocolVersion = "1.0"
+	// HandshakeMethod is the first frame core sends to every plugin process.
+	HandshakeMethod = "plugin.handshake"
+	// ShutdownMethod is the last frame core sends before closing the plugin's input. The
+	// plugin answers it, finishes in-flight calls, and exits.
+	ShutdownMethod = "plugin.shutdown"
+	// PingMethod checks the plugin's backend. Core only sends it to plugins whose manifest
+	// declares it, which the SDK does for providers that implement health.Pinger.
+	PingMethod = "plugin.ping"
+)
+
+// request is one frame read from core.
+type request struct {
+	ID      uint64          `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Config  json.RawMessage `json:"config"`
+	Payload json.RawMessage `json:"payload"`
+	Stream  bool            `json:"stream,omitempty"`
+	// Traceparent is the W3C trace context of core's provider call, when core traces requests.
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// response is one frame written back to core. Streamed calls send any number of chunk frames
+// followed by one terminal frame without a chunk.
+type response struct {
+	ID     uint64 `json:"id,omitempty"`
+	Chunk  any    `json:"chunk,omitempty"`
+	Result any    `json:"result,omitempty"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+// Error is the error object of a response frame. Code is surfaced to API clients.
+type Error struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Manifest is the plugin's answer to the handshake.
+type Manifest struct {
+	ProtocolVersion string   `json:"protocolVersion"`
+	Capability      string   `json:"capability"`
+	Methods         []string `json:"methods"`
+	Name            string   `json:"name,omitempty"`
+	Version         string   `json:"version,omitempty"`
+}
@@ -0,0 +1,71 @@
+package pluginsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// ServeListener serves provider to every connection accepted from l, for plugins that run as
+// sidecars and are reached through OPSORCH_<CAP>_PLUGIN=unix:///path/to.sock. Each connection
+// speaks the same framing as stdio. ServeListener returns when l is closed.
+func ServeListener(l net.Listener, provider any) error {
+	s, err := newServer(provider)
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.serveStream(context.Background(), conn, conn); err != nil {
+				fmt.Fprintf(os.Stderr, "pluginsdk: %v\n", err)
+			}
+		}()
+	}
+}
+
+// NewHTTPHandler returns a handler that answers one request frame per POST body, for plugins
+// reached through OPSORCH_<CAP>_PLUGIN=http://host:port/path. The response body holds the
+// response frames one per line; streamed calls flush each chunk frame as it is written.
+func NewHTTPHandler(provider any) (http.Handler, error) {
+	s, err := newServer(provider)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(response{Error: &Error{Code: "bad_request", Message: fmt.Sprintf("invalid request frame: %v", err)}})
+			return
+		}
+		enc := json.NewEncoder(w)
+		rc := http.NewResponseController(w)
+		s.respond(r.Context(), req, func(resp response) error {
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+			if resp.Chunk != nil {
+				_ = rc.Flush()
+			}
+			return nil
+		})
+	}), nil
+}
@@ -0,0 +1,249 @@
+package pluginsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/opsorch/opsorch-core/health"
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// Configurable is implemented by providers that accept the config block from core's
+// OPSORCH_<CAP>_CONFIG. Configure is called before the first request and again whenever
+// core sends a different config; requests wait until it returns.
+type Configurable interface {
+	Configure(config map[string]any) error
+}
+
+// Named is implemented by providers that report a name and version in the handshake.
+type Named interface {
+	PluginInfo() (name, version string)
+}
+
+// Serve runs provider as a plugin over stdin and stdout until core closes stdin or asks the
+// plugin to shut down. Provider must implement exactly one capability interface; it may be
+// called concurrently. A provider that implements io.Closer is closed before Serve returns.
+func Serve(provider any) error {
+	err := serve(context.Background(), provider, os.Stdin, os.Stdout)
+	if c, ok := provider.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// server dispatches request frames to a provider. It is shared by every stream or HTTP
+// request served for that provider, so config changes apply to all of them.
+type server struct {
+	provider   any
+	capability string
+	table      map[string]handler
+	streams    map[string]streamHandler
+
+	configMu   sync.RWMutex
+	lastConfig []byte
+	configErr  error
+}
+
+func newServer(provider any) (*server, error) {
+	capability, table, err := dispatchTable(provider)
+	if err != nil {
+		return nil, err
+	}
+	if pinger, isPinger := provider.(health.Pinger); isPinger {
+		table[PingMethod] = method(func(ctx context.Context, _ struct{}) (any, error) {
+			return ok, pinger.Ping(ctx)
+		})
+	}
+	return &server{provider: provider, capability: capability, table: table, streams: streamTable(provider)}, nil
+}
+
+func serve(ctx context.Context, provider any, in io.Reader, out io.Writer) error {
+	s, err := newServer(provider)
+	if err != nil {
+		return err
+	}
+	return s.serveStream(ctx, in, out)
+}
+
+// inbound is a frame read from core: a request, or a reply to a host call, which has no method.
+type inbound struct {
+	request
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// serveStream answers newline-delimited request frames from in until it is closed. Providers
+// reach core through the Host in their request context, whose calls share the stream.
+func (s *server) serveStream(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var writeMu sync.Mutex
+	enc := json.NewEncoder(out)
+	host := newHost(func(call hostCall) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return enc.Encode(call)
+	})
+	defer host.close(errors.New("pluginsdk: core closed the plugin stream"))
+	ctx = context.WithValue(ctx, hostKey{}, host)
+	handle := func(req request) {
+		s.respond(ctx, req, func(resp response) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := enc.Encode(resp); err != nil {
+				fmt.Fprintf(os.Stderr, "pluginsdk: write response for %s: %v\n", req.Method, err)
+				return err
+			}
+			return nil
+		})
+	}
+
+	dec := json.NewDecoder(in)
+	for {
+		var frame inbound
+		if err := dec.Decode(&frame); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("pluginsdk: read request: %w", err)
+		}
+		if frame.Method == "" && (frame.Result != nil || frame.Error != nil) {
+			host.deliver(hostReply{ID: frame.ID, Result: frame.Result, Error: frame.Error})
+			continue
+		}
+		req := frame.request
+		if req.Method == ShutdownMethod {
+			// Core sends nothing after the shutdown frame; finish in-flight calls and return.
+			handle(req)
+			return nil
+		}
+		if req.ID == 0 || req.Method == HandshakeMethod {
+			// Frames without an ID come from a lock-step caller that expects replies in order.
+			handle(req)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handle(req)
+		}()
+	}
+}
+
+// respond answers one request frame, passing each frame to write: chunk frames first when the
+// call is streamed, then the terminal frame.
+func (s *server) respond(ctx context.Context, req request, write func(response) error) {
+	emit := func(chunk any) error {
+		if chunk == nil {
+			return nil
+		}
+		return write(response{ID: req.ID, Chunk: chunk})
+	}
+	if req.Traceparent != "" {
+		ctx = context.WithValue(ctx, traceparentKey{}, req.Traceparent)
+	}
+	result, err := s.dispatch(ctx, req, emit)
+	if err != nil {
+		_ = write(response{ID: req.ID, Error: toError(err)})
+		return
+	}
+	_ = write(response{ID: req.ID, Result: result})
+}
+
+func (s *server) dispatch(ctx context.Context, req request, emit func(any) error) (result any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Fprintf(os.Stderr, "pluginsdk: panic in %s: %v\n%s", req.Method, p, debug.Stack())
+			err = fmt.Errorf("plugin panic in %s: %v", req.Method, p)
+		}
+	}()
+
+	if req.Method == ShutdownMethod {
+		return ok, nil
+	}
+	if err := s.configure(req.Config); err != nil {
+		return nil, err
+	}
+	if req.Method == HandshakeMethod {
+		return s.manifest(), nil
+	}
+	h, ok := s.table[req.Method]
+	if !ok {
+		return nil, orcherr.New("not_implemented", fmt.Sprintf("%s plugin does not implement %s", s.capability, req.Method), nil)
+	}
+
+	// Hold the read lock so a config change is never applied under a running call.
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	if sh, ok := s.streams[req.Method]; ok && req.Stream {
+		return nil, sh(ctx, req.Payload, emit)
+	}
+	return h(ctx, req.Payload)
+}
+
+// configure hands a changed config block to the provider. A failed Configure fails every
+// request until core sends a config the provider accepts.
+func (s *server) configure(raw json.RawMessage) error {
+	c, ok := s.provider.(Configurable)
+	if !ok {
+		return nil
+	}
+	if len(raw) == 0 {
+		raw = json.RawMessage("null")
+	}
+
+	s.configMu.RLock()
+	unchanged := s.lastConfig != nil && bytes.Equal(s.lastConfig, raw)
+	err := s.configErr
+	s.configMu.RUnlock()
+	if unchanged {
+		return err
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	if s.lastConfig != nil && bytes.Equal(s.lastConfig, raw) {
+		return s.configErr
+	}
+	var config map[string]any
+	if err := json.Unmarshal(raw, &config); err != nil {
+		s.configErr = orcherr.New("bad_request", fmt.Sprintf("invalid plugin config: %v", err), err)
+	} else if err := c.Configure(config); err != nil {
+		s.configErr = err
+	} else {
+		s.configErr = nil
+	}
+	s.lastConfig = append([]byte(nil), raw...)
+	return s.configErr
+}
+
+func (s *server) manifest() Manifest {
+	m := Manifest{ProtocolVersion: ProtocolVersion, Capability: s.capability, Methods: methodNames(s.table)}
+	if n, ok := s.provider.(Named); ok {
+		m.Name, m.Version = n.PluginInfo()
+	}
+	return m
+}
+
+// toError converts a provider error into a response error, keeping OpsOrchError codes.
+func toError(err error) *Error {
+	var oe orcherr.OpsOrchError
+	if errors.As(err, &oe) {
+		return &Error{Code: oe.Code, Message: oe.Message}
+	}
+	var oePtr *orcherr.OpsOrchError
+	if errors.As(err, &oePtr) && oePtr != nil {
+		return &Error{Code: oePtr.Code, Message: oePtr.Message}
+	}
+	return &Error{Message: err.Error()}
+}
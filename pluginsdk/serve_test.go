@@ -0,0 +1,415 @@
+package pluginsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+type fakeIncidents struct {
+	mu      sync.Mutex
+	configs []map[string]any
+	release chan struct{} // when set, Get blocks until it is closed
+}
+
+func (f *fakeIncidents) Configure(config map[string]any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if config["token"] == "bad" {
+		return orcherr.New("bad_request", "token rejected", nil)
+	}
+	f.configs = append(f.configs, config)
+	return nil
+}
+
+func (f *fakeIncidents) Query(ctx context.Context, q schema.IncidentQuery) ([]schema.Incident, error) {
+	return []schema.Incident{{ID: "i1", Title: q.Query, Severity: q.Severities[0]}}, nil
+}
+
+func (f *fakeIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	if f.release != nil {
+		<-f.release
+	}
+	if id == "missing" {
+		return schema.Incident{}, orcherr.New("not_found", "incident missing not found", nil)
+	}
+	if id == "panic" {
+		panic("boom")
+	}
+	return schema.Incident{ID: id}, nil
+}
+
+func (f *fakeIncidents) Create(ctx context.Context, in schema.CreateIncidentInput) (schema.Incident, error) {
+	return schema.Incident{ID: "new", Title: in.Title}, nil
+}
+
+func (f *fakeIncidents) Update(ctx context.Context, id string, in schema.UpdateIncidentInput) (schema.Incident, error) {
+	return schema.Incident{ID: id, Title: *in.Title}, nil
+}
+
+func (f *fakeIncidents) GetTimeline(ctx context.Context, id string) ([]schema.TimelineEntry, error) {
+	return nil, errors.New("timeline backend down")
+}
+
+func (f *fakeIncidents) AppendTimeline(ctx context.Context, id string, entry schema.TimelineAppendInput) error {
+	return nil
+}
+
+func (f *fakeIncidents) PluginInfo() (string, string) { return "fake", "0.1.0" }
+
+// session drives serve over in-memory pipes the way core drives a plugin's stdio.
+type session struct {
+	t   *testing.T
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+func startSession(t *testing.T, provider any) *session {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := serve(context.Background(), provider, inR, outW)
+		outW.Close()
+		done <- err
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return &session{t: t, enc: json.NewEncoder(inW), dec: json.NewDecoder(outR)}
+}
+
+func (s *session) send(id uint64, method string, config, payload any) {
+	s.t.Helper()
+	if err := s.enc.Encode(map[string]any{"id": id, "method": method, "config": config, "payload": payload}); err != nil {
+		s.t.Fatalf("send %s: %v", method, err)
+	}
+}
+
+type reply struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func (s *session) recv() reply {
+	s.t.Helper()
+	var r reply
+	if err := s.dec.Decode(&r); err != nil {
+		s.t.Fatalf("recv: %v", err)
+	}
+	return r
+}
+
+func (s *session) call(id uint64, method string, config, payload any) reply {
+	s.t.Helper()
+	s.send(id, method, config, payload)
+	r := s.recv()
+	if r.ID != id {
+		s.t.Fatalf("expected reply id %d, got %d", id, r.ID)
+	}
+	return r
+}
+
+func TestServeHandshakeReportsManifest(t *testing.T) {
+	s := startSession(t, &fakeIncidents{})
+	r := s.call(1, HandshakeMethod, nil, map[string]string{"protocolVersion": "1.0", "capability": "incident"})
+	if r.Error != nil {
+		t.Fatalf("handshake error: %+v", r.Error)
+	}
+	var m Manifest
+	if err := json.Unmarshal(r.Result, &m); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	want := Manifest{
+		ProtocolVersion: ProtocolVersion,
+		Capability:      "incident",
+		Methods:         []string{"incident.create", "incident.get", "incident.query", "incident.timeline.append", "incident.timeline.get", "incident.update"},
+		Name:            "fake",
+		Version:         "0.1.0",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+}
+
+func TestServeDecodesTypedPayloads(t *testing.T) {
+	s := startSession(t, &fakeIncidents{})
+
+	r := s.call(1, "incident.query", nil, schema.IncidentQuery{Query: "db", Severities: []string{"sev1"}})
+	var incidents []schema.Incident
+	if err := json.Unmarshal(r.Result, &incidents); err != nil || len(incidents) != 1 || incidents[0].Title != "db" || incidents[0].Severity != "sev1" {
+		t.Fatalf("unexpected query result %s (err=%v)", r.Result, err)
+	}
+
+	title := "renamed"
+	r = s.call(2, "incident.update", nil, map[string]any{"id": "i9", "input": schema.UpdateIncidentInput{Title: &title}})
+	var inc schema.Incident
+	if err := json.Unmarshal(r.Result, &inc); err != nil || inc.ID != "i9" || inc.Title != "renamed" {
+		t.Fatalf("unexpected update result %s (err=%v)", r.Result, err)
+	}
+
+	r = s.call(3, "incident.timeline.append", nil, map[string]any{"id": "i9", "entry": schema.TimelineAppendInput{Kind: "note"}})
+	if r.Error != nil || string(r.Result) != `{"status":"ok"}` {
+		t.Fatalf("unexpected append result %s error=%+v", r.Result, r.Error)
+	}
+}
+
+func TestServePropagatesErrorCodes(t *testing.T) {
+	s := startSession(t, &fakeIncidents{})
+	cases := []struct {
+		method  string
+		payload any
+		code    string
+		message string
+	}{
+		{"incident.get", map[string]any{"id": "missing"}, "not_found", "incident missing not found"},
+		{"incident.timeline.get", map[string]any{"id": "x"}, "", "timeline backend down"},
+		{"incident.query", `not an object`, "bad_request", ""},
+		{"ticket.query", map[string]any{}, "not_implemented", "incident plugin does not implement ticket.query"},
+		{"incident.get", map[string]any{"id": "panic"}, "", "plugin panic in incident.get: boom"},
+	}
+	for i, tc := range cases {
+		r := s.call(uint64(i+1), tc.method, nil, tc.payload)
+		if r.Error == nil {
+			t.Fatalf("%s: expected error, got result %s", tc.method, r.Result)
+		}
+		if r.Error.Code != tc.code || (tc.message != "" && r.Error.Message != tc.message) {
+			t.Fatalf("%s: unexpected error %+v", tc.method, r.Error)
+		}
+	}
+}
+
+func TestServeInjectsConfigOnChange(t *testing.T) {
+	p := &fakeIncidents{}
+	s := startSession(t, p)
+	s.call(1, HandshakeMethod, map[string]any{"token": "a"}, nil)
+	s.call(2, "incident.get", map[string]any{"token": "a"}, map[string]any{"id": "1"})
+	s.call(3, "incident.get", map[string]any{"token": "b"}, map[string]any{"id": "1"})
+
+	r := s.call(4, "incident.get", map[string]any{"token": "bad"}, map[string]any{"id": "1"})
+	if r.Error == nil || r.Error.Code != "bad_request" {
+		t.Fatalf("expected rejected config to fail the call, got %+v", r)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.configs) != 2 || p.configs[0]["token"] != "a" || p.configs[1]["token"] != "b" {
+		t.Fatalf("expected Configure once per distinct config, got %v", p.configs)
+	}
+}
+
+func TestServeAnswersConcurrentRequestsOutOfOrder(t *testing.T) {
+	p := &fakeIncidents{release: make(chan struct{})}
+	s := startSession(t, p)
+
+	s.send(1, "incident.get", nil, map[string]any{"id": "slow"})
+	s.send(2, "incident.create", nil, schema.CreateIncidentInput{Title: "fast"})
+	if r := s.recv(); r.ID != 2 {
+		t.Fatalf("expected the unblocked request to answer first, got id %d", r.ID)
+	}
+	close(p.release)
+	if r := s.recv(); r.ID != 1 {
+		t.Fatalf("expected id 1, got %d", r.ID)
+	}
+}
+
+func TestServeRejectsNonProvider(t *testing.T) {
+	err := serve(context.Background(), struct{}{}, nil, io.Discard)
+	if err == nil {
+		t.Fatal("expected error for a value that implements no provider interface")
+	}
+}
+
+func TestServeReturnsOnClosedInput(t *testing.T) {
+	inR, inW := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- serve(context.Background(), &fakeIncidents{}, inR, io.Discard) }()
+	inW.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected clean exit, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not return after stdin closed")
+	}
+}
+
+type fakeLogs struct{}
+
+func (fakeLogs) Query(ctx context.Context, q schema.LogQuery) (schema.LogEntries, error) {
+	return schema.LogEntries{Entries: []schema.LogEntry{{Message: "all"}}}, nil
+}
+
+func (fakeLogs) QueryStream(ctx context.Context, q schema.LogQuery, send func(schema.LogEntries) error) error {
+	for _, msg := range []string{"a", "b"} {
+		if err := send(schema.LogEntries{Entries: []schema.LogEntry{{Message: msg}}}); err != nil {
+			return err
+		}
+	}
+	return orcherr.New("rate_limited", "backend throttled", nil)
+}
+
+func TestServeStreamsChunksWhenRequested(t *testing.T) {
+	s := startSession(t, fakeLogs{})
+
+	if err := s.enc.Encode(map[string]any{"id": 1, "method": "log.query", "payload": schema.LogQuery{}, "stream": true}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var frames []map[string]json.RawMessage
+	for {
+		var frame map[string]json.RawMessage
+		if err := s.dec.Decode(&frame); err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		frames = append(frames, frame)
+		if _, ok := frame["chunk"]; !ok {
+			break
+		}
+	}
+	if len(frames) != 3 || string(frames[0]["chunk"]) != `{"entries":[{"timestamp":"0001-01-01T00:00:00Z","message":"a"}]}` {
+		t.Fatalf("unexpected frames: %v", frames)
+	}
+	var terminal reply
+	raw, _ := json.Marshal(frames[2])
+	_ = json.Unmarshal(raw, &terminal)
+	if terminal.ID != 1 || terminal.Error == nil || terminal.Error.Code != "rate_limited" {
+		t.Fatalf("expected terminal error frame, got %s", raw)
+	}
+
+	// Without the stream flag the plain method answers with one result frame.
+	r := s.call(2, "log.query", nil, schema.LogQuery{})
+	if r.Error != nil || !strings.Contains(string(r.Result), `"message":"all"`) {
+		t.Fatalf("unexpected unstreamed reply: %+v %s", r.Error, r.Result)
+	}
+}
+
+// hostIncidents looks up a secret through the host while serving incident.get.
+type hostIncidents struct{ fakeIncidents }
+
+func (h *hostIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	token, err := HostFrom(ctx).Secret(ctx, "jira/token")
+	if err != nil {
+		return schema.Incident{}, err
+	}
+	return schema.Incident{ID: id, Title: token}, nil
+}
+
+func TestServeRoutesHostCallsOnTheSameStream(t *testing.T) {
+	s := startSession(t, &hostIncidents{})
+
+	s.send(1, "incident.get", nil, map[string]string{"id": "INC-1"})
+	var call struct {
+		ID      uint64            `json:"id"`
+		Method  string            `json:"method"`
+		Payload map[string]string `json:"payload"`
+	}
+	if err := s.dec.Decode(&call); err != nil {
+		t.Fatalf("recv host call: %v", err)
+	}
+	if call.Method != HostSecretGet || call.Payload["key"] != "jira/token" {
+		t.Fatalf("unexpected host call %+v", call)
+	}
+	if err := s.enc.Encode(map[string]any{"id": call.ID, "result": "s3cret"}); err != nil {
+		t.Fatalf("send host reply: %v", err)
+	}
+	r := s.recv()
+	if r.ID != 1 || r.Error != nil || !strings.Contains(string(r.Result), `"title":"s3cret"`) {
+		t.Fatalf("unexpected reply: %+v %s", r.Error, r.Result)
+	}
+
+	// A refused host call surfaces to the provider with its code.
+	s.send(2, "incident.get", nil, map[string]string{"id": "INC-2"})
+	if err := s.dec.Decode(&call); err != nil {
+		t.Fatalf("recv host call: %v", err)
+	}
+	if err := s.enc.Encode(map[string]any{"id": call.ID, "error": Error{Code: "forbidden", Message: "no"}}); err != nil {
+		t.Fatalf("send host reply: %v", err)
+	}
+	if r := s.recv(); r.ID != 2 || r.Error == nil || r.Error.Code != "forbidden" {
+		t.Fatalf("expected forbidden, got %+v", r)
+	}
+}
+
+func TestHostUnavailableWithoutStream(t *testing.T) {
+	if _, err := HostFrom(context.Background()).Secret(context.Background(), "k"); !errors.Is(err, ErrNoHost) {
+		t.Fatalf("expected ErrNoHost, got %v", err)
+	}
+}
+
+func TestServeReturnsAfterShutdownFrame(t *testing.T) {
+	s := startSession(t, &fakeIncidents{})
+	// serve stops reading once it has the frame, so an io.Pipe write of the trailing newline
+	// would block; send from a goroutine that the cleanup's close releases.
+	go s.enc.Encode(map[string]any{"id": 1, "method": ShutdownMethod})
+	if r := s.recv(); r.ID != 1 || r.Error != nil {
+		t.Fatalf("shutdown: %+v", r)
+	}
+	// serve has returned and closed its output; the cleanup checks it returned nil.
+	var extra reply
+	if err := s.dec.Decode(&extra); err == nil {
+		t.Fatalf("expected the stream to end after shutdown, got %+v", extra)
+	}
+}
+
+// tracedIncidents answers Get with the trace context of the call.
+type tracedIncidents struct{ fakeIncidents }
+
+func (f *tracedIncidents) Get(ctx context.Context, id string) (schema.Incident, error) {
+	return schema.Incident{ID: id, Title: Traceparent(ctx)}, nil
+}
+
+func TestServeExposesTraceparent(t *testing.T) {
+	s := startSession(t, &tracedIncidents{})
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if err := s.enc.Encode(map[string]any{"id": 1, "method": "incident.get", "payload": map[string]string{"id": "i1"}, "traceparent": parent}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	var inc schema.Incident
+	if r := s.recv(); json.Unmarshal(r.Result, &inc) != nil || inc.Title != parent {
+		t.Fatalf("expected the frame's traceparent in the call context, got %s", r.Result)
+	}
+
+	r := s.call(2, "incident.get", nil, map[string]string{"id": "i2"})
+	if json.Unmarshal(r.Result, &inc) != nil || inc.Title != "" {
+		t.Fatalf("expected no traceparent on an untraced call, got %s", r.Result)
+	}
+}
+
+// pingedIncidents implements health.Pinger.
+type pingedIncidents struct {
+	fakeIncidents
+	err error
+}
+
+func (f *pingedIncidents) Ping(ctx context.Context) error { return f.err }
+
+func TestServeDeclaresPingForPingers(t *testing.T) {
+	s := startSession(t, &pingedIncidents{err: orcherr.New("forbidden", "token expired", nil)})
+	var m Manifest
+	if err := json.Unmarshal(s.call(1, HandshakeMethod, nil, nil).Result, &m); err != nil || m.Methods[len(m.Methods)-1] != PingMethod {
+		t.Fatalf("expected %s in the manifest, got %+v", PingMethod, m)
+	}
+	if r := s.call(2, PingMethod, nil, nil); r.Error == nil || r.Error.Code != "forbidden" {
+		t.Fatalf("expected the ping error, got %+v", r)
+	}
+
+	s = startSession(t, &fakeIncidents{})
+	if r := s.call(1, PingMethod, nil, nil); r.Error == nil || r.Error.Code != "not_implemented" {
+		t.Fatalf("expected providers without Ping not to answer %s, got %+v", PingMethod, r)
+	}
+}
@@ -0,0 +1,13 @@
+package pluginsdk
+
+import "context"
+
+type traceparentKey struct{}
+
+// Traceparent returns the W3C traceparent of the core span that made the current call, or ""
+// when core is not tracing. Adapters that trace their own work pass it on as the parent of
+// their spans, or forward it as the traceparent header of the requests they make upstream.
+func Traceparent(ctx context.Context) string {
+	v, _ := ctx.Value(traceparentKey{}).(string)
+	return v
+}
@@ -52,7 +52,7 @@ type OrchestrationStep struct {
 	ID    string `json:"id"`
 	Title string `json:"title"`
 
-	// Type is a normalized hint: "manual", "automated".
+	// Type is a normalized hint: StepTypeManual or StepTypeAutomated.
 	Type string `json:"type,omitempty"`
 
 	// Description is operator-facing text. May include Markdown for manual steps.
@@ -68,6 +68,12 @@ type OrchestrationStep struct {
 	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
+// Step types documented for OrchestrationStep.Type.
+const (
+	StepTypeManual    = "manual"
+	StepTypeAutomated = "automated"
+)
+
 // ---- Runs ----
 
 // OrchestrationRunQuery filters runs from the orchestration provider.